@@ -1,9 +1,11 @@
 package config
 
 import (
-	"log"
+	"fmt"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/giantswarm/microerror"
 )
@@ -14,12 +16,14 @@ const (
 	EnvEncryptPassph = "ETCDBACKUP_PASSPHRASE"
 )
 
-//AWS config
+// AWS config
 type AWSConfig struct {
-	AccessKey string
-	Bucket    string
-	Region    string
-	SecretKey string
+	AccessKey  string
+	Bucket     string
+	ExternalID string
+	Region     string
+	RoleARN    string
+	SecretKey  string
 }
 
 // Push gateway address
@@ -31,67 +35,237 @@ type PrometheusConfig struct {
 // Initialize parameters.
 
 type Flags struct {
-	AwsAccessKey    string
-	AwsSecretKey    string
-	AwsS3Bucket     string
-	AwsS3Region     string
-	EtcdV2DataDir   string
-	EtcdV3Cert      string
-	EtcdV3CACert    string
-	EtcdV3Key       string
-	EtcdV3Endpoints string
-	EncryptPass     string
-	GuestBackup     bool
-	Help            bool
-	Prefix          string
-	Provider        string
-	PushGatewayURL  string
-	PushGatewayJob  string
-	SkipV2          bool
+	AwsAccessKey     string
+	AwsAccessKeyFile string
+	AwsExternalID    string
+	AwsRoleARN       string
+	AwsSecretKey     string
+	AwsSecretKeyFile string
+	AwsS3Bucket      string
+	AwsS3Region      string
+	CertSecretKeys   string
+	Compression      string
+	CompressionLevel int
+	// Command is the subcommand, e.g. list or restore, empty for backups.
+	Command               string
+	Config                string
+	CRDNamespaces         string
+	Destinations          string
+	DestinationPolicy     string
+	EtcdV2DataDir         string
+	EtcdV3Cert            string
+	EtcdV3CACert          string
+	EtcdV3Key             string
+	EtcdV3Endpoints       string
+	EtcdV3Quota           int64
+	EncryptPass           string
+	EncryptPassFile       string
+	Export                bool
+	ExportPrefixes        string
+	GuestBackup           bool
+	GuestClustersFile     string
+	Help                  bool
+	Inventory             string
+	Kubeconfig            string
+	KubeContext           string
+	Prefix                string
+	Provider              string
+	PushGatewayURL        string
+	PushGatewayJob        string
+	ReplicationTargets    string
+	ReplicationTimeout    time.Duration
+	ReplicationPoll       time.Duration
+	ReportFile            string
+	ScheduleInterval      time.Duration
+	SecretNamespace       string
+	SecretNameTemplate    string
+	SkipPreflight         bool
+	SkipV2                bool
+	SnapshotDiskRateLimit string
+	UploadRateLimit       string
+	VersionPolicyFile     string
+
+	// Journal records the changes between scheduled backups.
+	Journal                bool
+	JournalSegmentInterval time.Duration
 }
 
 // parse
 func ParseEnvs(f *Flags) {
-	f.AwsAccessKey = os.Getenv(EnvAwsAccessKey)
-	f.AwsSecretKey = os.Getenv(EnvAwsSecretKey)
-	f.EncryptPass = os.Getenv(EnvEncryptPassph)
+	f.AwsAccessKey = envOr(EnvAwsAccessKey, f.AwsAccessKey)
+	f.AwsSecretKey = envOr(EnvAwsSecretKey, f.AwsSecretKey)
+	f.EncryptPass = envOr(EnvEncryptPassph, f.EncryptPass)
+}
+
+// envOr returns the value of the environment variable, or def when unset.
+func envOr(name string, def string) string {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return def
+	}
+	return v
 }
 
-func CheckConfig(f Flags) error {
-	// Validate parameters.
-	// Prefix is required.
-	if f.Prefix == "" {
-		log.Fatalf("-prefix required")
-		return microerror.Mask(invalidConfigError)
+// CheckConfig validates f and fills in derived settings. All problems are
+// returned at once as ValidationErrors.
+func CheckConfig(f *Flags) error {
+	var errs ValidationErrors
+
+	// Prefix is required for backups, inventory targets have their own.
+	// Subcommands use it as an optional filter.
+	if f.Prefix == "" && f.Inventory == "" && f.Command == "" {
+		errs = append(errs, FieldError{Field: "prefix", Message: "must not be empty"})
+	}
+
+	// Static AWS keys are optional, the default credential chain is used
+	// without them. If given, both are needed. The keys themselves have no
+	// flag, so errors name the flag of their file.
+	hasAccessKey := f.AwsAccessKey != "" || f.AwsAccessKeyFile != ""
+	hasSecretKey := f.AwsSecretKey != "" || f.AwsSecretKeyFile != ""
+	if hasSecretKey && !hasAccessKey {
+		errs = append(errs, FieldError{Field: "aws-access-key-file", Message: fmt.Sprintf("static credentials need the access key, set it or %s", EnvAwsAccessKey)})
+	}
+	if hasAccessKey && !hasSecretKey {
+		errs = append(errs, FieldError{Field: "aws-secret-key-file", Message: fmt.Sprintf("static credentials need the secret key, set it or %s", EnvAwsSecretKey)})
+	}
+	if f.AwsExternalID != "" && f.AwsRoleARN == "" {
+		errs = append(errs, FieldError{Field: "aws-external-id", Message: "needs aws-role-arn"})
 	}
 
-	// AWS is requirement.
-	if f.AwsAccessKey == "" || f.AwsSecretKey == "" {
-		log.Fatalf("No environment variables %s and %s provided", EnvAwsAccessKey, EnvAwsSecretKey)
-		return microerror.Mask(invalidConfigError)
+	// Secrets are given either as value or as file, and files must be readable.
+	secrets := []struct {
+		field string
+		env   string
+		value string
+		file  string
+	}{
+		{field: "aws-access-key-file", env: EnvAwsAccessKey, value: f.AwsAccessKey, file: f.AwsAccessKeyFile},
+		{field: "aws-secret-key-file", env: EnvAwsSecretKey, value: f.AwsSecretKey, file: f.AwsSecretKeyFile},
+		{field: "passphrase-file", env: EnvEncryptPassph, value: f.EncryptPass, file: f.EncryptPassFile},
+	}
+	for _, secret := range secrets {
+		if secret.value != "" && secret.file != "" {
+			errs = append(errs, FieldError{Field: secret.field, Message: fmt.Sprintf("must not be set together with %s", secret.env)})
+			continue
+		}
+		if secret.file != "" {
+			_, err := Secret{File: secret.file}.Get()
+			if err != nil {
+				errs = append(errs, FieldError{Field: secret.field, Message: err.Error()})
+			}
+		}
+	}
+
+	// Guest clusters are discovered from provider CRs unless listed in a file.
+	if f.GuestBackup && f.GuestClustersFile == "" && f.Inventory == "" {
+		switch f.Provider {
+		case "aws", "azure", "kvm":
+		default:
+			errs = append(errs, FieldError{Field: "provider", Message: fmt.Sprintf("must be aws, azure or kvm, got %q", f.Provider)})
+		}
 	}
 
 	// check that the Prometheus Url, if present, is a valid URL
 	if f.PushGatewayURL != "" {
 		_, err := url.ParseRequestURI(f.PushGatewayURL)
 		if err != nil {
-			log.Fatalf("--prometheus-url is invalid")
-			return microerror.Mask(invalidConfigError)
+			errs = append(errs, FieldError{Field: "prometheus-url", Message: err.Error()})
 		}
 
 		if f.PushGatewayJob == "" {
-			log.Fatalf("--prometheus-job is mandatory when --prometheus-url is set")
-			return microerror.Mask(invalidConfigError)
+			errs = append(errs, FieldError{Field: "prometheus-job", Message: "must not be empty when prometheus-url is set"})
+		}
+	}
+
+	for _, d := range SplitList(f.Destinations) {
+		if !strings.HasPrefix(d, "s3://") && !strings.HasPrefix(d, "file://") {
+			errs = append(errs, FieldError{Field: "destinations", Message: fmt.Sprintf("%q must start with s3:// or file://", d)})
+		}
+	}
+	switch f.DestinationPolicy {
+	case "all", "any", "primary":
+	default:
+		errs = append(errs, FieldError{Field: "destination-policy", Message: fmt.Sprintf("must be all, any or primary, got %q", f.DestinationPolicy)})
+	}
+
+	if f.ReplicationTargets != "" {
+		for _, t := range SplitList(f.ReplicationTargets) {
+			if !strings.HasPrefix(t, "s3://") {
+				errs = append(errs, FieldError{Field: "replication-targets", Message: fmt.Sprintf("%q must start with s3://", t)})
+			}
+		}
+		var s3Destination bool
+		for _, d := range SplitList(f.Destinations) {
+			if strings.HasPrefix(d, "s3://") {
+				s3Destination = true
+			}
 		}
-	} else {
-		log.Print("Skipping prometheus metrics push as --prometheus-url is not set")
+		if f.Destinations != "" && !s3Destination {
+			errs = append(errs, FieldError{Field: "replication-targets", Message: "need an s3:// destination to compare with"})
+		}
+		if f.ReplicationTimeout <= 0 {
+			errs = append(errs, FieldError{Field: "replication-timeout", Message: "must be positive"})
+		}
+		if f.ReplicationPoll <= 0 {
+			errs = append(errs, FieldError{Field: "replication-poll-interval", Message: "must be positive"})
+		}
+	}
+
+	for _, limit := range []struct {
+		field string
+		value string
+	}{
+		{field: "upload-rate-limit", value: f.UploadRateLimit},
+		{field: "snapshot-disk-read-rate-limit", value: f.SnapshotDiskRateLimit},
+	} {
+		_, err := ParseRateLimit(limit.value)
+		if err != nil {
+			errs = append(errs, FieldError{Field: limit.field, Message: err.Error()})
+		}
+	}
+
+	switch f.Compression {
+	case "gzip":
+		if f.CompressionLevel < 0 || f.CompressionLevel > 9 {
+			errs = append(errs, FieldError{Field: "compression-level", Message: fmt.Sprintf("must be between 1 and 9 for gzip, got %d", f.CompressionLevel)})
+		}
+	case "zstd":
+		if f.CompressionLevel < 0 || f.CompressionLevel > 22 {
+			errs = append(errs, FieldError{Field: "compression-level", Message: fmt.Sprintf("must be between 1 and 22 for zstd, got %d", f.CompressionLevel)})
+		}
+	case "none":
+		if f.CompressionLevel != 0 {
+			errs = append(errs, FieldError{Field: "compression-level", Message: "must not be set without compression"})
+		}
+	default:
+		errs = append(errs, FieldError{Field: "compression", Message: fmt.Sprintf("must be gzip, zstd or none, got %q", f.Compression)})
+	}
+
+	if f.EtcdV3Quota < 0 {
+		errs = append(errs, FieldError{Field: "etcd-v3-quota-backend-bytes", Message: "must not be negative"})
+	}
+
+	if f.ScheduleInterval < 0 {
+		errs = append(errs, FieldError{Field: "schedule-interval", Message: "must not be negative"})
+	}
+
+	// journals are recorded while waiting for the next backup
+	if f.Journal {
+		if f.ScheduleInterval == 0 {
+			errs = append(errs, FieldError{Field: "journal", Message: "needs schedule-interval"})
+		}
+		if f.JournalSegmentInterval <= 0 {
+			errs = append(errs, FieldError{Field: "journal-segment-interval", Message: "must be positive"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return microerror.Mask(errs)
 	}
 
 	// Skip V2 etcd if not datadir provided.
 	if f.EtcdV2DataDir == "" {
 		f.SkipV2 = true
-		log.Print("Skipping etcd V2 etcd as -etcd-v2-datadir is not set")
-		return microerror.Mask(invalidConfigError)
 	}
 
 	return nil
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+func Test_CheckConfig_FieldsAreFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := &Flags{}
+	RegisterFlags(fs, f)
+	err := fs.Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every setting below is invalid
+	f.AwsSecretKey = "secret"
+	f.AwsAccessKeyFile = "/nonexistent/access-key"
+	f.EncryptPass = "pass"
+	f.EncryptPassFile = "/nonexistent/passphrase"
+	f.AwsExternalID = "external"
+	f.GuestBackup = true
+	f.Provider = "openstack"
+	f.PushGatewayURL = "no url"
+	f.Destinations = "ftp://backups"
+	f.DestinationPolicy = "some"
+	f.ReplicationTargets = "file:///dr"
+	f.ReplicationTimeout = 0
+	f.ReplicationPoll = 0
+	f.UploadRateLimit = "fast"
+	f.SnapshotDiskRateLimit = "-1"
+	f.Compression = "lz4"
+	f.EtcdV3Quota = -1
+	f.Journal = true
+	f.JournalSegmentInterval = 0
+
+	err = CheckConfig(f)
+	if !IsInvalidConfig(err) {
+		t.Fatalf("expected invalid config error, got %#v", err)
+	}
+
+	errs := microerror.Cause(err).(ValidationErrors)
+	fields := map[string]bool{}
+	for _, fe := range errs {
+		if fs.Lookup(fe.Field) == nil {
+			t.Errorf("field %q of %q is no flag", fe.Field, fe.Message)
+		}
+		fields[fe.Field] = true
+	}
+
+	for _, field := range []string{"prefix", "aws-access-key-file", "passphrase-file", "aws-external-id", "provider", "prometheus-url", "prometheus-job", "destinations", "destination-policy", "replication-targets", "replication-timeout", "replication-poll-interval", "upload-rate-limit", "snapshot-disk-read-rate-limit", "compression", "etcd-v3-quota-backend-bytes", "journal", "journal-segment-interval"} {
+		if !fields[field] {
+			t.Errorf("expected an error for %s, got %v", field, errs)
+		}
+	}
+}
+
+func Test_CheckConfig_ReplicationNeedsS3Destination(t *testing.T) {
+	testCases := []struct {
+		name         string
+		destinations string
+		expected     bool
+	}{
+		{name: "case 0: s3 destination", destinations: "file:///backups,s3://backups", expected: false},
+		{name: "case 1: s3 only in a file path", destinations: "file:///backups/s3://backups", expected: true},
+		{name: "case 2: default destination", destinations: "", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			f := &Flags{}
+			RegisterFlags(fs, f)
+			err := fs.Parse(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Destinations = tc.destinations
+			f.ReplicationTargets = "s3://dr"
+
+			var failed bool
+			if errs, ok := microerror.Cause(CheckConfig(f)).(ValidationErrors); ok {
+				for _, fe := range errs {
+					failed = failed || fe.Field == "replication-targets"
+				}
+			}
+			if failed != tc.expected {
+				t.Fatalf("expected replication-targets error %t, got %t", tc.expected, failed)
+			}
+		})
+	}
+}
+
+func Test_SplitList(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "", expected: nil},
+		{input: " , ,", expected: nil},
+		{input: "a", expected: []string{"a"}},
+		{input: " a, b ,,c ", expected: []string{"a", "b", "c"}},
+	}
+
+	for _, tc := range testCases {
+		l := SplitList(tc.input)
+		if !reflect.DeepEqual(l, tc.expected) {
+			t.Errorf("SplitList(%q): expected %#v, got %#v", tc.input, tc.expected, l)
+		}
+	}
+}
@@ -1,10 +1,42 @@
 package config
 
-import "github.com/giantswarm/microerror"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
 
 var invalidConfigError = microerror.New("invalid config")
 
-// IsInvalidConfig asserts invalidConfigError.
+// IsInvalidConfig asserts invalidConfigError and ValidationErrors.
 func IsInvalidConfig(err error) bool {
-	return microerror.Cause(err) == invalidConfigError
+	c := microerror.Cause(err)
+	if _, ok := c.(ValidationErrors); ok {
+		return true
+	}
+	return c == invalidConfigError
+}
+
+// FieldError describes a single invalid setting.
+type FieldError struct {
+	// Field is the name of the flag, which also derives the environment
+	// variable and config file key of the setting.
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors aggregates all invalid settings found by CheckConfig.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	var msgs []string
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
 }
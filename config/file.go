@@ -0,0 +1,219 @@
+package config
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"sigs.k8s.io/yaml"
+)
+
+// File is the structure of the YAML or JSON config file, e.g.
+//
+//	prefix: cluster1
+//	provider: aws
+//	etcd:
+//	  v3:
+//	    endpoints: https://127.0.0.1:2379
+//	storage:
+//	  aws:
+//	    bucket: etcdbackups
+//	    region: eu-central-1
+//	discovery:
+//	  guestBackup: true
+//	  crdNamespaces:
+//	  - default
+//	schedule:
+//	  interval: 1h
+type File struct {
+	Prefix   string `json:"prefix,omitempty"`
+	Provider string `json:"provider,omitempty"`
+
+	Etcd struct {
+		V2 struct {
+			DataDir string `json:"dataDir,omitempty"`
+			Skip    *bool  `json:"skip,omitempty"`
+		} `json:"v2,omitempty"`
+		V3 struct {
+			Endpoints string `json:"endpoints,omitempty"`
+			CACert    string `json:"caCert,omitempty"`
+			Cert      string `json:"cert,omitempty"`
+			Key       string `json:"key,omitempty"`
+
+			QuotaBackendBytes *int64 `json:"quotaBackendBytes,omitempty"`
+			SkipPreflight     *bool  `json:"skipPreflight,omitempty"`
+		} `json:"v3,omitempty"`
+	} `json:"etcd,omitempty"`
+
+	Compression struct {
+		Algorithm string `json:"algorithm,omitempty"`
+		Level     *int   `json:"level,omitempty"`
+	} `json:"compression,omitempty"`
+
+	Export struct {
+		Enabled  *bool    `json:"enabled,omitempty"`
+		Prefixes []string `json:"prefixes,omitempty"`
+	} `json:"export,omitempty"`
+
+	Journal struct {
+		Enabled         *bool  `json:"enabled,omitempty"`
+		SegmentInterval string `json:"segmentInterval,omitempty"`
+	} `json:"journal,omitempty"`
+
+	RateLimits RateLimits `json:"rateLimits,omitempty"`
+
+	Storage struct {
+		Destinations      []string `json:"destinations,omitempty"`
+		DestinationPolicy string   `json:"destinationPolicy,omitempty"`
+
+		Replication struct {
+			Targets      []string `json:"targets,omitempty"`
+			Timeout      string   `json:"timeout,omitempty"`
+			PollInterval string   `json:"pollInterval,omitempty"`
+		} `json:"replication,omitempty"`
+
+		AWS struct {
+			Bucket        string `json:"bucket,omitempty"`
+			Region        string `json:"region,omitempty"`
+			AccessKey     string `json:"accessKey,omitempty"`
+			AccessKeyFile string `json:"accessKeyFile,omitempty"`
+			SecretKey     string `json:"secretKey,omitempty"`
+			SecretKeyFile string `json:"secretKeyFile,omitempty"`
+			RoleARN       string `json:"roleARN,omitempty"`
+			ExternalID    string `json:"externalID,omitempty"`
+		} `json:"aws,omitempty"`
+	} `json:"storage,omitempty"`
+
+	Encryption struct {
+		Passphrase     string `json:"passphrase,omitempty"`
+		PassphraseFile string `json:"passphraseFile,omitempty"`
+	} `json:"encryption,omitempty"`
+
+	Discovery struct {
+		GuestBackup        *bool    `json:"guestBackup,omitempty"`
+		GuestClustersFile  string   `json:"guestClustersFile,omitempty"`
+		Inventory          string   `json:"inventory,omitempty"`
+		CRDNamespaces      []string `json:"crdNamespaces,omitempty"`
+		SecretNamespace    string   `json:"secretNamespace,omitempty"`
+		SecretNameTemplate string   `json:"secretNameTemplate,omitempty"`
+		CertSecretKeys     string   `json:"certSecretKeys,omitempty"`
+		VersionPolicyFile  string   `json:"versionPolicyFile,omitempty"`
+		Kubeconfig         string   `json:"kubeconfig,omitempty"`
+		KubeContext        string   `json:"kubeContext,omitempty"`
+	} `json:"discovery,omitempty"`
+
+	Metrics struct {
+		PushGatewayURL string `json:"pushGatewayURL,omitempty"`
+		PushGatewayJob string `json:"pushGatewayJob,omitempty"`
+	} `json:"metrics,omitempty"`
+
+	Report struct {
+		File string `json:"file,omitempty"`
+	} `json:"report,omitempty"`
+
+	Schedule struct {
+		Interval string `json:"interval,omitempty"`
+	} `json:"schedule,omitempty"`
+}
+
+// LoadFile reads the config file at path. Unknown fields are rejected to
+// catch typos.
+func LoadFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var f File
+	err = yaml.UnmarshalStrict(data, &f)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "%s: %s", path, err)
+	}
+
+	return &f, nil
+}
+
+// flagValues returns the settings of the file keyed by flag name. Unset
+// settings are omitted.
+func (f *File) flagValues() map[string]string {
+	values := map[string]string{}
+	add := func(name string, v string) {
+		if v != "" {
+			values[name] = v
+		}
+	}
+	addBool := func(name string, v *bool) {
+		if v != nil {
+			values[name] = strconv.FormatBool(*v)
+		}
+	}
+	addInt := func(name string, v *int) {
+		if v != nil {
+			values[name] = strconv.Itoa(*v)
+		}
+	}
+	addInt64 := func(name string, v *int64) {
+		if v != nil {
+			values[name] = strconv.FormatInt(*v, 10)
+		}
+	}
+
+	add("prefix", f.Prefix)
+	add("provider", f.Provider)
+
+	add("etcd-v2-datadir", f.Etcd.V2.DataDir)
+	addBool("skip-v2", f.Etcd.V2.Skip)
+	add("etcd-v3-endpoints", f.Etcd.V3.Endpoints)
+	add("etcd-v3-cacert", f.Etcd.V3.CACert)
+	add("etcd-v3-cert", f.Etcd.V3.Cert)
+	add("etcd-v3-key", f.Etcd.V3.Key)
+	addInt64("etcd-v3-quota-backend-bytes", f.Etcd.V3.QuotaBackendBytes)
+	addBool("skip-preflight", f.Etcd.V3.SkipPreflight)
+
+	add("compression", f.Compression.Algorithm)
+	addInt("compression-level", f.Compression.Level)
+
+	addBool("export", f.Export.Enabled)
+	add("export-prefixes", strings.Join(f.Export.Prefixes, ","))
+
+	addBool("journal", f.Journal.Enabled)
+	add("journal-segment-interval", f.Journal.SegmentInterval)
+
+	add("upload-rate-limit", f.RateLimits.Upload)
+	add("snapshot-disk-read-rate-limit", f.RateLimits.SnapshotDiskRead)
+
+	add("destinations", strings.Join(f.Storage.Destinations, ","))
+	add("destination-policy", f.Storage.DestinationPolicy)
+	add("replication-targets", strings.Join(f.Storage.Replication.Targets, ","))
+	add("replication-timeout", f.Storage.Replication.Timeout)
+	add("replication-poll-interval", f.Storage.Replication.PollInterval)
+	add("aws-s3-bucket", f.Storage.AWS.Bucket)
+	add("aws-s3-region", f.Storage.AWS.Region)
+	add("aws-access-key-file", f.Storage.AWS.AccessKeyFile)
+	add("aws-secret-key-file", f.Storage.AWS.SecretKeyFile)
+	add("aws-role-arn", f.Storage.AWS.RoleARN)
+	add("aws-external-id", f.Storage.AWS.ExternalID)
+
+	add("passphrase-file", f.Encryption.PassphraseFile)
+
+	addBool("guest-backup", f.Discovery.GuestBackup)
+	add("guest-clusters-file", f.Discovery.GuestClustersFile)
+	add("inventory", f.Discovery.Inventory)
+	add("crd-namespaces", strings.Join(f.Discovery.CRDNamespaces, ","))
+	add("secret-namespace", f.Discovery.SecretNamespace)
+	add("secret-name-template", f.Discovery.SecretNameTemplate)
+	add("cert-secret-keys", f.Discovery.CertSecretKeys)
+	add("version-policy", f.Discovery.VersionPolicyFile)
+	add("kubeconfig", f.Discovery.Kubeconfig)
+	add("kube-context", f.Discovery.KubeContext)
+
+	add("prometheus-url", f.Metrics.PushGatewayURL)
+	add("prometheus-job", f.Metrics.PushGatewayJob)
+
+	add("report-file", f.Report.File)
+
+	add("schedule-interval", f.Schedule.Interval)
+
+	return values
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// EnvPrefix is prepended to the upper-cased flag name to form the environment
+// variable overriding it, e.g. ETCDBACKUP_AWS_S3_BUCKET for -aws-s3-bucket.
+const EnvPrefix = "ETCDBACKUP_"
+
+const (
+	// DefaultNamespace is where CRs and secrets are located in Giant Swarm
+	// installations.
+	DefaultNamespace = "default"
+
+	// DefaultSecretNameTemplate is the name of the secret holding the etcd
+	// client certificates of a guest cluster.
+	DefaultSecretNameTemplate = "{{.ID}}-etcd"
+
+	// DefaultCertKeys are the data keys of Giant Swarm etcd secrets.
+	DefaultCertKeys = "ca,crt,key"
+)
+
+// RegisterFlags defines all command line flags on fs, storing values in f.
+func RegisterFlags(fs *flag.FlagSet, f *Flags) {
+	fs.StringVar(&f.Config, "config", "", "YAML or JSON config file. Flags and environment variables take precedence over it")
+	fs.StringVar(&f.AwsAccessKeyFile, "aws-access-key-file", "", "File containing the AWS access key for S3, re-read on every backup")
+	fs.StringVar(&f.AwsSecretKeyFile, "aws-secret-key-file", "", "File containing the AWS secret access key for S3, re-read on every backup")
+	fs.StringVar(&f.EncryptPassFile, "passphrase-file", "", "File containing the passphrase for AES encryption, re-read on every backup")
+	fs.StringVar(&f.AwsRoleARN, "aws-role-arn", "", "AWS role to assume for S3 access, on top of static keys or the default credential chain")
+	fs.StringVar(&f.AwsExternalID, "aws-external-id", "", "External ID used when assuming -aws-role-arn")
+	fs.StringVar(&f.AwsS3Bucket, "aws-s3-bucket", "etcdbackups", "AWS S3 bucket for backups")
+	fs.StringVar(&f.AwsS3Region, "aws-s3-region", "us-east-1", "AWS S3 region for backups")
+	fs.StringVar(&f.Destinations, "destinations", "", "Comma separated backup destinations (i.e. s3://bucket?region=eu-west-1,file:///mnt/backups). If not set -aws-s3-bucket is used")
+	fs.StringVar(&f.DestinationPolicy, "destination-policy", "all", "Which destinations must succeed for a backup to count as successful (all, any or primary, the first destination)")
+	fs.StringVar(&f.ReplicationTargets, "replication-targets", "", "Comma separated buckets the first S3 destination replicates to (i.e. s3://bucket-dr?region=eu-central-1). If set, every upload is checked for arriving there")
+	fs.DurationVar(&f.ReplicationTimeout, "replication-timeout", 5*time.Minute, "How long to wait for a backup to be replicated to each of -replication-targets")
+	fs.DurationVar(&f.ReplicationPoll, "replication-poll-interval", 15*time.Second, "Interval between checks of -replication-targets")
+	fs.StringVar(&f.Compression, "compression", "gzip", "Backup compression: gzip, zstd or none. zstd needs the zstd binary")
+	fs.IntVar(&f.CompressionLevel, "compression-level", 0, "Compression level (gzip 1-9, zstd 1-22). If not set the default level of the algorithm is used")
+	fs.StringVar(&f.UploadRateLimit, "upload-rate-limit", "", "Upload bandwidth limit in bytes per second (i.e. 20Mi). Guest clusters override it with the label "+UploadRateLimitLabel)
+	fs.StringVar(&f.SnapshotDiskRateLimit, "snapshot-disk-read-rate-limit", "", "Bandwidth limit of reading the local snapshot file, or v2 backup directory, in bytes per second (i.e. 50Mi). The etcdctl snapshot download is not limited. Guest clusters override it with the label "+SnapshotDiskReadRateLimitLabel)
+	fs.BoolVar(&f.GuestBackup, "guest-backup", false, "Enable guest clusters etcd backup.")
+	fs.StringVar(&f.GuestClustersFile, "guest-clusters-file", "", "File listing guest clusters to backup. If not set guest clusters are discovered from provider CRs")
+	fs.StringVar(&f.EtcdV2DataDir, "etcd-v2-datadir", "", "Etcd datadir. If not set V2 etcd will be skipped")
+	fs.StringVar(&f.EtcdV3Cert, "etcd-v3-cert", "", "Client certificate for etcd connection")
+	fs.StringVar(&f.EtcdV3CACert, "etcd-v3-cacert", "", "Client CA certificate for etcd connection")
+	fs.StringVar(&f.EtcdV3Key, "etcd-v3-key", "", "Client private key for etcd connection")
+	fs.StringVar(&f.EtcdV3Endpoints, "etcd-v3-endpoints", "http://127.0.0.1:2379", "Endpoints for etcd connection")
+	fs.Int64Var(&f.EtcdV3Quota, "etcd-v3-quota-backend-bytes", 0, "Backend quota of the etcd clusters, as set with etcd --quota-backend-bytes. Backups are skipped when a database is close to it, 0 disables the check")
+	fs.BoolVar(&f.Export, "export", false, "Upload a logical export of the keys at the snapshot revision next to every v3 backup")
+	fs.StringVar(&f.ExportPrefixes, "export-prefixes", "", "Comma separated key prefixes to export (i.e. /registry/secrets/,/registry/configmaps/). If not set all keys are exported")
+	fs.BoolVar(&f.Journal, "journal", false, "Record the changes between v3 backups in journal segments while waiting for the next scheduled backup. Needs -schedule-interval")
+	fs.DurationVar(&f.JournalSegmentInterval, "journal-segment-interval", 5*time.Minute, "Interval journal segments are uploaded with")
+	fs.BoolVar(&f.SkipPreflight, "skip-preflight", false, "Skip the pre-flight health, leader, alarm, database size and member list checks before v3 backups")
+	fs.StringVar(&f.CRDNamespaces, "crd-namespaces", DefaultNamespace, "Comma separated namespaces where guest cluster CRs are located")
+	fs.StringVar(&f.SecretNamespace, "secret-namespace", DefaultNamespace, "Namespace where guest cluster etcd certificate secrets are located")
+	fs.StringVar(&f.SecretNameTemplate, "secret-name-template", DefaultSecretNameTemplate, "Template for guest cluster etcd certificate secret names, executed with the cluster (i.e. {{.ID}}-etcd)")
+	fs.StringVar(&f.CertSecretKeys, "cert-secret-keys", DefaultCertKeys, "Data keys of CA, certificate and private key in etcd certificate secrets (i.e. ca,crt,key), or 'tls' for kubernetes.io/tls secrets")
+	fs.StringVar(&f.VersionPolicyFile, "version-policy", "", "File with minimum guest cluster release versions per provider and channel. If not set built-in minimums are used")
+	fs.StringVar(&f.Inventory, "inventory", "", "File listing standalone etcd clusters to backup. If set only these clusters are backed up")
+	fs.StringVar(&f.Kubeconfig, "kubeconfig", "", "Kubeconfig file to access the host cluster. If not set the files in $KUBECONFIG are used, and in-cluster config without them")
+	fs.StringVar(&f.KubeContext, "kube-context", "", "Kubeconfig context to use. If not set the current context is used")
+	fs.StringVar(&f.Prefix, "prefix", "", "[mandatory] Prefix to use in etcd filenames")
+	fs.StringVar(&f.Provider, "provider", "", "[mandatory] provider (aws, azure or kvm)")
+	fs.BoolVar(&f.SkipV2, "skip-v2", false, "flag for skipping etcd v2 backup")
+	fs.StringVar(&f.PushGatewayURL, "prometheus-url", "", "URL of the Prometheus push gateway (i.e. http://pushgw.example.com:9001)")
+	fs.StringVar(&f.PushGatewayJob, "prometheus-job", "", "Job name for the Prometheus push gateway (i.e. etcd_backup)")
+	fs.StringVar(&f.ReportFile, "report-file", "", "File to write a JSON report of all processed clusters to")
+	fs.DurationVar(&f.ScheduleInterval, "schedule-interval", 0, "Run backups repeatedly with this interval (i.e. 1h). If not set a single backup is run")
+
+	fs.BoolVar(&f.Help, "help", false, "Print usage and exit")
+}
+
+// SplitList splits a comma separated flag value and drops empty items.
+func SplitList(s string) []string {
+	var l []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			l = append(l, item)
+		}
+	}
+	return l
+}
+
+// EnvName returns the environment variable overriding the given flag.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// Load applies the config file and ETCDBACKUP_* environment variables to all
+// flags not set on the command line. fs must already be parsed. The precedence
+// is flag, environment variable, config file, default.
+func Load(fs *flag.FlagSet, f *Flags) error {
+	set := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+
+	// The config file itself can come from the environment.
+	if !set["config"] {
+		if v, ok := os.LookupEnv(EnvName("config")); ok {
+			f.Config = v
+		}
+	}
+
+	var file *File
+	fileValues := map[string]string{}
+	if f.Config != "" {
+		var err error
+		file, err = LoadFile(f.Config)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		fileValues = file.flagValues()
+	}
+
+	var err error
+	fs.VisitAll(func(fl *flag.Flag) {
+		if err != nil || set[fl.Name] || fl.Name == "config" {
+			return
+		}
+
+		v, ok := os.LookupEnv(EnvName(fl.Name))
+		if !ok {
+			v, ok = fileValues[fl.Name]
+		}
+		if !ok {
+			return
+		}
+
+		setErr := fs.Set(fl.Name, v)
+		if setErr != nil {
+			err = microerror.Maskf(invalidConfigError, "%s: %s", fl.Name, setErr)
+		}
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Secrets are not flags, they come from the environment or the file.
+	if file != nil {
+		f.AwsAccessKey = file.Storage.AWS.AccessKey
+		f.AwsSecretKey = file.Storage.AWS.SecretKey
+		f.EncryptPass = file.Encryption.Passphrase
+	}
+	ParseEnvs(f)
+
+	return nil
+}
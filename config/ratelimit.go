@@ -0,0 +1,66 @@
+package config
+
+import (
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// UploadRateLimitLabel on a guest cluster CR overrides the global
+	// upload rate limit for the cluster.
+	UploadRateLimitLabel = "etcd-backup.giantswarm.io/upload-rate-limit"
+	// SnapshotDiskReadRateLimitLabel on a guest cluster CR overrides the global
+	// limit of reading the local snapshot file for the cluster.
+	SnapshotDiskReadRateLimitLabel = "etcd-backup.giantswarm.io/snapshot-disk-read-rate-limit"
+)
+
+// RateLimits are bandwidth limits in bytes per second as Kubernetes
+// quantities, e.g. 20Mi. Empty values do not override, 0 disables a limit.
+type RateLimits struct {
+	Upload           string `json:"upload,omitempty"`
+	SnapshotDiskRead string `json:"snapshotDiskRead,omitempty"`
+}
+
+// Validate checks that both limits can be parsed.
+func (r RateLimits) Validate() error {
+	_, err := ParseRateLimit(r.Upload)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	_, err = ParseRateLimit(r.SnapshotDiskRead)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Override returns r with the non-empty limits of o.
+func (r RateLimits) Override(o RateLimits) RateLimits {
+	if o.Upload != "" {
+		r.Upload = o.Upload
+	}
+	if o.SnapshotDiskRead != "" {
+		r.SnapshotDiskRead = o.SnapshotDiskRead
+	}
+
+	return r
+}
+
+// ParseRateLimit returns the bytes per second of a quantity like 20Mi. Empty
+// and 0 mean no limit.
+func ParseRateLimit(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, microerror.Maskf(invalidConfigError, "rate limit %q: %s", s, err)
+	}
+	if q.Sign() < 0 {
+		return 0, microerror.Maskf(invalidConfigError, "rate limit %q must not be negative", s)
+	}
+
+	return q.Value(), nil
+}
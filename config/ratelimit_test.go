@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+)
+
+func Test_ParseRateLimit(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+		valid    bool
+	}{
+		{input: "", expected: 0, valid: true},
+		{input: "0", expected: 0, valid: true},
+		{input: "1000", expected: 1000, valid: true},
+		{input: "20Mi", expected: 20 << 20, valid: true},
+		{input: "1.5k", expected: 1500, valid: true},
+		{input: "-1Mi"},
+		{input: "fast"},
+	}
+
+	for _, tc := range testCases {
+		limit, err := ParseRateLimit(tc.input)
+		if tc.valid && err != nil {
+			t.Errorf("ParseRateLimit(%q): expected no error, got %#v", tc.input, err)
+		}
+		if !tc.valid && !IsInvalidConfig(err) {
+			t.Errorf("ParseRateLimit(%q): expected invalid config error, got %#v", tc.input, err)
+		}
+		if limit != tc.expected {
+			t.Errorf("ParseRateLimit(%q): expected %d, got %d", tc.input, tc.expected, limit)
+		}
+	}
+}
+
+func Test_RateLimits_Override(t *testing.T) {
+	global := RateLimits{Upload: "20Mi", SnapshotDiskRead: "50Mi"}
+
+	limits := global.Override(RateLimits{Upload: "0"})
+	expected := RateLimits{Upload: "0", SnapshotDiskRead: "50Mi"}
+	if limits != expected {
+		t.Fatalf("expected %#v, got %#v", expected, limits)
+	}
+}
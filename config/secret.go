@@ -0,0 +1,36 @@
+package config
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Secret is a sensitive setting given either as value or as file. Files are
+// read on every Get, so rotated mounted Kubernetes secrets are picked up
+// without a restart.
+type Secret struct {
+	Value string
+	File  string
+}
+
+// Get returns the secret value. Surrounding whitespace of file contents is
+// trimmed.
+func (s Secret) Get() (string, error) {
+	if s.File == "" {
+		return s.Value, nil
+	}
+
+	data, err := ioutil.ReadFile(s.File)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// IsSet returns whether a value or file is configured.
+func (s Secret) IsSet() bool {
+	return s.Value != "" || s.File != ""
+}
@@ -0,0 +1,46 @@
+package discovery
+
+import (
+	"github.com/giantswarm/apiextensions/pkg/clientset/versioned"
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type AWSConfig struct {
+	G8sClient versioned.Interface
+
+	CertSecret CertSecret
+	Namespaces []string
+}
+
+// AWS discovers guest clusters from AWSConfig CRs.
+type AWS struct {
+	*crdSource
+}
+
+func NewAWS(config AWSConfig) (*AWS, error) {
+	list := func(namespace string) ([]crdCluster, error) {
+		crList, err := config.G8sClient.ProviderV1alpha1().AWSConfigs(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		var crs []crdCluster
+		for _, awsConfig := range crList.Items {
+			crs = append(crs, crdCluster{
+				ObjectMeta:     awsConfig.ObjectMeta,
+				Endpoint:       AwsEtcdEndpoint(awsConfig.Spec.Cluster.Etcd.Domain),
+				ReleaseVersion: awsConfig.Spec.VersionBundle.Version,
+			})
+		}
+
+		return crs, nil
+	}
+
+	c, err := newCRDSource(crdConfig(config), "AWSConfig", list)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &AWS{crdSource: c}, nil
+}
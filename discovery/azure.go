@@ -0,0 +1,46 @@
+package discovery
+
+import (
+	"github.com/giantswarm/apiextensions/pkg/clientset/versioned"
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type AzureConfig struct {
+	G8sClient versioned.Interface
+
+	CertSecret CertSecret
+	Namespaces []string
+}
+
+// Azure discovers guest clusters from AzureConfig CRs.
+type Azure struct {
+	*crdSource
+}
+
+func NewAzure(config AzureConfig) (*Azure, error) {
+	list := func(namespace string) ([]crdCluster, error) {
+		crList, err := config.G8sClient.ProviderV1alpha1().AzureConfigs(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		var crs []crdCluster
+		for _, azureConfig := range crList.Items {
+			crs = append(crs, crdCluster{
+				ObjectMeta:     azureConfig.ObjectMeta,
+				Endpoint:       AzureEtcdEndpoint(azureConfig.Spec.Cluster.Etcd.Domain),
+				ReleaseVersion: azureConfig.Spec.VersionBundle.Version,
+			})
+		}
+
+		return crs, nil
+	}
+
+	c, err := newCRDSource(crdConfig(config), "AzureConfig", list)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &Azure{crdSource: c}, nil
+}
@@ -0,0 +1,89 @@
+package discovery
+
+import (
+	"github.com/giantswarm/apiextensions/pkg/clientset/versioned"
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// crdConfig is the configuration shared by AWSConfig, AzureConfig and
+// KVMConfig, which convert to it.
+type crdConfig struct {
+	G8sClient versioned.Interface
+
+	CertSecret CertSecret
+	Namespaces []string
+}
+
+// crdCluster is what a provider CR tells about its guest cluster.
+type crdCluster struct {
+	metav1.ObjectMeta
+	Endpoint       string
+	ReleaseVersion string
+}
+
+// crdSource discovers guest clusters from provider CRs. Only listing the CRs
+// of a namespace differs between providers.
+type crdSource struct {
+	certSecret CertSecret
+	kind       string
+	list       func(namespace string) ([]crdCluster, error)
+	namespaces []string
+}
+
+// newCRDSource validates config for the CRs of kind and returns the source
+// listing them with list.
+func newCRDSource(config crdConfig, kind string, list func(namespace string) ([]crdCluster, error)) (*crdSource, error) {
+	if config.G8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%s.G8sClient must not be empty", kind)
+	}
+	if len(config.Namespaces) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%s.Namespaces must not be empty", kind)
+	}
+	err := config.CertSecret.Validate()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	c := &crdSource{
+		certSecret: config.CertSecret,
+		kind:       kind,
+		list:       list,
+		namespaces: config.Namespaces,
+	}
+
+	return c, nil
+}
+
+func (c *crdSource) Clusters() ([]GuestCluster, error) {
+	var clusters []GuestCluster
+
+	for _, namespace := range c.namespaces {
+		crs, err := c.list(namespace)
+		if err != nil {
+			return nil, microerror.Maskf(err, "failed to list %s CRs in namespace %s", c.kind, namespace)
+		}
+
+		for _, cr := range crs {
+			// only backup cluster if it was not marked for delete
+			if cr.DeletionTimestamp != nil {
+				continue
+			}
+
+			cluster := GuestCluster{
+				ID:             cr.Name,
+				Endpoint:       cr.Endpoint,
+				ReleaseVersion: cr.ReleaseVersion,
+				Labels:         cr.Labels,
+			}
+			cluster.CertSecretRef, err = c.certSecret.Ref(cluster)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	return clusters, nil
+}
@@ -0,0 +1,248 @@
+package discovery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/apiextensions/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/apiextensions/pkg/clientset/versioned"
+	providerv1alpha1 "github.com/giantswarm/apiextensions/pkg/clientset/versioned/typed/provider/v1alpha1"
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/etcd-backup/config"
+)
+
+// fakeG8sClient serves provider CRs by namespace. Calling any other method
+// of the clientset panics.
+type fakeG8sClient struct {
+	versioned.Interface
+
+	aws   map[string][]v1alpha1.AWSConfig
+	azure map[string][]v1alpha1.AzureConfig
+	kvm   map[string][]v1alpha1.KVMConfig
+	err   error
+}
+
+func (c *fakeG8sClient) ProviderV1alpha1() providerv1alpha1.ProviderV1alpha1Interface {
+	return fakeProvider{c: c}
+}
+
+type fakeProvider struct {
+	providerv1alpha1.ProviderV1alpha1Interface
+	c *fakeG8sClient
+}
+
+func (p fakeProvider) AWSConfigs(namespace string) providerv1alpha1.AWSConfigInterface {
+	return fakeAWSConfigs{items: p.c.aws[namespace], err: p.c.err}
+}
+
+func (p fakeProvider) AzureConfigs(namespace string) providerv1alpha1.AzureConfigInterface {
+	return fakeAzureConfigs{items: p.c.azure[namespace], err: p.c.err}
+}
+
+func (p fakeProvider) KVMConfigs(namespace string) providerv1alpha1.KVMConfigInterface {
+	return fakeKVMConfigs{items: p.c.kvm[namespace], err: p.c.err}
+}
+
+type fakeAWSConfigs struct {
+	providerv1alpha1.AWSConfigInterface
+	items []v1alpha1.AWSConfig
+	err   error
+}
+
+func (f fakeAWSConfigs) List(opts metav1.ListOptions) (*v1alpha1.AWSConfigList, error) {
+	return &v1alpha1.AWSConfigList{Items: f.items}, f.err
+}
+
+type fakeAzureConfigs struct {
+	providerv1alpha1.AzureConfigInterface
+	items []v1alpha1.AzureConfig
+	err   error
+}
+
+func (f fakeAzureConfigs) List(opts metav1.ListOptions) (*v1alpha1.AzureConfigList, error) {
+	return &v1alpha1.AzureConfigList{Items: f.items}, f.err
+}
+
+type fakeKVMConfigs struct {
+	providerv1alpha1.KVMConfigInterface
+	items []v1alpha1.KVMConfig
+	err   error
+}
+
+func (f fakeKVMConfigs) List(opts metav1.ListOptions) (*v1alpha1.KVMConfigList, error) {
+	return &v1alpha1.KVMConfigList{Items: f.items}, f.err
+}
+
+func testCertSecret() CertSecret {
+	return CertSecret{
+		Namespace:    config.DefaultNamespace,
+		NameTemplate: config.DefaultSecretNameTemplate,
+		Keys:         CertKeys{CA: "ca", Crt: "crt", Key: "key"},
+	}
+}
+
+func testMeta(name string, deleted bool) metav1.ObjectMeta {
+	m := metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{"owner": name},
+	}
+	if deleted {
+		now := metav1.Now()
+		m.DeletionTimestamp = &now
+	}
+
+	return m
+}
+
+func testCluster(name string, endpoint string, version string) GuestCluster {
+	keys := testCertSecret().Keys
+	return GuestCluster{
+		ID:             name,
+		Endpoint:       endpoint,
+		ReleaseVersion: version,
+		Labels:         map[string]string{"owner": name},
+		CertSecretRef: SecretRef{
+			Namespace: config.DefaultNamespace,
+			Name:      name + "-etcd",
+			Keys:      &keys,
+		},
+	}
+}
+
+func Test_CRDSources_Clusters(t *testing.T) {
+	awsConfig := func(name string, deleted bool) v1alpha1.AWSConfig {
+		c := v1alpha1.AWSConfig{ObjectMeta: testMeta(name, deleted)}
+		c.Spec.Cluster.Etcd.Domain = "etcd." + name + ".example.com"
+		c.Spec.VersionBundle.Version = "8.5.0"
+		return c
+	}
+	azureConfig := func(name string, deleted bool) v1alpha1.AzureConfig {
+		c := v1alpha1.AzureConfig{ObjectMeta: testMeta(name, deleted)}
+		c.Spec.Cluster.Etcd.Domain = "etcd." + name + ".example.com"
+		c.Spec.VersionBundle.Version = "2.0.0"
+		return c
+	}
+	kvmConfig := func(name string, deleted bool) v1alpha1.KVMConfig {
+		c := v1alpha1.KVMConfig{ObjectMeta: testMeta(name, deleted)}
+		c.Spec.Cluster.Etcd.Domain = "etcd." + name + ".example.com"
+		c.Spec.VersionBundle.Version = "3.1.0"
+		return c
+	}
+
+	client := &fakeG8sClient{
+		aws: map[string][]v1alpha1.AWSConfig{
+			"default": {awsConfig("abc12", false), awsConfig("gone1", true)},
+			"other":   {awsConfig("def34", false)},
+		},
+		azure: map[string][]v1alpha1.AzureConfig{
+			"default": {azureConfig("az123", false)},
+		},
+		kvm: map[string][]v1alpha1.KVMConfig{
+			"default": {kvmConfig("kvm12", false), kvmConfig("gone2", true)},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		source   func(c crdConfig) (ClusterSource, error)
+		expected []GuestCluster
+	}{
+		{
+			name: "case 0: aws lists all namespaces and skips deleted clusters",
+			source: func(c crdConfig) (ClusterSource, error) {
+				return NewAWS(AWSConfig(c))
+			},
+			expected: []GuestCluster{
+				testCluster("abc12", "https://etcd.abc12.example.com:2379", "8.5.0"),
+				testCluster("def34", "https://etcd.def34.example.com:2379", "8.5.0"),
+			},
+		},
+		{
+			name: "case 1: azure",
+			source: func(c crdConfig) (ClusterSource, error) {
+				return NewAzure(AzureConfig(c))
+			},
+			expected: []GuestCluster{
+				testCluster("az123", "https://etcd.az123.example.com:2379", "2.0.0"),
+			},
+		},
+		{
+			name: "case 2: kvm serves etcd on port 443",
+			source: func(c crdConfig) (ClusterSource, error) {
+				return NewKVM(KVMConfig(c))
+			},
+			expected: []GuestCluster{
+				testCluster("kvm12", "https://etcd.kvm12.example.com:443", "3.1.0"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := crdConfig{
+				G8sClient:  client,
+				CertSecret: testCertSecret(),
+				Namespaces: []string{"default", "other"},
+			}
+			source, err := tc.source(c)
+			if err != nil {
+				t.Fatalf("expected no error, got %#v", err)
+			}
+
+			clusters, err := source.Clusters()
+			if err != nil {
+				t.Fatalf("expected no error, got %#v", err)
+			}
+			if !reflect.DeepEqual(clusters, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, clusters)
+			}
+		})
+	}
+}
+
+func Test_CRDSources_ListError(t *testing.T) {
+	listErr := errors.New("forbidden")
+	client := &fakeG8sClient{err: listErr}
+
+	source, err := NewKVM(KVMConfig{G8sClient: client, CertSecret: testCertSecret(), Namespaces: []string{"default"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+
+	_, err = source.Clusters()
+	if microerror.Cause(err) != listErr {
+		t.Fatalf("expected cause %#v, got %#v", listErr, err)
+	}
+}
+
+func Test_CRDSources_InvalidConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config AWSConfig
+	}{
+		{
+			name:   "case 0: missing client",
+			config: AWSConfig{CertSecret: testCertSecret(), Namespaces: []string{"default"}},
+		},
+		{
+			name:   "case 1: missing namespaces",
+			config: AWSConfig{G8sClient: &fakeG8sClient{}, CertSecret: testCertSecret()},
+		},
+		{
+			name:   "case 2: invalid cert secret",
+			config: AWSConfig{G8sClient: &fakeG8sClient{}, CertSecret: CertSecret{Namespace: "default"}, Namespaces: []string{"default"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewAWS(tc.config)
+			if !IsInvalidConfig(err) {
+				t.Fatalf("expected invalid config error, got %#v", err)
+			}
+		})
+	}
+}
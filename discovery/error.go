@@ -0,0 +1,17 @@
+package discovery
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidSourceFileError = microerror.New("invalid source file")
+
+// IsInvalidSourceFile asserts invalidSourceFileError.
+func IsInvalidSourceFile(err error) bool {
+	return microerror.Cause(err) == invalidSourceFileError
+}
@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/etcd-backup/config"
+)
+
+// Inventory lists standalone etcd clusters which are not managed as guest
+// clusters, e.g.
+//
+//	targets:
+//	- name: vault
+//	  endpoints:
+//	  - https://10.0.1.10:2379
+//	  - https://10.0.1.11:2379
+//	  caFile: /etc/vault-etcd/ca.pem
+//	  certFile: /etc/vault-etcd/crt.pem
+//	  keyFile: /etc/vault-etcd/key.pem
+//	  prefix: vault-prod
+//	- name: calico
+//	  endpoints:
+//	  - https://calico-etcd.kube-system:2379
+//	  certSecretRef:
+//	    namespace: kube-system
+//	    name: calico-etcd-certs
+//	    keys:
+//	      ca: ca.crt
+//	      crt: tls.crt
+//	      key: tls.key
+//	  encryption:
+//	    passphraseEnv: CALICO_BACKUP_PASSPHRASE
+//	  rateLimits:
+//	    upload: 20Mi
+type Inventory struct {
+	Targets []Target `json:"targets"`
+}
+
+// Target is a single etcd cluster listed in the inventory.
+type Target struct {
+	Name      string   `json:"name"`
+	Endpoints []string `json:"endpoints"`
+
+	// Client certificates are either read from files or from a secret.
+	CAFile        string     `json:"caFile,omitempty"`
+	CertFile      string     `json:"certFile,omitempty"`
+	KeyFile       string     `json:"keyFile,omitempty"`
+	CertSecretRef *SecretRef `json:"certSecretRef,omitempty"`
+
+	// Prefix used in backup filenames. Defaults to the target name.
+	Prefix     string           `json:"prefix,omitempty"`
+	Encryption TargetEncryption `json:"encryption,omitempty"`
+	// RateLimits override the global rate limits for this target.
+	RateLimits config.RateLimits `json:"rateLimits,omitempty"`
+}
+
+// TargetEncryption overrides the global encryption settings for a target.
+type TargetEncryption struct {
+	// Disabled uploads backups of this target unencrypted.
+	Disabled bool `json:"disabled,omitempty"`
+	// PassphraseFile is read on every backup of this target and takes
+	// precedence over PassphraseEnv. The global passphrase is used when
+	// both are empty.
+	PassphraseFile string `json:"passphraseFile,omitempty"`
+	// PassphraseEnv names the environment variable holding the passphrase
+	// for this target.
+	PassphraseEnv string `json:"passphraseEnv,omitempty"`
+}
+
+// EndpointList returns the endpoints in the comma separated form etcdctl
+// expects.
+func (t Target) EndpointList() string {
+	return strings.Join(t.Endpoints, ",")
+}
+
+// BackupPrefix returns the prefix used in the target backup filenames.
+func (t Target) BackupPrefix() string {
+	if t.Prefix != "" {
+		return t.Prefix
+	}
+	return t.Name
+}
+
+// LoadInventory reads and validates the inventory file at path.
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var inventory Inventory
+	err = yaml.Unmarshal(data, &inventory)
+	if err != nil {
+		return nil, microerror.Maskf(invalidSourceFileError, "%s: %s", path, err)
+	}
+
+	names := map[string]bool{}
+	for i, t := range inventory.Targets {
+		if t.Name == "" {
+			return nil, microerror.Maskf(invalidSourceFileError, "%s: targets[%d].name must not be empty", path, i)
+		}
+		if names[t.Name] {
+			return nil, microerror.Maskf(invalidSourceFileError, "%s: target %q is listed twice", path, t.Name)
+		}
+		names[t.Name] = true
+
+		if len(t.Endpoints) == 0 {
+			return nil, microerror.Maskf(invalidSourceFileError, "%s: target %q must have endpoints", path, t.Name)
+		}
+		if t.CertSecretRef != nil && (t.CAFile != "" || t.CertFile != "" || t.KeyFile != "") {
+			return nil, microerror.Maskf(invalidSourceFileError, "%s: target %q must not set both certSecretRef and cert files", path, t.Name)
+		}
+		if t.CertSecretRef != nil && (t.CertSecretRef.Namespace == "" || t.CertSecretRef.Name == "") {
+			return nil, microerror.Maskf(invalidSourceFileError, "%s: target %q certSecretRef needs namespace and name", path, t.Name)
+		}
+		err = t.RateLimits.Validate()
+		if err != nil {
+			return nil, microerror.Maskf(invalidSourceFileError, "%s: target %q: %s", path, t.Name, err)
+		}
+	}
+
+	return &inventory, nil
+}
@@ -0,0 +1,13 @@
+package discovery
+
+import "fmt"
+
+func AwsEtcdEndpoint(etcdDomain string) string {
+	return fmt.Sprintf("https://%s:2379", etcdDomain)
+}
+func AzureEtcdEndpoint(etcdDomain string) string {
+	return fmt.Sprintf("https://%s:2379", etcdDomain)
+}
+func KVMEtcdEndpoint(etcdDomain string) string {
+	return fmt.Sprintf("https://%s:443", etcdDomain)
+}
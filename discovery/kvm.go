@@ -0,0 +1,46 @@
+package discovery
+
+import (
+	"github.com/giantswarm/apiextensions/pkg/clientset/versioned"
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type KVMConfig struct {
+	G8sClient versioned.Interface
+
+	CertSecret CertSecret
+	Namespaces []string
+}
+
+// KVM discovers guest clusters from KVMConfig CRs.
+type KVM struct {
+	*crdSource
+}
+
+func NewKVM(config KVMConfig) (*KVM, error) {
+	list := func(namespace string) ([]crdCluster, error) {
+		crList, err := config.G8sClient.ProviderV1alpha1().KVMConfigs(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		var crs []crdCluster
+		for _, kvmConfig := range crList.Items {
+			crs = append(crs, crdCluster{
+				ObjectMeta:     kvmConfig.ObjectMeta,
+				Endpoint:       KVMEtcdEndpoint(kvmConfig.Spec.Cluster.Etcd.Domain),
+				ReleaseVersion: kvmConfig.Spec.VersionBundle.Version,
+			})
+		}
+
+		return crs, nil
+	}
+
+	c, err := newCRDSource(crdConfig(config), "KVMConfig", list)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &KVM{crdSource: c}, nil
+}
@@ -0,0 +1,163 @@
+package discovery
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/giantswarm/microerror"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultChannelLabel is the guest cluster label holding its release channel.
+const DefaultChannelLabel = "release.giantswarm.io/channel"
+
+// VersionPolicy decides which guest clusters have a release supporting etcd
+// backups, e.g.
+//
+//	channelLabel: release.giantswarm.io/channel
+//	providers:
+//	  aws:
+//	    minVersion: 3.1.1
+//	    channels:
+//	      alpha: 3.0.0
+//	  azure:
+//	    minVersion: 0.2.0
+//	allow:
+//	- abc12
+//	deny:
+//	- xyz99
+type VersionPolicy struct {
+	// Allow and Deny override the version check for single cluster IDs.
+	// Deny takes precedence.
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	// AllowUnknownVersion backs up clusters without release version instead
+	// of skipping them.
+	AllowUnknownVersion bool   `json:"allowUnknownVersion,omitempty"`
+	ChannelLabel        string `json:"channelLabel,omitempty"`
+	// Providers without entry have no minimum version.
+	Providers map[string]ProviderVersionPolicy `json:"providers,omitempty"`
+}
+
+// ProviderVersionPolicy is the minimum release version of a provider,
+// optionally overridden per release channel.
+type ProviderVersionPolicy struct {
+	MinVersion string            `json:"minVersion,omitempty"`
+	Channels   map[string]string `json:"channels,omitempty"`
+}
+
+// DefaultVersionPolicy returns the first releases supporting guest cluster
+// etcd backups.
+func DefaultVersionPolicy() VersionPolicy {
+	return VersionPolicy{
+		ChannelLabel: DefaultChannelLabel,
+		Providers: map[string]ProviderVersionPolicy{
+			"aws": {
+				MinVersion: "3.1.1",
+			},
+			"azure": {
+				MinVersion: "0.2.0",
+			},
+		},
+	}
+}
+
+// LoadVersionPolicy reads and validates the version policy file at path.
+func LoadVersionPolicy(path string) (VersionPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return VersionPolicy{}, microerror.Mask(err)
+	}
+
+	var p VersionPolicy
+	err = yaml.UnmarshalStrict(data, &p)
+	if err != nil {
+		return VersionPolicy{}, microerror.Maskf(invalidSourceFileError, "%s: %s", path, err)
+	}
+	if p.ChannelLabel == "" {
+		p.ChannelLabel = DefaultChannelLabel
+	}
+
+	err = p.Validate()
+	if err != nil {
+		return VersionPolicy{}, microerror.Maskf(invalidSourceFileError, "%s: %s", path, err)
+	}
+
+	return p, nil
+}
+
+// Validate checks that all versions in the policy are valid semver.
+func (p VersionPolicy) Validate() error {
+	for provider, pp := range p.Providers {
+		if pp.MinVersion != "" {
+			_, err := semver.NewVersion(pp.MinVersion)
+			if err != nil {
+				return microerror.Maskf(invalidConfigError, "providers.%s.minVersion: %s", provider, err)
+			}
+		}
+		for channel, v := range pp.Channels {
+			_, err := semver.NewVersion(v)
+			if err != nil {
+				return microerror.Maskf(invalidConfigError, "providers.%s.channels.%s: %s", provider, channel, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Supported returns whether the cluster should be backed up and, if not, why.
+func (p VersionPolicy) Supported(provider string, cluster GuestCluster) (bool, string, error) {
+	if contains(p.Deny, cluster.ID) {
+		return false, "cluster is denied by version policy", nil
+	}
+	if contains(p.Allow, cluster.ID) {
+		return true, "", nil
+	}
+
+	pp, ok := p.Providers[provider]
+	if !ok {
+		return true, "", nil
+	}
+
+	minVersion := pp.MinVersion
+	channel := cluster.Labels[p.ChannelLabel]
+	if v, ok := pp.Channels[channel]; ok && channel != "" {
+		minVersion = v
+	}
+	if minVersion == "" {
+		return true, "", nil
+	}
+
+	if cluster.ReleaseVersion == "" {
+		if p.AllowUnknownVersion {
+			return true, "", nil
+		}
+		return false, "cluster has no release version", nil
+	}
+
+	version, err := semver.NewVersion(cluster.ReleaseVersion)
+	if err != nil {
+		return false, "", microerror.Maskf(err, "invalid release version %q for cluster %s", cluster.ReleaseVersion, cluster.ID)
+	}
+	min, err := semver.NewVersion(minVersion)
+	if err != nil {
+		return false, "", microerror.Maskf(invalidConfigError, "invalid minimum version %q for provider %s", minVersion, provider)
+	}
+
+	if version.LessThan(*min) {
+		return false, fmt.Sprintf("release version %s is older than %s", version, min), nil
+	}
+
+	return true, "", nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
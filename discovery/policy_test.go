@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_LoadVersionPolicy(t *testing.T) {
+	testCases := []struct {
+		name         string
+		file         string
+		errorMatcher func(error) bool
+	}{
+		{
+			name: "case 0: valid policy",
+			file: `
+providers:
+  aws:
+    minVersion: 3.1.1
+    channels:
+      alpha: 3.0.0
+deny:
+- xyz99
+`,
+			errorMatcher: nil,
+		},
+		{
+			name: "case 1: misspelled field",
+			file: `
+providers:
+  aws:
+    minVerison: 3.1.1
+`,
+			errorMatcher: IsInvalidSourceFile,
+		},
+		{
+			name: "case 2: unknown top level field",
+			file: `
+alow:
+- abc12
+`,
+			errorMatcher: IsInvalidSourceFile,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "etcd-backup-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, string(rune('a'+i))+".yaml")
+			err := ioutil.WriteFile(path, []byte(tc.file), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := LoadVersionPolicy(path)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if err == nil && p.ChannelLabel != DefaultChannelLabel {
+				t.Fatalf("expected the default channel label, got %q", p.ChannelLabel)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// CertKeysTLS selects the data keys of kubernetes.io/tls secrets.
+	CertKeysTLS = "tls"
+)
+
+// CertKeys are the data keys of the CA, client certificate and private key in
+// a certificate secret.
+type CertKeys struct {
+	CA  string `json:"ca"`
+	Crt string `json:"crt"`
+	Key string `json:"key"`
+}
+
+// ParseCertKeys parses data keys in the form "ca,crt,key". The value "tls"
+// selects the keys of kubernetes.io/tls secrets.
+func ParseCertKeys(s string) (CertKeys, error) {
+	if s == CertKeysTLS {
+		s = "ca.crt,tls.crt,tls.key"
+	}
+
+	keys := strings.Split(s, ",")
+	if len(keys) != 3 {
+		return CertKeys{}, microerror.Maskf(invalidConfigError, "cert keys %q must be in the form ca,crt,key or %q", s, CertKeysTLS)
+	}
+	for _, k := range keys {
+		if strings.TrimSpace(k) == "" {
+			return CertKeys{}, microerror.Maskf(invalidConfigError, "cert keys %q must not contain empty keys", s)
+		}
+	}
+
+	c := CertKeys{
+		CA:  strings.TrimSpace(keys[0]),
+		Crt: strings.TrimSpace(keys[1]),
+		Key: strings.TrimSpace(keys[2]),
+	}
+
+	return c, nil
+}
+
+// CertSecret configures where the etcd client certificates of guest clusters
+// are stored.
+type CertSecret struct {
+	Namespace string
+	// NameTemplate is a text/template executed with the GuestCluster, e.g.
+	// "{{.ID}}-etcd".
+	NameTemplate string
+	Keys         CertKeys
+}
+
+// Validate checks that all fields are set and the name template parses.
+func (c CertSecret) Validate() error {
+	if c.Namespace == "" {
+		return microerror.Maskf(invalidConfigError, "%T.Namespace must not be empty", c)
+	}
+	if c.NameTemplate == "" {
+		return microerror.Maskf(invalidConfigError, "%T.NameTemplate must not be empty", c)
+	}
+	if c.Keys.CA == "" || c.Keys.Crt == "" || c.Keys.Key == "" {
+		return microerror.Maskf(invalidConfigError, "%T.Keys must not be empty", c)
+	}
+
+	_, err := template.New("secret").Parse(c.NameTemplate)
+	if err != nil {
+		return microerror.Maskf(invalidConfigError, "%T.NameTemplate: %s", c, err)
+	}
+
+	return nil
+}
+
+// Ref returns the reference of the secret holding the etcd client
+// certificates of the given guest cluster.
+func (c CertSecret) Ref(cluster GuestCluster) (SecretRef, error) {
+	t, err := template.New("secret").Option("missingkey=error").Parse(c.NameTemplate)
+	if err != nil {
+		return SecretRef{}, microerror.Maskf(invalidConfigError, "secret name template: %s", err)
+	}
+
+	var name bytes.Buffer
+	err = t.Execute(&name, cluster)
+	if err != nil {
+		return SecretRef{}, microerror.Maskf(invalidConfigError, "secret name template for cluster %s: %s", cluster.ID, err)
+	}
+
+	ref := SecretRef{
+		Namespace: c.Namespace,
+		Name:      name.String(),
+		Keys:      &c.Keys,
+	}
+
+	return ref, nil
+}
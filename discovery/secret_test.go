@@ -0,0 +1,157 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/etcd-backup/config"
+)
+
+func Test_ParseCertKeys(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        string
+		expected     CertKeys
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: default keys",
+			input:    config.DefaultCertKeys,
+			expected: CertKeys{CA: "ca", Crt: "crt", Key: "key"},
+		},
+		{
+			name:     "case 1: kubernetes.io/tls keys",
+			input:    CertKeysTLS,
+			expected: CertKeys{CA: "ca.crt", Crt: "tls.crt", Key: "tls.key"},
+		},
+		{
+			name:     "case 2: spaces are trimmed",
+			input:    " a , b ,c ",
+			expected: CertKeys{CA: "a", Crt: "b", Key: "c"},
+		},
+		{
+			name:         "case 3: too few keys",
+			input:        "ca,crt",
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name:         "case 4: empty key",
+			input:        "ca,,key",
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keys, err := ParseCertKeys(tc.input)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if keys != tc.expected {
+				t.Fatalf("expected %#v, got %#v", tc.expected, keys)
+			}
+		})
+	}
+}
+
+func Test_CertSecret_Ref(t *testing.T) {
+	keys := testCertSecret().Keys
+
+	testCases := []struct {
+		name         string
+		template     string
+		cluster      GuestCluster
+		expected     SecretRef
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: default template",
+			template: config.DefaultSecretNameTemplate,
+			cluster:  GuestCluster{ID: "abc12"},
+			expected: SecretRef{Namespace: config.DefaultNamespace, Name: "abc12-etcd", Keys: &keys},
+		},
+		{
+			name:     "case 1: template using labels",
+			template: `{{index .Labels "owner"}}-{{.ID}}`,
+			cluster:  GuestCluster{ID: "abc12", Labels: map[string]string{"owner": "acme"}},
+			expected: SecretRef{Namespace: config.DefaultNamespace, Name: "acme-abc12", Keys: &keys},
+		},
+		{
+			name:         "case 2: unknown field",
+			template:     "{{.Name}}-etcd",
+			cluster:      GuestCluster{ID: "abc12"},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := testCertSecret()
+			c.NameTemplate = tc.template
+
+			ref, err := c.Ref(tc.cluster)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && !reflect.DeepEqual(ref, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, ref)
+			}
+		})
+	}
+}
+
+func Test_CertSecret_Validate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		secret CertSecret
+		valid  bool
+	}{
+		{
+			name:   "case 0: valid",
+			secret: testCertSecret(),
+			valid:  true,
+		},
+		{
+			name:   "case 1: missing namespace",
+			secret: CertSecret{NameTemplate: config.DefaultSecretNameTemplate, Keys: testCertSecret().Keys},
+		},
+		{
+			name:   "case 2: missing keys",
+			secret: CertSecret{Namespace: config.DefaultNamespace, NameTemplate: config.DefaultSecretNameTemplate},
+		},
+		{
+			name:   "case 3: template does not parse",
+			secret: CertSecret{Namespace: config.DefaultNamespace, NameTemplate: "{{.ID", Keys: testCertSecret().Keys},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.secret.Validate()
+			if tc.valid && err != nil {
+				t.Fatalf("expected no error, got %#v", err)
+			}
+			if !tc.valid && !IsInvalidConfig(err) {
+				t.Fatalf("expected invalid config error, got %#v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"io/ioutil"
+
+	"github.com/giantswarm/microerror"
+	"sigs.k8s.io/yaml"
+)
+
+type StaticConfig struct {
+	// CertSecret is used for clusters without certSecretRef.
+	CertSecret CertSecret
+	// Path is the YAML or JSON file listing the guest clusters.
+	Path string
+}
+
+// Static discovers guest clusters from a file, e.g.
+//
+//	clusters:
+//	- id: abc12
+//	  endpoint: https://etcd.abc12.example.com:2379
+//	  certSecretRef:
+//	    namespace: default
+//	    name: abc12-etcd
+//	  releaseVersion: 8.5.0
+type Static struct {
+	certSecret CertSecret
+	path       string
+}
+
+type staticFile struct {
+	Clusters []GuestCluster `json:"clusters"`
+}
+
+func NewStatic(config StaticConfig) (*Static, error) {
+	if config.Path == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Path must not be empty", config)
+	}
+	err := config.CertSecret.Validate()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	s := &Static{
+		certSecret: config.CertSecret,
+		path:       config.Path,
+	}
+
+	return s, nil
+}
+
+// Clusters reads the file on every call, so changes are picked up without a
+// restart.
+func (s *Static) Clusters() ([]GuestCluster, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var f staticFile
+	err = yaml.Unmarshal(data, &f)
+	if err != nil {
+		return nil, microerror.Maskf(invalidSourceFileError, "%s: %s", s.path, err)
+	}
+
+	for i, c := range f.Clusters {
+		if c.ID == "" {
+			return nil, microerror.Maskf(invalidSourceFileError, "%s: clusters[%d].id must not be empty", s.path, i)
+		}
+		if c.Endpoint == "" {
+			return nil, microerror.Maskf(invalidSourceFileError, "%s: clusters[%d].endpoint must not be empty", s.path, i)
+		}
+
+		ref, err := s.certSecret.Ref(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		if c.CertSecretRef.Name == "" {
+			f.Clusters[i].CertSecretRef.Name = ref.Name
+		}
+		if c.CertSecretRef.Namespace == "" {
+			f.Clusters[i].CertSecretRef.Namespace = ref.Namespace
+		}
+		if c.CertSecretRef.Keys == nil {
+			f.Clusters[i].CertSecretRef.Keys = ref.Keys
+		}
+	}
+
+	return f.Clusters, nil
+}
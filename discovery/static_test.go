@@ -0,0 +1,117 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/etcd-backup/config"
+)
+
+func Test_Static_Clusters(t *testing.T) {
+	keys := testCertSecret().Keys
+	tlsKeys := CertKeys{CA: "ca.crt", Crt: "tls.crt", Key: "tls.key"}
+
+	testCases := []struct {
+		name         string
+		file         string
+		expected     []GuestCluster
+		errorMatcher func(error) bool
+	}{
+		{
+			name: "case 0: defaults fill the secret reference",
+			file: `
+clusters:
+- id: abc12
+  endpoint: https://etcd.abc12.example.com:2379
+  releaseVersion: 8.5.0
+`,
+			expected: []GuestCluster{
+				{
+					ID:             "abc12",
+					Endpoint:       "https://etcd.abc12.example.com:2379",
+					ReleaseVersion: "8.5.0",
+					CertSecretRef:  SecretRef{Namespace: config.DefaultNamespace, Name: "abc12-etcd", Keys: &keys},
+				},
+			},
+		},
+		{
+			name: "case 1: the secret reference of a cluster wins, json works too",
+			file: `{"clusters": [{"id": "def34", "endpoint": "https://10.0.0.1:2379", "certSecretRef": {"namespace": "certs", "name": "def34-tls", "keys": {"ca": "ca.crt", "crt": "tls.crt", "key": "tls.key"}}}]}`,
+			expected: []GuestCluster{
+				{
+					ID:            "def34",
+					Endpoint:      "https://10.0.0.1:2379",
+					CertSecretRef: SecretRef{Namespace: "certs", Name: "def34-tls", Keys: &tlsKeys},
+				},
+			},
+		},
+		{
+			name: "case 2: missing id",
+			file: `
+clusters:
+- endpoint: https://etcd.abc12.example.com:2379
+`,
+			errorMatcher: IsInvalidSourceFile,
+		},
+		{
+			name: "case 3: missing endpoint",
+			file: `
+clusters:
+- id: abc12
+`,
+			errorMatcher: IsInvalidSourceFile,
+		},
+		{
+			name:         "case 4: no yaml",
+			file:         "clusters: [",
+			errorMatcher: IsInvalidSourceFile,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "etcd-backup-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, string(rune('a'+i))+".yaml")
+			err := ioutil.WriteFile(path, []byte(tc.file), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			source, err := NewStatic(StaticConfig{CertSecret: testCertSecret(), Path: path})
+			if err != nil {
+				t.Fatalf("expected no error, got %#v", err)
+			}
+			clusters, err := source.Clusters()
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !reflect.DeepEqual(clusters, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, clusters)
+			}
+		})
+	}
+}
+
+func Test_Static_InvalidConfig(t *testing.T) {
+	_, err := NewStatic(StaticConfig{CertSecret: testCertSecret()})
+	if !IsInvalidConfig(err) {
+		t.Fatalf("expected invalid config error, got %#v", err)
+	}
+}
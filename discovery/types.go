@@ -0,0 +1,35 @@
+package discovery
+
+import "github.com/giantswarm/etcd-backup/config"
+
+// ClusterSource lists guest clusters which should be backed up. There is one
+// implementation per provider CR type and one reading a static file, so the
+// backup loop does not need to know where a cluster came from.
+type ClusterSource interface {
+	Clusters() ([]GuestCluster, error)
+}
+
+// GuestCluster holds everything needed to back up a single guest cluster etcd.
+type GuestCluster struct {
+	ID             string            `json:"id"`
+	Endpoint       string            `json:"endpoint"`
+	CertSecretRef  SecretRef         `json:"certSecretRef"`
+	ReleaseVersion string            `json:"releaseVersion"`
+	Labels         map[string]string `json:"labels,omitempty"`
+}
+
+// SecretRef points to the secret holding etcd client certificates.
+type SecretRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Keys overrides the default data keys of the secret.
+	Keys *CertKeys `json:"keys,omitempty"`
+}
+
+// RateLimits returns the rate limits set by labels on the cluster.
+func (c GuestCluster) RateLimits() config.RateLimits {
+	return config.RateLimits{
+		Upload:           c.Labels[config.UploadRateLimitLabel],
+		SnapshotDiskRead: c.Labels[config.SnapshotDiskReadRateLimitLabel],
+	}
+}
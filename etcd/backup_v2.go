@@ -1,22 +1,30 @@
 package etcd
 
 import (
+	"io"
 	"path/filepath"
 
-	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/storage"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	"github.com/mholt/archiver"
 )
 
 type EtcdBackupV2 struct {
-	Aws      config.AWSConfig
-	Datadir  string
-	EncPass  string
-	Filename string
-	Logger   micrologger.Logger
-	Prefix   string
-	TmpDir   string
+	Compression Compression
+	Datadir     string
+	EncPass     string
+	Filename    string
+	Logger      micrologger.Logger
+	Prefix      string
+	TmpDir      string
+	Uploader    storage.Uploader
+
+	// SnapshotDiskReadRateLimit and UploadRateLimit are in bytes per second, 0
+	// means unlimited. SnapshotDiskReadRateLimit only limits reading the
+	// local copy into the pipeline, not the transfer from etcd.
+	SnapshotDiskReadRateLimit int64
+	UploadRateLimit           int64
 }
 
 // Create etcd in temporary directory, tar and compress.
@@ -24,9 +32,6 @@ func (b *EtcdBackupV2) Create() error {
 	// Filename
 	b.Filename = b.Prefix + "-etcd-etcd-v2-" + getTimeStamp()
 
-	// Full path to file.
-	fpath := filepath.Join(b.TmpDir, b.Filename)
-
 	// Create a etcd.
 	etcdctlEnvs := []string{}
 	etcdctlArgs := []string{
@@ -40,53 +45,48 @@ func (b *EtcdBackupV2) Create() error {
 		return microerror.Mask(err)
 	}
 
-	// Create tar.gz.
-	err = archiver.TarGz.Make(fpath+tgzExt, []string{fpath})
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	// Update Filename in etcd object.
-	b.Filename = b.Filename + tgzExt
-
 	b.Logger.Log("level", "info", "msg", "Etcd v2 etcd created successfully")
 	return nil
 }
 
-func (b *EtcdBackupV2) Encrypt() error {
-	if b.EncPass == "" {
-		b.Logger.Log("level", "warning", "msg", "No passphrase provided. Skipping etcd v2 backup encryption")
-		return nil
-	}
-
-	// Full path to file.
+// Upload tars, compresses, encrypts and uploads the backup directory in one
+// pipeline.
+func (b *EtcdBackupV2) Upload() (storage.Result, []StageStats, error) {
+	// Full path to backup directory.
 	fpath := filepath.Join(b.TmpDir, b.Filename)
 
-	// Encrypt etcd.
-	err := encryptFile(fpath, fpath+encExt, b.EncPass)
-	if err != nil {
-		return microerror.Mask(err)
-	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archiver.Tar.Write(pw, []string{fpath}))
+	}()
+	defer pr.Close()
 
-	// Update Filename in etcd object.
-	b.Filename = b.Filename + encExt
+	c := pipelineConfig{
+		Logger: b.Logger,
 
-	b.Logger.Log("level", "info", "msg", "Etcd v2 backup encrypted successfully")
-	return nil
-}
+		Compression: b.Compression,
+		EncPass:     b.EncPass,
+		Uploader:    b.Uploader,
 
-// Upload resulted etcd to S3.
-func (b *EtcdBackupV2) Upload() (int64, error) {
-	fpath := filepath.Join(b.TmpDir, b.Filename)
+		ReadRateLimit:   b.SnapshotDiskReadRateLimit,
+		UploadRateLimit: b.UploadRateLimit,
 
-	// Upload
-	size, err := uploadToS3(fpath, b.Aws, b.Logger)
+		Name:   b.Filename + tarExt,
+		Source: pr,
+
+		VerifyReplication: true,
+	}
+
+	name, result, stats, err := runPipeline(c)
 	if err != nil {
-		return -1, microerror.Mask(err)
+		return result, stats, microerror.Mask(err)
 	}
 
+	// Update Filename in etcd object.
+	b.Filename = name
+
 	b.Logger.Log("level", "info", "msg", "Etcd v2 backup uploaded successfully")
-	return size, nil
+	return result, stats, nil
 }
 
 func (b *EtcdBackupV2) Version() string {
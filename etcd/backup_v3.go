@@ -1,109 +1,262 @@
 package etcd
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/storage"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
-	"github.com/mholt/archiver"
 )
 
+// v3NameInfix separates the prefix from the time in v3 backup names.
+const v3NameInfix = "-backup-etcd-v3-"
+
 type EtcdBackupV3 struct {
-	Aws       config.AWSConfig
-	CACert    string
-	Cert      string
-	EncPass   string
-	Endpoints string
-	Filename  string
-	Logger    micrologger.Logger
-	Key       string
-	Prefix    string
-	TmpDir    string
+	CACert      string
+	Cert        string
+	Compression Compression
+	EncPass     string
+	Endpoints   string
+	Filename    string
+	Logger      micrologger.Logger
+	Key         string
+	Prefix      string
+	TmpDir      string
+	Uploader    storage.Uploader
+
+	// SnapshotDiskReadRateLimit and UploadRateLimit are in bytes per second, 0
+	// means unlimited. SnapshotDiskReadRateLimit only limits reading the
+	// local copy into the pipeline, not the transfer from etcd.
+	SnapshotDiskReadRateLimit int64
+	UploadRateLimit           int64
+
+	// Member is the etcd member the snapshot was taken from, set by Create.
+	Member MemberStatus
+	// Revision is the revision of the snapshot, set by Create.
+	Revision int64
 }
 
-// Create etcd in temporary directory.
+// snapshotStatus is the JSON output of etcdctl snapshot status.
+type snapshotStatus struct {
+	Revision int64 `json:"revision"`
+}
+
+// Create etcd in temporary directory. With several endpoints the snapshot is
+// taken from the healthiest member, falling back to the others in order of
+// preference.
 func (b *EtcdBackupV3) Create() error {
 	// Filename
-	b.Filename = b.Prefix + "-backup-etcd-v3-" + getTimeStamp() + dbExt
+	b.Filename = b.Prefix + v3NameInfix + getTimeStamp() + dbExt
 
 	// Full path to file.
 	fpath := filepath.Join(b.TmpDir, b.Filename)
 
-	etcdctlEnvs := []string{"ETCDCTL_API=3"}
-	etcdctlArgs := []string{
-		"snapshot",
-		"save",
-		fpath,
-	}
+	c := b.Connection()
 
-	if b.Endpoints != "" {
-		etcdctlArgs = append(etcdctlArgs, "--endpoints", b.Endpoints)
+	candidates, err := MemberStatuses(c, b.Logger)
+	if err != nil {
+		// ranking is best effort, snapshots may still work
+		b.Logger.Log("level", "warning", "msg", "Failed to query etcd member status", "reason", err)
 	}
-	if b.CACert != "" {
-		etcdctlArgs = append(etcdctlArgs, "--cacert", b.CACert)
+	if len(candidates) == 0 {
+		candidates = []MemberStatus{{Endpoint: c.Endpoints}}
 	}
-	if b.Cert != "" {
-		etcdctlArgs = append(etcdctlArgs, "--cert", b.Cert)
+	candidates = RankMembers(candidates)
+
+	etcdctlEnvs := []string{"ETCDCTL_API=3"}
+	for _, m := range candidates {
+		etcdctlArgs := []string{
+			"snapshot",
+			"save",
+			fpath,
+		}
+		etcdctlArgs = append(etcdctlArgs, c.args(m.Endpoint)...)
+
+		// Create a etcd.
+		_, err = execCmd(etcdctlCmd, etcdctlArgs, etcdctlEnvs, b.Logger)
+		if err != nil {
+			b.Logger.Log("level", "warning", "msg", "Failed to take etcd v3 snapshot from "+m.Endpoint, "reason", err)
+			continue
+		}
+
+		b.Member = m
+		b.Revision, err = snapshotRevision(fpath, b.Logger)
+		if err != nil {
+			// the member revision is from just before the snapshot
+			b.Logger.Log("level", "warning", "msg", "Failed to read etcd v3 snapshot revision", "reason", err)
+			b.Revision = m.Revision
+		}
+		b.Logger.Log("level", "info", "msg", "Etcd v3 backup created successfully", "endpoint", m.Endpoint, "member", fmt.Sprintf("%x", m.MemberID), "revision", b.Revision)
+		return nil
 	}
-	if b.Key != "" {
-		etcdctlArgs = append(etcdctlArgs, "--key", b.Key)
+
+	return microerror.Mask(err)
+}
+
+// SnapshotTime returns when the v3 snapshot name of the backups of prefix
+// was taken, as recorded in its name. It returns false for other backups,
+// e.g. exports or backups of guest clusters under prefix.
+func SnapshotTime(prefix string, name string) (time.Time, bool) {
+	rest := strings.TrimPrefix(name, prefix+v3NameInfix)
+	if rest == name || len(rest) < len(TimestampFormat) || !strings.HasPrefix(rest[len(TimestampFormat):], dbExt) {
+		return time.Time{}, false
 	}
 
-	// Create a etcd.
-	_, err := execCmd(etcdctlCmd, etcdctlArgs, etcdctlEnvs, b.Logger)
+	t, err := time.ParseInLocation(TimestampFormat, rest[:len(TimestampFormat)], time.Local)
 	if err != nil {
-		return microerror.Mask(err)
+		return time.Time{}, false
 	}
 
-	// Create tar.gz.
-	err = archiver.TarGz.Make(fpath+tgzExt, []string{fpath})
+	return t, true
+}
+
+// SnapshotPrefix returns the prefix of the v3 snapshot name.
+func SnapshotPrefix(name string) (string, bool) {
+	i := strings.LastIndex(name, v3NameInfix)
+	if i < 0 {
+		return "", false
+	}
+	prefix := name[:i]
+	_, ok := SnapshotTime(prefix, name)
+
+	return prefix, ok
+}
+
+// snapshotRevision returns the revision of the snapshot at fpath.
+func snapshotRevision(fpath string, logger micrologger.Logger) (int64, error) {
+	etcdctlEnvs := []string{"ETCDCTL_API=3"}
+
+	out, err := execCmd(etcdctlCmd, []string{"snapshot", "status", fpath, "-w", "json"}, etcdctlEnvs, logger)
 	if err != nil {
-		return microerror.Mask(err)
+		return 0, microerror.Mask(err)
 	}
 
-	// Update Filename in etcd object.
-	b.Filename = b.Filename + tgzExt
+	var s snapshotStatus
+	err = json.Unmarshal(out, &s)
+	if err != nil {
+		return 0, microerror.Maskf(invalidEtcdctlOutputError, "snapshot status: %s", out)
+	}
 
-	b.Logger.Log("level", "info", "msg", "Etcd v3 backup created successfully")
-	return nil
+	return s.Revision, nil
 }
 
-// encrypt backup
-func (b *EtcdBackupV3) Encrypt() error {
-	if b.EncPass == "" {
-		b.Logger.Log("level", "warning", "msg", "No passphrase provided. Skipping etcd v3 backup encryption")
-		return nil
+// Connection returns the etcdctl connection settings.
+func (b *EtcdBackupV3) Connection() Connection {
+	return Connection{
+		CACert:    b.CACert,
+		Cert:      b.Cert,
+		Key:       b.Key,
+		Endpoints: b.Endpoints,
 	}
+}
 
+// Upload compresses, encrypts and uploads the snapshot in one pipeline. The
+// snapshot is compressed as is, a tar around a single file adds nothing.
+func (b *EtcdBackupV3) Upload() (storage.Result, []StageStats, error) {
 	// Full path to file.
 	fpath := filepath.Join(b.TmpDir, b.Filename)
 
-	// Encrypt etcd.
-	err := encryptFile(fpath, fpath+encExt, b.EncPass)
+	f, err := os.Open(fpath)
 	if err != nil {
-		return microerror.Mask(err)
+		return storage.Result{}, nil, microerror.Mask(err)
 	}
+	defer f.Close()
 
-	// Update Filename in etcd object.
-	b.Filename = b.Filename + encExt
+	c := pipelineConfig{
+		Logger: b.Logger,
 
-	b.Logger.Log("level", "info", "msg", "Etcd v3 backup encrypted successfully")
-	return nil
-}
+		Compression: b.Compression,
+		EncPass:     b.EncPass,
+		Uploader:    b.Uploader,
 
-// Upload resulted etcd to S3.
-func (b *EtcdBackupV3) Upload() (int64, error) {
-	fpath := filepath.Join(b.TmpDir, b.Filename)
+		ReadRateLimit:   b.SnapshotDiskReadRateLimit,
+		UploadRateLimit: b.UploadRateLimit,
+
+		Name:     b.Filename,
+		Source:   f,
+		Metadata: snapshotMetadata(b.Member, b.Revision),
+
+		VerifyReplication: true,
+	}
 
-	// Upload.
-	size, err := uploadToS3(fpath, b.Aws, b.Logger)
+	name, result, stats, err := runPipeline(c)
 	if err != nil {
-		return -1, microerror.Mask(err)
+		return result, stats, microerror.Mask(err)
 	}
 
+	// Update Filename in etcd object.
+	b.Filename = name
+
 	b.Logger.Log("level", "info", "msg", "Etcd v3 backup uploaded successfully")
-	return size, nil
+	return result, stats, nil
+}
+
+// Export returns a logical export of the keys at the revision of the
+// snapshot, read from the same member. Create must have succeeded.
+func (b *EtcdBackupV3) Export(prefixes []string) *EtcdExport {
+	c := b.Connection()
+	if b.Member.Endpoint != "" {
+		c.Endpoints = b.Member.Endpoint
+	}
+
+	return &EtcdExport{
+		Logger: b.Logger,
+
+		Compression: b.Compression,
+		Connection:  c,
+		EncPass:     b.EncPass,
+		Filename:    b.Filename[:strings.LastIndex(b.Filename, dbExt)] + exportExt,
+		Member:      b.Member,
+		Prefixes:    prefixes,
+		Revision:    b.Revision,
+		TmpDir:      b.TmpDir,
+		Uploader:    b.Uploader,
+
+		UploadRateLimit: b.UploadRateLimit,
+	}
+}
+
+// Journal returns a journal of the changes after the snapshot, watched on
+// the same member. Create must have succeeded. The connection files and
+// tmpDir must outlive the journal, unlike the temporary directory of the
+// backup.
+func (b *EtcdBackupV3) Journal(c Connection, segmentInterval time.Duration, tmpDir string) *EtcdJournal {
+	if b.Member.Endpoint != "" {
+		c.Endpoints = b.Member.Endpoint
+	}
+
+	return &EtcdJournal{
+		Logger: b.Logger,
+
+		Compression:     b.Compression,
+		Connection:      c,
+		EncPass:         b.EncPass,
+		Member:          b.Member,
+		Prefix:          b.Prefix,
+		Revision:        b.Revision + 1,
+		SegmentInterval: segmentInterval,
+		TmpDir:          tmpDir,
+		Uploader:        b.Uploader,
+
+		UploadRateLimit: b.UploadRateLimit,
+	}
+}
+
+// snapshotMetadata describes the member and the revision keys were read
+// from.
+func snapshotMetadata(m MemberStatus, revision int64) map[string]string {
+	metadata := m.Metadata()
+	if revision != 0 {
+		metadata[MetadataRevision] = strconv.FormatInt(revision, 10)
+	}
+
+	return metadata
 }
 
 func (b *EtcdBackupV3) Version() string {
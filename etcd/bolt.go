@@ -0,0 +1,233 @@
+package etcd
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"os"
+
+	"github.com/giantswarm/microerror"
+)
+
+// The bolt on-disk format, as written by etcd into its backend database and
+// snapshots. Only what is needed to read buckets is covered.
+const (
+	boltMagic       = 0xED0CDAED
+	boltPageHeader  = 16
+	boltElementSize = 16
+	boltMetaSize    = 56
+	boltMinPageSize = 1024
+
+	boltBranchPage = 0x01
+	boltLeafPage   = 0x02
+
+	boltBucketLeaf = 0x01
+
+	// boltMaxDepth bounds the depth of a bucket tree. bolt keeps its trees
+	// balanced, real databases stay far below it.
+	boltMaxDepth = 64
+)
+
+// boltDB reads a bolt database read-only, one page at a time, so large
+// snapshots do not need to fit into memory.
+type boltDB struct {
+	f        *os.File
+	size     int64
+	pageSize int
+	// root is the page of the root bucket.
+	root uint64
+}
+
+// boltMeta is the part of a meta page needed to find the data.
+type boltMeta struct {
+	pageSize int
+	root     uint64
+	txid     uint64
+}
+
+// openBolt opens the bolt database at path using the newest valid meta
+// page.
+func openBolt(path string) (*boltDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// the second meta page follows the first, whose page size is used
+	meta, ok := readBoltMeta(f, 0)
+	pageSize := os.Getpagesize()
+	if ok {
+		pageSize = meta.pageSize
+	}
+	second, secondOK := readBoltMeta(f, int64(pageSize))
+	if secondOK && (!ok || second.txid > meta.txid) {
+		meta, ok = second, true
+	}
+	if !ok {
+		f.Close()
+		return nil, microerror.Maskf(invalidSnapshotError, "%s has no valid bolt meta page", path)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, microerror.Mask(err)
+	}
+
+	db := &boltDB{
+		f:        f,
+		size:     fi.Size(),
+		pageSize: meta.pageSize,
+		root:     meta.root,
+	}
+
+	return db, nil
+}
+
+// readBoltMeta reads the meta page at offset. It returns false if the page
+// is no meta page or its checksum does not match.
+func readBoltMeta(f *os.File, offset int64) (boltMeta, bool) {
+	p := make([]byte, boltPageHeader+boltMetaSize+8)
+	_, err := f.ReadAt(p, offset)
+	if err != nil {
+		return boltMeta{}, false
+	}
+
+	m := p[boltPageHeader:]
+	if binary.LittleEndian.Uint32(m[0:4]) != boltMagic {
+		return boltMeta{}, false
+	}
+	h := fnv.New64a()
+	h.Write(m[:boltMetaSize])
+	if h.Sum64() != binary.LittleEndian.Uint64(m[boltMetaSize:]) {
+		return boltMeta{}, false
+	}
+
+	meta := boltMeta{
+		pageSize: int(binary.LittleEndian.Uint32(m[8:12])),
+		root:     binary.LittleEndian.Uint64(m[16:24]),
+		txid:     binary.LittleEndian.Uint64(m[48:56]),
+	}
+	if meta.pageSize < boltMinPageSize {
+		return boltMeta{}, false
+	}
+
+	return meta, true
+}
+
+func (db *boltDB) Close() error {
+	return db.f.Close()
+}
+
+// page returns page id including its overflow pages.
+func (db *boltDB) page(id uint64) ([]byte, error) {
+	offset := int64(id) * int64(db.pageSize)
+
+	header := make([]byte, boltPageHeader)
+	_, err := db.f.ReadAt(header, offset)
+	if err != nil {
+		return nil, microerror.Maskf(invalidSnapshotError, "page %d: %s", id, err)
+	}
+	overflow := binary.LittleEndian.Uint32(header[12:16])
+	size := (int64(overflow) + 1) * int64(db.pageSize)
+	if offset+size > db.size {
+		return nil, microerror.Maskf(invalidSnapshotError, "page %d overflows the file", id)
+	}
+
+	p := make([]byte, size)
+	_, err = db.f.ReadAt(p, offset)
+	if err != nil {
+		return nil, microerror.Maskf(invalidSnapshotError, "page %d: %s", id, err)
+	}
+
+	return p, nil
+}
+
+// forEach calls fn for every key of the bucket name in the root bucket, in
+// key order. Nested buckets are passed with their header as value.
+func (db *boltDB) forEach(name string, fn func(k, v []byte) error) error {
+	var bucket []byte
+	err := db.walk(db.root, nil, 0, map[uint64]bool{}, func(k, v []byte, flags uint32) error {
+		if flags&boltBucketLeaf != 0 && string(k) == name {
+			bucket = v
+		}
+		return nil
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if bucket == nil {
+		return microerror.Maskf(invalidSnapshotError, "bucket %q not found", name)
+	}
+	if len(bucket) < 16 {
+		return microerror.Maskf(invalidSnapshotError, "bucket %q is truncated", name)
+	}
+
+	root := binary.LittleEndian.Uint64(bucket[0:8])
+	var inline []byte
+	if root == 0 {
+		// small buckets are stored inline after their header
+		inline = bucket[16:]
+	}
+
+	return db.walk(root, inline, 0, map[uint64]bool{}, func(k, v []byte, flags uint32) error {
+		return fn(k, v)
+	})
+}
+
+// walk calls fn for every leaf element below page id, or below the inline
+// page p if set. depth and visited guard against corrupt trees, whose
+// branches could point back to their own pages.
+func (db *boltDB) walk(id uint64, p []byte, depth int, visited map[uint64]bool, fn func(k, v []byte, flags uint32) error) error {
+	if depth > boltMaxDepth {
+		return microerror.Maskf(invalidSnapshotError, "page %d is nested too deeply", id)
+	}
+	if p == nil {
+		if visited[id] {
+			return microerror.Maskf(invalidSnapshotError, "page %d is referenced twice", id)
+		}
+		visited[id] = true
+
+		var err error
+		p, err = db.page(id)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+	if len(p) < boltPageHeader {
+		return microerror.Maskf(invalidSnapshotError, "page %d is truncated", id)
+	}
+
+	flags := binary.LittleEndian.Uint16(p[8:10])
+	count := int(binary.LittleEndian.Uint16(p[10:12]))
+	if len(p) < boltPageHeader+count*boltElementSize {
+		return microerror.Maskf(invalidSnapshotError, "page %d is truncated", id)
+	}
+
+	for i := 0; i < count; i++ {
+		e := boltPageHeader + i*boltElementSize
+
+		switch {
+		case flags&boltBranchPage != 0:
+			child := binary.LittleEndian.Uint64(p[e+8 : e+16])
+			err := db.walk(child, nil, depth+1, visited, fn)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		case flags&boltLeafPage != 0:
+			elementFlags := binary.LittleEndian.Uint32(p[e : e+4])
+			pos := e + int(binary.LittleEndian.Uint32(p[e+4:e+8]))
+			ksize := int(binary.LittleEndian.Uint32(p[e+8 : e+12]))
+			vsize := int(binary.LittleEndian.Uint32(p[e+12 : e+16]))
+			if pos+ksize+vsize > len(p) {
+				return microerror.Maskf(invalidSnapshotError, "page %d is truncated", id)
+			}
+			err := fn(p[pos:pos+ksize], p[pos+ksize:pos+ksize+vsize], elementFlags)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		default:
+			return microerror.Maskf(invalidSnapshotError, "page %d is no branch or leaf page", id)
+		}
+	}
+
+	return nil
+}
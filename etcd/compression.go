@@ -0,0 +1,186 @@
+package etcd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	CompressionGzip = "gzip"
+	CompressionNone = "none"
+	CompressionZstd = "zstd"
+)
+
+const (
+	gzExt  = ".gz"
+	tarExt = ".tar"
+	zstExt = ".zst"
+
+	zstdCmd = "zstd"
+	// zstdMaxLevel is the highest level zstd accepts without --ultra.
+	zstdMaxLevel = 19
+)
+
+// Compression selects how backups are compressed. Level 0 uses the default
+// level of the algorithm. Values are validated by config.CheckConfig.
+type Compression struct {
+	Algorithm string
+	Level     int
+}
+
+// Ext returns the file extension of the algorithm.
+func (c Compression) Ext() string {
+	switch c.Algorithm {
+	case CompressionGzip:
+		return gzExt
+	case CompressionZstd:
+		return zstExt
+	}
+
+	return ""
+}
+
+// newWriter returns a writer compressing to w. Compression runs on all
+// CPUs. The output is complete after Close.
+func (c Compression) newWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c.Algorithm {
+	case CompressionZstd:
+		args := []string{"-q", "-c", "-T0"}
+		if c.Level > zstdMaxLevel {
+			args = append(args, "--ultra")
+		}
+		if c.Level > 0 {
+			args = append(args, fmt.Sprintf("-%d", c.Level))
+		}
+
+		cmd := exec.Command(zstdCmd, args...)
+		cmd.Stdout = w
+
+		return newCmdWriter(cmd)
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	}
+
+	level := gzip.DefaultCompression
+	if c.Level > 0 {
+		level = c.Level
+	}
+
+	return newParallelGzipWriter(w, level), nil
+}
+
+// decompressReader returns the decompressed content of r, with the
+// algorithm taken from the extension of name. It also returns name without
+// the compression extension. Names without a known extension are passed
+// through.
+func decompressReader(r io.Reader, name string) (io.ReadCloser, string, error) {
+	switch {
+	case strings.HasSuffix(name, gzExt):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, "", microerror.Mask(err)
+		}
+		return gz, strings.TrimSuffix(name, gzExt), nil
+	case strings.HasSuffix(name, zstExt):
+		c := exec.Command(zstdCmd, "-q", "-d", "-c")
+		c.Stdin = r
+		rc, err := newCmdReader(c)
+		if err != nil {
+			return nil, "", microerror.Mask(err)
+		}
+		return rc, strings.TrimSuffix(name, zstExt), nil
+	}
+
+	return ioutil.NopCloser(r), name, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// cmdWriter writes to the stdin of a command. Close waits for the command.
+type cmdWriter struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func newCmdWriter(c *exec.Cmd) (*cmdWriter, error) {
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	c.Stderr = os.Stderr
+
+	err = c.Start()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &cmdWriter{WriteCloser: stdin, cmd: c}, nil
+}
+
+func (w *cmdWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if werr := w.cmd.Wait(); err == nil {
+		err = werr
+	}
+
+	return err
+}
+
+// cmdReader reads the stdout of a command. Close waits for the command, which
+// is killed if its output was not read to the end.
+type cmdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+	eof bool
+}
+
+func newCmdReader(c *exec.Cmd) (*cmdReader, error) {
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	c.Stderr = os.Stderr
+
+	err = c.Start()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &cmdReader{ReadCloser: stdout, cmd: c}, nil
+}
+
+func (r *cmdReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		r.eof = true
+	}
+
+	return n, err
+}
+
+// Close returns the exit error of the command only when its output was read
+// to the end. A consumer stopping early has its own error, and the command
+// would otherwise block writing output no one reads.
+func (r *cmdReader) Close() error {
+	if !r.eof {
+		r.ReadCloser.Close()
+		r.cmd.Process.Kill()
+		r.cmd.Wait()
+		return nil
+	}
+
+	return r.cmd.Wait()
+}
@@ -0,0 +1,72 @@
+package etcd
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func Test_cmdReader_Close(t *testing.T) {
+	testCases := []struct {
+		name     string
+		command  []string
+		readAll  bool
+		expected bool
+	}{
+		{
+			name:    "case 0: output read to the end",
+			command: []string{"sh", "-c", "echo snapshot"},
+			readAll: true,
+		},
+		{
+			name:     "case 1: exit error after the output was read to the end",
+			command:  []string{"sh", "-c", "echo snapshot; exit 3"},
+			readAll:  true,
+			expected: true,
+		},
+		{
+			name:    "case 2: consumer stops early, the command never ends by itself",
+			command: []string{"yes"},
+		},
+		{
+			name:    "case 3: consumer stops early, the command fails",
+			command: []string{"sh", "-c", "yes; exit 3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newCmdReader(exec.Command(tc.command[0], tc.command[1:]...))
+			if err != nil {
+				t.Fatalf("expected no error, got %#v", err)
+			}
+
+			if tc.readAll {
+				_, err = ioutil.ReadAll(r)
+			} else {
+				_, err = r.Read(make([]byte, 16))
+			}
+			if err != nil {
+				t.Fatalf("expected no read error, got %#v", err)
+			}
+
+			closed := make(chan error, 1)
+			go func() {
+				closed <- r.Close()
+			}()
+
+			select {
+			case err = <-closed:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Close did not return")
+			}
+			if tc.expected && err == nil {
+				t.Fatalf("expected the exit error")
+			}
+			if !tc.expected && err != nil {
+				t.Fatalf("expected no error, got %#v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats of decoded values.
+const (
+	FormatJSON = "json"
+	FormatRaw  = "raw"
+	FormatYAML = "yaml"
+)
+
+const k8sProtobufContentType = "application/vnd.kubernetes.protobuf"
+
+// k8sProtobufPrefix starts values Kubernetes stores as protobuf.
+var k8sProtobufPrefix = []byte("k8s\x00")
+
+// Decode returns a Kubernetes object stored in etcd as YAML or JSON.
+// Built-in types stored as protobuf are decoded with the client-go scheme,
+// custom resources are stored as JSON already. It returns false for values
+// that are no Kubernetes object, e.g. encrypted at rest, which are returned
+// as is. FormatRaw returns every value as is.
+func Decode(value []byte, format string) ([]byte, bool, error) {
+	if format == FormatRaw {
+		return value, false, nil
+	}
+
+	var data []byte
+	switch {
+	case bytes.HasPrefix(value, k8sProtobufPrefix):
+		s := protobuf.NewSerializer(scheme.Scheme, scheme.Scheme, k8sProtobufContentType)
+		obj, gvk, err := s.Decode(value, nil, nil)
+		if err != nil {
+			// e.g. a type unknown to this client-go version
+			return value, false, nil
+		}
+		// protobuf does not carry the type in the object
+		obj.GetObjectKind().SetGroupVersionKind(*gvk)
+
+		data, err = json.Marshal(obj)
+		if err != nil {
+			return nil, false, microerror.Mask(err)
+		}
+	case json.Valid(value) && bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")):
+		data = value
+	default:
+		return value, false, nil
+	}
+
+	if format == FormatYAML {
+		out, err := yaml.JSONToYAML(data)
+		if err != nil {
+			return nil, false, microerror.Mask(err)
+		}
+		return out, true, nil
+	}
+
+	var out bytes.Buffer
+	err := json.Indent(&out, data, "", "  ")
+	if err != nil {
+		return nil, false, microerror.Mask(err)
+	}
+	out.WriteString("\n")
+
+	return out.Bytes(), true, nil
+}
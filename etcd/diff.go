@@ -0,0 +1,218 @@
+package etcd
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Changes of a key between two backups.
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeRemoved  = "removed"
+)
+
+const (
+	// k8sRegistryPrefix starts the keys Kubernetes stores objects under.
+	k8sRegistryPrefix = "/registry/"
+	// resourceOther groups keys not written by Kubernetes.
+	resourceOther = "other"
+
+	// diffContext is the number of unchanged lines shown around changes.
+	diffContext = 3
+	// maxDiffCells limits the size of the table of a line diff, larger
+	// values are only reported as modified.
+	maxDiffCells = 4 << 20
+)
+
+// KeyChange is a key that differs between two backups. Diff is a line diff
+// of the decoded values of modified Kubernetes objects, if requested.
+type KeyChange struct {
+	Key    string `json:"key"`
+	Change string `json:"change"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+// ResourceChanges are the changed keys of one Kubernetes resource type,
+// e.g. secrets or clusters.giantswarm.io.
+type ResourceChanges struct {
+	Resource string      `json:"resource"`
+	Added    int         `json:"added"`
+	Removed  int         `json:"removed"`
+	Modified int         `json:"modified"`
+	Changes  []KeyChange `json:"changes"`
+}
+
+// DiffKeys compares the keys of two backups, both sorted by key, and groups
+// the changes by resource type. With values modified Kubernetes objects get a
+// line diff of their YAML.
+func DiffKeys(from []KeyValue, to []KeyValue, values bool) ([]ResourceChanges, error) {
+	resources := map[string]*ResourceChanges{}
+	add := func(key string, change string, diff string) {
+		resource := ResourceType(key)
+		r, ok := resources[resource]
+		if !ok {
+			r = &ResourceChanges{Resource: resource}
+			resources[resource] = r
+		}
+
+		switch change {
+		case ChangeAdded:
+			r.Added++
+		case ChangeRemoved:
+			r.Removed++
+		case ChangeModified:
+			r.Modified++
+		}
+		r.Changes = append(r.Changes, KeyChange{Key: key, Change: change, Diff: diff})
+	}
+
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case j == len(to) || (i < len(from) && from[i].Key < to[j].Key):
+			add(from[i].Key, ChangeRemoved, "")
+			i++
+		case i == len(from) || to[j].Key < from[i].Key:
+			add(to[j].Key, ChangeAdded, "")
+			j++
+		default:
+			if !bytes.Equal(from[i].Value, to[j].Value) {
+				var diff string
+				if values {
+					var err error
+					diff, err = valueDiff(from[i].Value, to[j].Value)
+					if err != nil {
+						return nil, microerror.Mask(err)
+					}
+				}
+				add(from[i].Key, ChangeModified, diff)
+			}
+			i++
+			j++
+		}
+	}
+
+	var changes []ResourceChanges
+	for _, r := range resources {
+		changes = append(changes, *r)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Resource < changes[j].Resource
+	})
+
+	return changes, nil
+}
+
+// ResourceType returns the Kubernetes resource type of key, e.g. secrets for
+// /registry/secrets/default/app and clusters.giantswarm.io for
+// /registry/giantswarm.io/clusters/default/abc.
+func ResourceType(key string) string {
+	if !strings.HasPrefix(key, k8sRegistryPrefix) {
+		return resourceOther
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(key, k8sRegistryPrefix), "/", 3)
+	// API groups contain a dot, core resources do not
+	if strings.Contains(parts[0], ".") && len(parts) > 1 {
+		return parts[1] + "." + parts[0]
+	}
+
+	return parts[0]
+}
+
+// valueDiff returns the line diff of two values decoded as YAML. It is empty
+// when the values are no Kubernetes objects.
+func valueDiff(from []byte, to []byte) (string, error) {
+	a, ok, err := Decode(from, FormatYAML)
+	if err != nil {
+		return "", microerror.Mask(err)
+	} else if !ok {
+		return "", nil
+	}
+	b, ok, err := Decode(to, FormatYAML)
+	if err != nil {
+		return "", microerror.Mask(err)
+	} else if !ok {
+		return "", nil
+	}
+
+	return lineDiff(string(a), string(b)), nil
+}
+
+// lineDiff returns the lines removed from a, prefixed with "-", and added in
+// b, prefixed with "+", with a few unchanged lines around them.
+func lineDiff(a string, b string) string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+	n, m := len(x), len(y)
+	if n*m > maxDiffCells {
+		return "values too large to diff\n"
+	}
+
+	// lcs[i][j] is the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && x[i] == y[j]:
+			lines = append(lines, " "+x[i])
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+x[i])
+			i++
+		default:
+			lines = append(lines, "+"+y[j])
+			j++
+		}
+	}
+
+	// show changed lines and their context only
+	show := make([]bool, len(lines))
+	for k, l := range lines {
+		if l[0] == ' ' {
+			continue
+		}
+		for c := k - diffContext; c <= k+diffContext; c++ {
+			if c >= 0 && c < len(lines) {
+				show[c] = true
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	skipped := false
+	for k, l := range lines {
+		if !show[k] {
+			skipped = true
+			continue
+		}
+		if skipped && out.Len() > 0 {
+			out.WriteString("...\n")
+		}
+		skipped = false
+		out.WriteString(l + "\n")
+	}
+
+	return out.String()
+}
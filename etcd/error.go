@@ -0,0 +1,119 @@
+package etcd
+
+import "github.com/giantswarm/microerror"
+
+var decryptionFailedError = microerror.New("decryption failed")
+
+// IsDecryptionFailed asserts decryptionFailedError.
+func IsDecryptionFailed(err error) bool {
+	return microerror.Cause(err) == decryptionFailedError
+}
+
+var missingPassphraseError = microerror.New("missing passphrase")
+
+// IsMissingPassphrase asserts missingPassphraseError.
+func IsMissingPassphrase(err error) bool {
+	return microerror.Cause(err) == missingPassphraseError
+}
+
+var invalidEtcdctlOutputError = microerror.New("invalid etcdctl output")
+
+// IsInvalidEtcdctlOutput asserts invalidEtcdctlOutputError.
+func IsInvalidEtcdctlOutput(err error) bool {
+	return microerror.Cause(err) == invalidEtcdctlOutputError
+}
+
+var invalidSnapshotError = microerror.New("invalid snapshot")
+
+// IsInvalidSnapshot asserts invalidSnapshotError.
+func IsInvalidSnapshot(err error) bool {
+	return microerror.Cause(err) == invalidSnapshotError
+}
+
+var journalCompactedError = microerror.New("journal compacted")
+
+// IsJournalCompacted asserts journalCompactedError.
+func IsJournalCompacted(err error) bool {
+	return microerror.Cause(err) == journalCompactedError
+}
+
+var journalGapError = microerror.New("journal gap")
+
+// IsJournalGap asserts journalGapError.
+func IsJournalGap(err error) bool {
+	return microerror.Cause(err) == journalGapError
+}
+
+var invalidMemberError = microerror.New("invalid member")
+
+// IsInvalidMember asserts invalidMemberError.
+func IsInvalidMember(err error) bool {
+	return microerror.Cause(err) == invalidMemberError
+}
+
+var createFailedError = microerror.New("create failed")
+
+// IsCreateFailed asserts createFailedError.
+func IsCreateFailed(err error) bool {
+	return microerror.Cause(err) == createFailedError
+}
+
+var readFailedError = microerror.New("read failed")
+
+// IsReadFailed asserts readFailedError.
+func IsReadFailed(err error) bool {
+	return microerror.Cause(err) == readFailedError
+}
+
+var compressFailedError = microerror.New("compress failed")
+
+// IsCompressFailed asserts compressFailedError.
+func IsCompressFailed(err error) bool {
+	return microerror.Cause(err) == compressFailedError
+}
+
+var encryptFailedError = microerror.New("encrypt failed")
+
+// IsEncryptFailed asserts encryptFailedError.
+func IsEncryptFailed(err error) bool {
+	return microerror.Cause(err) == encryptFailedError
+}
+
+var uploadFailedError = microerror.New("upload failed")
+
+// IsUploadFailed asserts uploadFailedError.
+func IsUploadFailed(err error) bool {
+	return microerror.Cause(err) == uploadFailedError
+}
+
+// stageErrors are the errors of the stages of a backup.
+var stageErrors = map[string]error{
+	StageCreate:   createFailedError,
+	StageRead:     readFailedError,
+	StageCompress: compressFailedError,
+	StageEncrypt:  encryptFailedError,
+	StageUpload:   uploadFailedError,
+}
+
+// stageError returns err as the error of stage. Errors of a stage are
+// returned as they are.
+func stageError(stage string, err error) error {
+	if FailedStage(err) != "" {
+		return microerror.Mask(err)
+	}
+
+	return microerror.Maskf(stageErrors[stage], "%s", err)
+}
+
+// FailedStage returns the stage of the backup err was returned by, or an
+// empty string for other errors.
+func FailedStage(err error) string {
+	cause := microerror.Cause(err)
+	for stage, stageErr := range stageErrors {
+		if cause == stageErr {
+			return stage
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,227 @@
+package etcd
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+const (
+	exportExt = ".export.jsonl"
+	// exportPageSize is the number of keys read with one etcdctl call.
+	exportPageSize = 1000
+)
+
+// KeyValue is a key as stored in etcd. Logical exports are newline
+// delimited JSON with one KeyValue per line, Value is base64 encoded.
+type KeyValue struct {
+	Key            string `json:"key"`
+	Value          []byte `json:"value"`
+	CreateRevision int64  `json:"createRevision"`
+	ModRevision    int64  `json:"modRevision"`
+	Version        int64  `json:"version"`
+	Lease          int64  `json:"lease"`
+}
+
+// etcdKeyValue is a key in the JSON output of etcdctl.
+type etcdKeyValue struct {
+	Key            []byte `json:"key"`
+	Value          []byte `json:"value"`
+	CreateRevision int64  `json:"create_revision"`
+	ModRevision    int64  `json:"mod_revision"`
+	Version        int64  `json:"version"`
+	Lease          int64  `json:"lease"`
+}
+
+func (kv etcdKeyValue) keyValue() KeyValue {
+	return KeyValue{
+		Key:            string(kv.Key),
+		Value:          kv.Value,
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+		Lease:          kv.Lease,
+	}
+}
+
+// rangeResponse is the JSON output of etcdctl get.
+type rangeResponse struct {
+	Kvs  []etcdKeyValue `json:"kvs"`
+	More bool           `json:"more"`
+}
+
+// EtcdExport is a logical export of the keys of a v3 cluster at one
+// revision. Unlike a snapshot single keys can be read from it without
+// restoring the cluster. Use EtcdBackupV3.Export to pair it with a snapshot.
+type EtcdExport struct {
+	Compression Compression
+	Connection  Connection
+	EncPass     string
+	Filename    string
+	Logger      micrologger.Logger
+	// Member is the etcd member the keys are read from.
+	Member MemberStatus
+	// Prefixes limit the export to keys with these prefixes. All keys are
+	// exported without prefixes.
+	Prefixes []string
+	// Revision is the revision the keys are read at, 0 is the latest.
+	Revision int64
+	TmpDir   string
+	Uploader storage.Uploader
+
+	// UploadRateLimit is in bytes per second, 0 means unlimited.
+	UploadRateLimit int64
+}
+
+// Create writes the keys to the temporary directory. Keys are read in pages,
+// so the export never needs to fit into memory.
+func (b *EtcdExport) Create() error {
+	fpath := filepath.Join(b.TmpDir, b.Filename)
+
+	f, err := os.Create(fpath)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+
+	prefixes := b.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+
+	var count int
+	for _, prefix := range prefixes {
+		n, err := b.exportPrefix(prefix, enc)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		count += n
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	err = f.Close()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	b.Logger.Log("level", "info", "msg", "Etcd v3 export created successfully", "keys", count, "revision", b.Revision)
+	return nil
+}
+
+// exportPrefix writes all keys with prefix. Every page after the first
+// starts at the last key of the previous page, which is skipped.
+func (b *EtcdExport) exportPrefix(prefix string, enc *json.Encoder) (int, error) {
+	etcdctlEnvs := []string{"ETCDCTL_API=3"}
+
+	var count int
+	var last []byte
+	for {
+		args := []string{"get", "-w", "json", "--limit", strconv.Itoa(exportPageSize + 1)}
+		if b.Revision != 0 {
+			args = append(args, "--rev", strconv.FormatInt(b.Revision, 10))
+		}
+		args = append(args, b.Connection.args(b.Connection.Endpoints)...)
+		// keys follow --, so they are never taken for flags
+		if last == nil {
+			args = append(args, "--prefix", "--", prefix)
+		} else if end := prefixEnd(prefix); end != "" {
+			args = append(args, "--", string(last), end)
+		} else {
+			args = append(args, "--from-key", "--", string(last))
+		}
+
+		out, err := execCmd(etcdctlCmd, args, etcdctlEnvs, b.Logger)
+		if err != nil {
+			return count, microerror.Mask(err)
+		}
+
+		var r rangeResponse
+		err = json.Unmarshal(out, &r)
+		if err != nil {
+			return count, microerror.Maskf(invalidEtcdctlOutputError, "get: %s", err)
+		}
+
+		kvs := r.Kvs
+		if last != nil && len(kvs) > 0 && string(kvs[0].Key) == string(last) {
+			kvs = kvs[1:]
+		}
+		for _, kv := range kvs {
+			err = enc.Encode(kv.keyValue())
+			if err != nil {
+				return count, microerror.Mask(err)
+			}
+			count++
+		}
+
+		if !r.More || len(r.Kvs) == 0 {
+			return count, nil
+		}
+		last = r.Kvs[len(r.Kvs)-1].Key
+	}
+}
+
+// prefixEnd returns the end of the range of keys with prefix, as etcd
+// computes it. It is empty when the range is open, e.g. for an empty prefix.
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+
+	return ""
+}
+
+// Upload compresses, encrypts and uploads the export in one pipeline.
+func (b *EtcdExport) Upload() (storage.Result, []StageStats, error) {
+	fpath := filepath.Join(b.TmpDir, b.Filename)
+
+	f, err := os.Open(fpath)
+	if err != nil {
+		return storage.Result{}, nil, microerror.Mask(err)
+	}
+	defer f.Close()
+
+	c := pipelineConfig{
+		Logger: b.Logger,
+
+		Compression: b.Compression,
+		EncPass:     b.EncPass,
+		Uploader:    b.Uploader,
+
+		UploadRateLimit: b.UploadRateLimit,
+
+		Name:     b.Filename,
+		Source:   f,
+		Metadata: snapshotMetadata(b.Member, b.Revision),
+	}
+
+	name, result, stats, err := runPipeline(c)
+	if err != nil {
+		return result, stats, microerror.Mask(err)
+	}
+
+	b.Filename = name
+
+	b.Logger.Log("level", "info", "msg", "Etcd v3 export uploaded successfully")
+	return result, stats, nil
+}
+
+func (b *EtcdExport) Version() string {
+	return "v3 export"
+}
@@ -0,0 +1,572 @@
+package etcd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+// Types of journal events.
+const (
+	EventDelete = "delete"
+	EventPut    = "put"
+)
+
+const (
+	// journalNameInfix separates the prefix from the time in journal
+	// segment names.
+	journalNameInfix = "-journal-etcd-v3-"
+	journalExt       = ".journal.jsonl"
+
+	// journalRetryDelay is the time between restarts of a failed watch.
+	journalRetryDelay = 10 * time.Second
+	// maxWatchResponseSize is the largest line of etcdctl watch output read,
+	// a response holds all events of a revision with base64 values.
+	maxWatchResponseSize = 64 << 20
+	// watchEventDelete is the type of a delete in etcdctl watch output, puts
+	// have no type.
+	watchEventDelete = 1
+)
+
+// JournalEvent is a change of a key. Journal segments are newline delimited
+// JSON with one JournalEvent per line, in revision order. ModRevision is the
+// revision of the change, also for deletes.
+type JournalEvent struct {
+	Type string `json:"type"`
+	KeyValue
+}
+
+// JournalSegment is an uploaded journal segment as described by its name.
+type JournalSegment struct {
+	Name string
+	// From and To are the first and last revision the segment covers.
+	From int64
+	To   int64
+	// Time is when the segment was closed, it holds the changes up to then.
+	Time time.Time
+}
+
+// watchResponse is a line of etcdctl watch JSON output.
+type watchResponse struct {
+	Events []struct {
+		Type int          `json:"type"`
+		Kv   etcdKeyValue `json:"kv"`
+	} `json:"Events"`
+	CompactRevision int64 `json:"CompactRevision"`
+	Canceled        bool  `json:"Canceled"`
+}
+
+// EtcdJournal records the changes of a v3 cluster after a full snapshot,
+// so the cluster can be restored to a revision between two snapshots. The
+// changes are watched and uploaded in segments every SegmentInterval. Use
+// EtcdBackupV3.Journal to start it at the revision of a snapshot.
+type EtcdJournal struct {
+	Compression Compression
+	Connection  Connection
+	EncPass     string
+	Logger      micrologger.Logger
+	// Member is the etcd member the changes are watched on.
+	Member MemberStatus
+	Prefix string
+	// Revision is the next revision to record. It advances with every
+	// recorded change.
+	Revision        int64
+	SegmentInterval time.Duration
+	TmpDir          string
+	Uploader        storage.Uploader
+
+	// UploadRateLimit is in bytes per second, 0 means unlimited.
+	UploadRateLimit int64
+
+	segment *journalSegmentFile
+}
+
+// journalSegmentFile is the segment being recorded.
+type journalSegmentFile struct {
+	f      *os.File
+	w      *bufio.Writer
+	enc    *json.Encoder
+	from   int64
+	to     int64
+	events int
+}
+
+// watcher is a running etcdctl watch. err is set when responses is closed,
+// after etcdctl exited.
+type watcher struct {
+	cmd       *exec.Cmd
+	responses chan watchResponse
+	// done is closed when responses are no longer read.
+	done chan struct{}
+	err  error
+}
+
+// Run records changes until stop is closed and uploads the last segment. A
+// failed watch is restarted from the next revision to record, a failed
+// upload is retried with the next segment. Run fails when the revisions to
+// record are compacted, the journal can only continue from a new snapshot.
+func (j *EtcdJournal) Run(stop <-chan struct{}) error {
+	ticker := time.NewTicker(j.SegmentInterval)
+	defer ticker.Stop()
+
+	var w *watcher
+	var retry <-chan time.Time
+	for {
+		if w == nil && retry == nil {
+			var err error
+			w, err = j.watch()
+			if err != nil {
+				j.Logger.Log("level", "warning", "msg", "Failed to start etcd v3 watch for journal "+j.Prefix, "reason", err)
+				retry = time.After(journalRetryDelay)
+			}
+		}
+
+		var responses <-chan watchResponse
+		if w != nil {
+			responses = w.responses
+		}
+
+		select {
+		case r, ok := <-responses:
+			if !ok {
+				err := w.err
+				w = nil
+				if IsJournalCompacted(err) {
+					return j.failed(err)
+				}
+				j.Logger.Log("level", "warning", "msg", "Etcd v3 watch for journal "+j.Prefix+" ended, restarting", "revision", j.Revision, "reason", err)
+				retry = time.After(journalRetryDelay)
+				continue
+			}
+			err := j.record(r)
+			if err != nil {
+				w.abort()
+				return j.failed(err)
+			}
+		case <-retry:
+			retry = nil
+		case <-ticker.C:
+			err := j.flush()
+			if err != nil {
+				j.Logger.Log("level", "warning", "msg", "Failed to upload journal segment of "+j.Prefix+", retrying with the next one", "reason", err)
+			}
+		case <-stop:
+			if w != nil {
+				w.stop()
+				// responses read before the watch stopped are recorded
+				for r := range w.responses {
+					err := j.record(r)
+					if err != nil {
+						w.abort()
+						return j.failed(err)
+					}
+				}
+			}
+			err := j.flush()
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			return nil
+		}
+	}
+}
+
+// failed uploads what was recorded before err.
+func (j *EtcdJournal) failed(err error) error {
+	flushErr := j.flush()
+	if flushErr != nil {
+		j.Logger.Log("level", "error", "msg", "Failed to upload journal segment of "+j.Prefix, "reason", flushErr)
+	}
+
+	return microerror.Mask(err)
+}
+
+// watch starts watching all keys from the next revision to record.
+func (j *EtcdJournal) watch() (*watcher, error) {
+	args := []string{"watch", "-w", "json", "--rev", strconv.FormatInt(j.Revision, 10), "--prefix"}
+	args = append(args, j.Connection.args(j.Connection.Endpoints)...)
+	// the empty prefix matches all keys. Unlike other etcdctl commands watch
+	// runs the arguments after -- as a command, so the key is passed without.
+	args = append(args, "")
+
+	j.Logger.Log("level", "info", "msg", fmt.Sprintf("Executing: %s %v", etcdctlCmd, args))
+
+	cmd := exec.Command(etcdctlCmd, args...)
+	cmd.Env = append(os.Environ(), "ETCDCTL_API=3")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	w := &watcher{
+		cmd:       cmd,
+		responses: make(chan watchResponse),
+		done:      make(chan struct{}),
+	}
+	revision := j.Revision
+	go func() {
+		defer close(w.responses)
+
+		s := bufio.NewScanner(stdout)
+		s.Buffer(nil, maxWatchResponseSize)
+	scan:
+		for s.Scan() {
+			var r watchResponse
+			err := json.Unmarshal(s.Bytes(), &r)
+			if err != nil {
+				w.err = microerror.Maskf(invalidEtcdctlOutputError, "watch: %s", err)
+				break
+			}
+			if r.CompactRevision != 0 {
+				w.err = microerror.Maskf(journalCompactedError, "revision %d is compacted, etcd keeps revisions from %d", revision, r.CompactRevision)
+				break
+			}
+			if r.Canceled {
+				w.err = microerror.Maskf(invalidEtcdctlOutputError, "watch canceled")
+				break
+			}
+			select {
+			case w.responses <- r:
+			case <-w.done:
+				break scan
+			}
+		}
+		if w.err == nil {
+			w.err = s.Err()
+		}
+
+		// the watch only ends on its own on errors
+		cmd.Process.Kill()
+		err := cmd.Wait()
+		switch {
+		case w.err != nil:
+		case strings.Contains(stderr.String(), "compacted"):
+			w.err = microerror.Maskf(journalCompactedError, "revision %d is compacted: %s", revision, strings.TrimSpace(stderr.String()))
+		case err != nil:
+			w.err = microerror.Maskf(err, "%s", strings.TrimSpace(stderr.String()))
+		default:
+			w.err = microerror.Maskf(invalidEtcdctlOutputError, "watch ended")
+		}
+	}()
+
+	return w, nil
+}
+
+// stop ends the watch, responses is closed afterwards. Responses read
+// before are still sent.
+func (w *watcher) stop() {
+	w.cmd.Process.Kill()
+}
+
+// abort ends the watch without reading its responses. It returns once
+// etcdctl exited.
+func (w *watcher) abort() {
+	close(w.done)
+	w.cmd.Process.Kill()
+	for range w.responses {
+	}
+}
+
+// record writes the events of r to the current segment.
+func (j *EtcdJournal) record(r watchResponse) error {
+	for _, e := range r.Events {
+		if j.segment == nil {
+			err := j.openSegment()
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		event := JournalEvent{
+			Type:     EventPut,
+			KeyValue: e.Kv.keyValue(),
+		}
+		if e.Type == watchEventDelete {
+			event.Type = EventDelete
+		}
+		err := j.segment.enc.Encode(event)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		j.segment.to = event.ModRevision
+		j.segment.events++
+		j.Revision = event.ModRevision + 1
+	}
+
+	return nil
+}
+
+func (j *EtcdJournal) openSegment() error {
+	f, err := os.Create(filepath.Join(j.TmpDir, "segment"+journalExt))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	w := bufio.NewWriter(f)
+	j.segment = &journalSegmentFile{
+		f:    f,
+		w:    w,
+		enc:  json.NewEncoder(w),
+		from: j.Revision,
+	}
+
+	return nil
+}
+
+// flush uploads the current segment, if it has changes. The segment is kept
+// when the upload fails, so no change is lost.
+func (j *EtcdJournal) flush() error {
+	seg := j.segment
+	if seg == nil {
+		return nil
+	}
+
+	err := seg.w.Flush()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	f, err := os.Open(seg.f.Name())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer f.Close()
+
+	c := pipelineConfig{
+		Logger: j.Logger,
+
+		Compression: j.Compression,
+		EncPass:     j.EncPass,
+		Uploader:    j.Uploader,
+
+		UploadRateLimit: j.UploadRateLimit,
+
+		Name:     fmt.Sprintf("%s%s%s-%d-%d%s", j.Prefix, journalNameInfix, getTimeStamp(), seg.from, seg.to, journalExt),
+		Source:   f,
+		Metadata: snapshotMetadata(j.Member, seg.to),
+	}
+
+	name, _, _, err := runPipeline(c)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	j.Logger.Log("level", "info", "msg", "Etcd v3 journal segment uploaded successfully", "name", name, "from", seg.from, "to", seg.to, "events", seg.events)
+
+	seg.f.Close()
+	os.Remove(seg.f.Name())
+	j.segment = nil
+
+	return nil
+}
+
+// ParseJournalSegment returns the journal segment of the backups of prefix
+// described by name. It returns false for other backups, e.g. snapshots or
+// journals of guest clusters under prefix.
+func ParseJournalSegment(prefix string, name string) (JournalSegment, bool) {
+	rest := strings.TrimPrefix(name, prefix+journalNameInfix)
+	if rest == name || len(rest) < len(TimestampFormat) {
+		return JournalSegment{}, false
+	}
+	t, err := time.ParseInLocation(TimestampFormat, rest[:len(TimestampFormat)], time.Local)
+	if err != nil {
+		return JournalSegment{}, false
+	}
+
+	// -<from>-<to> follows the time
+	rest = rest[len(TimestampFormat):]
+	i := strings.Index(rest, journalExt)
+	if i < 0 {
+		return JournalSegment{}, false
+	}
+	revisions := strings.Split(strings.TrimPrefix(rest[:i], "-"), "-")
+	if len(revisions) != 2 {
+		return JournalSegment{}, false
+	}
+	from, err := strconv.ParseInt(revisions[0], 10, 64)
+	if err != nil {
+		return JournalSegment{}, false
+	}
+	to, err := strconv.ParseInt(revisions[1], 10, 64)
+	if err != nil {
+		return JournalSegment{}, false
+	}
+
+	s := JournalSegment{
+		Name: name,
+		From: from,
+		To:   to,
+		Time: t,
+	}
+
+	return s, true
+}
+
+// SelectSegments returns the segments, in order, that hold the changes after
+// revision up to toRevision. If at is set only segments closed at or before it
+// are used. The segments must follow each other without gaps. With toRevision
+// 0 the segments up to the first gap are returned, e.g. where the journal
+// stopped for the next snapshot.
+func SelectSegments(segments []JournalSegment, revision int64, toRevision int64, at time.Time) ([]JournalSegment, error) {
+	sorted := append([]JournalSegment(nil), segments...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].From != sorted[j].From {
+			return sorted[i].From < sorted[j].From
+		}
+		return sorted[i].To > sorted[j].To
+	})
+
+	var selected []JournalSegment
+	next := revision + 1
+	for _, s := range sorted {
+		if toRevision != 0 && next > toRevision {
+			break
+		}
+		if !at.IsZero() && s.Time.After(at) {
+			// a segment uploaded again on a retry may close after one
+			// holding the same revisions
+			continue
+		}
+		if s.To < next {
+			// covered by a segment before, e.g. uploaded twice on retries
+			continue
+		}
+		if s.From > next && toRevision == 0 {
+			break
+		}
+		if s.From > next {
+			return nil, microerror.Maskf(journalGapError, "no journal segment holds revision %d", next)
+		}
+
+		selected = append(selected, s)
+		next = s.To + 1
+	}
+
+	if toRevision != 0 && next <= toRevision {
+		return nil, microerror.Maskf(journalGapError, "journal ends at revision %d, before revision %d", next-1, toRevision)
+	}
+
+	return selected, nil
+}
+
+// ReadJournal reads the events of the journal segment at path.
+func ReadJournal(path string) ([]JournalEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer f.Close()
+
+	var events []JournalEvent
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var e JournalEvent
+		err = dec.Decode(&e)
+		if err != nil {
+			return nil, microerror.Maskf(invalidSnapshotError, "%s: %s", path, err)
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// JournalChanges adds the events after revision up to toRevision, 0 for
+// all, to changes, which keeps the last change of every key. Applying them
+// gives the same keys as applying all events in order. Events of later
+// segments are added with later calls.
+func JournalChanges(changes map[string]JournalEvent, events []JournalEvent, revision int64, toRevision int64) {
+	for _, e := range events {
+		if e.ModRevision <= revision || (toRevision != 0 && e.ModRevision > toRevision) {
+			continue
+		}
+		changes[e.Key] = e
+	}
+}
+
+// ApplyChanges returns kvs, sorted by key, with the changes selected by m
+// applied.
+func ApplyChanges(kvs []KeyValue, changes map[string]JournalEvent, m Matcher) []KeyValue {
+	keys := map[string]KeyValue{}
+	for _, kv := range kvs {
+		keys[kv.Key] = kv
+	}
+	for k, e := range changes {
+		if !m.Match(k) {
+			continue
+		}
+		if e.Type == EventDelete {
+			delete(keys, k)
+		} else {
+			keys[k] = e.KeyValue
+		}
+	}
+
+	return sortKeyValues(keys)
+}
+
+// ApplyJournal writes the changes to the cluster c, one key at a time in key
+// order. Values are written without their lease, leases of the journal do
+// not exist in a restored cluster.
+func ApplyJournal(c Connection, changes map[string]JournalEvent, logger micrologger.Logger) error {
+	etcdctlEnvs := []string{"ETCDCTL_API=3"}
+
+	keys := make([]string, 0, len(changes))
+	for k := range changes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		e := changes[k]
+		args := []string{"put"}
+		if e.Type == EventDelete {
+			args = []string{"del"}
+		}
+		args = append(args, c.args(c.Endpoints)...)
+		// keys follow --, so they are never taken for flags
+		args = append(args, "--", e.Key)
+
+		var value *bytes.Reader
+		switch {
+		case e.Type == EventDelete:
+		case len(e.Value) == 0:
+			// etcdctl rejects empty values on stdin
+			args = append(args, "")
+		default:
+			// values are passed on stdin, they may be binary or secret
+			value = bytes.NewReader(e.Value)
+		}
+
+		var err error
+		if value != nil {
+			_, err = execCmdStdin(etcdctlCmd, args, etcdctlEnvs, value, logger)
+		} else {
+			_, err = execCmd(etcdctlCmd, args, etcdctlEnvs, logger)
+		}
+		if err != nil {
+			return microerror.Maskf(err, "%s %s", e.Type, e.Key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,436 @@
+package etcd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+// fakeEtcdctl puts an etcdctl running script first in $PATH. The returned
+// function restores $PATH and removes the script.
+func fakeEtcdctl(t *testing.T, script string) (string, func()) {
+	dir, err := ioutil.TempDir("", "etcdctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, etcdctlCmd), []byte("#!/bin/sh\n"+script), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	return dir, func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+// segmentUploader keeps every uploaded segment, failing while err is set.
+type segmentUploader struct {
+	err      error
+	segments map[string][]byte
+}
+
+func (u *segmentUploader) Upload(name string, r io.Reader, metadata map[string]string) (storage.Result, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return storage.Result{Size: -1}, err
+	}
+	if u.err != nil {
+		return storage.Result{Size: -1}, u.err
+	}
+	if u.segments == nil {
+		u.segments = map[string][]byte{}
+	}
+	u.segments[name] = data
+
+	return storage.Result{Size: int64(len(data))}, nil
+}
+
+func testJournal(t *testing.T, u storage.Uploader, tmpDir string) *EtcdJournal {
+	return &EtcdJournal{
+		Compression:     Compression{Algorithm: CompressionNone},
+		Logger:          testLogger(t),
+		Prefix:          "host",
+		Revision:        5,
+		SegmentInterval: time.Hour,
+		TmpDir:          tmpDir,
+		Uploader:        u,
+	}
+}
+
+func testResponse(revision int64, keys ...string) watchResponse {
+	var r watchResponse
+	for _, k := range keys {
+		e := r.Events
+		e = append(e, struct {
+			Type int          `json:"type"`
+			Kv   etcdKeyValue `json:"kv"`
+		}{Kv: etcdKeyValue{Key: []byte(k), Value: []byte(k), ModRevision: revision}})
+		r.Events = e
+	}
+
+	return r
+}
+
+// uploadedSegments returns the segments of u in revision order with their
+// events.
+func uploadedSegments(t *testing.T, u *segmentUploader) ([]JournalSegment, [][]JournalEvent) {
+	dir, err := ioutil.TempDir("", "segments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var segments []JournalSegment
+	for name := range u.segments {
+		s, ok := ParseJournalSegment("host", name)
+		if !ok {
+			t.Fatalf("expected a journal segment, got %s", name)
+		}
+		segments = append(segments, s)
+	}
+	segments, err = SelectSegments(segments, segments[0].From-1, 0, time.Time{})
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+
+	var events [][]JournalEvent
+	for i, s := range segments {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		err := ioutil.WriteFile(path, u.segments[s.Name], 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := ReadJournal(path)
+		if err != nil {
+			t.Fatalf("expected no error, got %#v", err)
+		}
+		events = append(events, e)
+	}
+
+	return segments, events
+}
+
+func Test_EtcdJournal_SegmentRotation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	u := &segmentUploader{}
+	j := testJournal(t, u, tmpDir)
+
+	// segments without changes are not uploaded
+	err = j.flush()
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	if len(u.segments) != 0 {
+		t.Fatalf("expected no segment, got %v", u.segments)
+	}
+
+	err = j.record(testResponse(6, "a", "b"))
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	err = j.flush()
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+
+	// a failed upload keeps the segment for the next one
+	err = j.record(testResponse(8, "c"))
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	u.err = fmt.Errorf("bucket gone")
+	err = j.flush()
+	if err == nil {
+		t.Fatalf("expected the upload error")
+	}
+	u.err = nil
+	err = j.record(testResponse(9, "a"))
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	err = j.flush()
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+
+	if j.Revision != 10 {
+		t.Fatalf("expected revision 10 to be recorded next, got %d", j.Revision)
+	}
+
+	segments, events := uploadedSegments(t, u)
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %#v", segments)
+	}
+	if segments[0].From != 5 || segments[0].To != 6 || segments[1].From != 7 || segments[1].To != 9 {
+		t.Fatalf("expected segments 5-6 and 7-9, got %#v", segments)
+	}
+
+	var keys [][]string
+	for _, e := range events {
+		var k []string
+		for _, event := range e {
+			k = append(k, fmt.Sprintf("%s@%d", event.Key, event.ModRevision))
+		}
+		keys = append(keys, k)
+	}
+	expected := [][]string{{"a@6", "b@6"}, {"c@8", "a@9"}}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected events %v, got %v", expected, keys)
+	}
+
+	files, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("expected uploaded segments to be removed, got %d files", len(files))
+	}
+}
+
+func Test_EtcdJournal_Run(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// a and b are put at revision 5, a is deleted at revision 6
+	_, cleanup := fakeEtcdctl(t, `echo '{"Events":[{"kv":{"key":"YQ==","value":"MQ==","mod_revision":5}},{"kv":{"key":"Yg==","value":"Mg==","mod_revision":5}}]}'
+echo '{"Events":[{"type":1,"kv":{"key":"YQ==","mod_revision":6}}]}'
+exec sleep 60
+`)
+	defer cleanup()
+
+	u := &segmentUploader{}
+	j := testJournal(t, u, tmpDir)
+
+	stop := make(chan struct{})
+	time.AfterFunc(500*time.Millisecond, func() { close(stop) })
+	err = j.Run(stop)
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+
+	segments, events := uploadedSegments(t, u)
+	if len(segments) != 1 || segments[0].From != 5 || segments[0].To != 6 {
+		t.Fatalf("expected segment 5-6, got %#v", segments)
+	}
+	if len(events[0]) != 3 || events[0][2].Type != EventDelete || events[0][2].Key != "a" {
+		t.Fatalf("expected 2 puts and the delete of a, got %#v", events[0])
+	}
+}
+
+func Test_EtcdJournal_Run_Compacted(t *testing.T) {
+	_, cleanup := fakeEtcdctl(t, `echo '{"CompactRevision":9}'
+exec sleep 60
+`)
+	defer cleanup()
+
+	j := testJournal(t, &segmentUploader{}, "")
+
+	err := j.Run(make(chan struct{}))
+	if !IsJournalCompacted(err) {
+		t.Fatalf("expected journal compacted error, got %#v", err)
+	}
+}
+
+func Test_EtcdJournal_Run_RecordError(t *testing.T) {
+	// etcdctl writes changes faster than they are read
+	dir, cleanup := fakeEtcdctl(t, `echo $$ > "$(dirname "$0")/pid"
+while true; do
+	echo '{"Events":[{"kv":{"key":"YQ==","value":"MQ==","mod_revision":5}}]}'
+done
+`)
+	defer cleanup()
+
+	// segments cannot be written to a missing directory
+	j := testJournal(t, &segmentUploader{}, "/nonexistent")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- j.Run(make(chan struct{}))
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("expected the record error")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Run did not return")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "pid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = syscall.Kill(pid, 0)
+	if err != syscall.ESRCH {
+		t.Fatalf("expected etcdctl to have exited, got %#v", err)
+	}
+}
+
+func Test_SelectSegments(t *testing.T) {
+	at := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	segment := func(from, to int64, minutes int) JournalSegment {
+		return JournalSegment{
+			Name: fmt.Sprintf("%d-%d", from, to),
+			From: from,
+			To:   to,
+			Time: at.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+	segments := []JournalSegment{
+		segment(21, 30, 20),
+		segment(11, 20, 10),
+		// uploaded again on a retry, together with the next changes
+		segment(11, 25, 15),
+		segment(31, 40, 30),
+		// the journal of the next snapshot, at revision 50
+		segment(51, 60, 60),
+	}
+
+	testCases := []struct {
+		name         string
+		revision     int64
+		toRevision   int64
+		at           time.Time
+		expected     []string
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: all segments up to the first gap",
+			revision: 10,
+			expected: []string{"11-25", "21-30", "31-40"},
+		},
+		{
+			name:       "case 1: up to a revision",
+			revision:   10,
+			toRevision: 22,
+			expected:   []string{"11-25"},
+		},
+		{
+			name:     "case 2: up to a time",
+			revision: 10,
+			at:       at.Add(12 * time.Minute),
+			expected: []string{"11-20"},
+		},
+		{
+			name:       "case 3: snapshot after the first segment",
+			revision:   20,
+			toRevision: 40,
+			expected:   []string{"11-25", "21-30", "31-40"},
+		},
+		{
+			name:     "case 4: no segment after the snapshot",
+			revision: 40,
+		},
+		{
+			name:         "case 5: revision in a gap",
+			revision:     10,
+			toRevision:   45,
+			errorMatcher: IsJournalGap,
+		},
+		{
+			name:         "case 6: revision after the journal",
+			revision:     50,
+			toRevision:   70,
+			errorMatcher: IsJournalGap,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := SelectSegments(segments, tc.revision, tc.toRevision, tc.at)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			var names []string
+			for _, s := range selected {
+				names = append(names, s.Name)
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func Test_ApplyJournal(t *testing.T) {
+	// every call is logged with its stdin
+	dir, cleanup := fakeEtcdctl(t, `log="$(dirname "$0")/calls"
+echo "$*" >> "$log"
+if [ "$1" = put ] && [ "$#" -eq 5 ]; then
+	echo "stdin=$(cat)" >> "$log"
+fi
+if [ "$3" = fail ]; then
+	exit 1
+fi
+`)
+	defer cleanup()
+
+	changes := map[string]JournalEvent{
+		"b": {Type: EventDelete, KeyValue: KeyValue{Key: "b"}},
+		"a": {Type: EventPut, KeyValue: KeyValue{Key: "a", Value: []byte("secret"), Lease: 7}},
+		"c": {Type: EventPut, KeyValue: KeyValue{Key: "c"}},
+		"-": {Type: EventPut, KeyValue: KeyValue{Key: "--flag", Value: []byte("v")}},
+	}
+	err := ApplyJournal(Connection{Endpoints: "https://etcd:2379"}, changes, testLogger(t))
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keys are applied in order, values are passed on stdin and empty
+	// values as argument
+	expected := `put --endpoints https://etcd:2379 -- --flag
+stdin=v
+put --endpoints https://etcd:2379 -- a
+stdin=secret
+del --endpoints https://etcd:2379 -- b
+put --endpoints https://etcd:2379 -- c 
+`
+	if string(data) != expected {
+		t.Fatalf("expected calls\n%s\ngot\n%s", expected, data)
+	}
+
+	err = ApplyJournal(Connection{}, map[string]JournalEvent{"fail": {Type: EventDelete, KeyValue: KeyValue{Key: "fail"}}}, testLogger(t))
+	if err == nil {
+		t.Fatalf("expected the etcdctl error")
+	}
+}
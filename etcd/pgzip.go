@@ -0,0 +1,157 @@
+package etcd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+)
+
+// pgzipBlockSize is the amount of input compressed by one worker at a time.
+const pgzipBlockSize = 1 << 20
+
+// parallelGzipWriter compresses blocks of its input on all CPUs. Every block
+// becomes a gzip member of its own. Concatenated members are a valid gzip
+// stream, so gunzip and compress/gzip read the output like any other gzip
+// file.
+type parallelGzipWriter struct {
+	w     io.Writer
+	level int
+
+	buf    []byte
+	blocks int
+	// pending holds the results of the blocks in input order.
+	pending chan chan compressedBlock
+	done    chan struct{}
+
+	mutex  sync.Mutex
+	err    error
+	closed bool
+}
+
+type compressedBlock struct {
+	data []byte
+	err  error
+}
+
+func newParallelGzipWriter(w io.Writer, level int) *parallelGzipWriter {
+	pw := &parallelGzipWriter{
+		w:     w,
+		level: level,
+
+		buf:     make([]byte, 0, pgzipBlockSize),
+		pending: make(chan chan compressedBlock, runtime.NumCPU()),
+		done:    make(chan struct{}),
+	}
+
+	go pw.writeLoop()
+
+	return pw
+}
+
+func (pw *parallelGzipWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(pw.buf[len(pw.buf):cap(pw.buf)], p)
+		pw.buf = pw.buf[:len(pw.buf)+n]
+		p = p[n:]
+		written += n
+
+		if len(pw.buf) == cap(pw.buf) {
+			err := pw.flush()
+			if err != nil {
+				return written, microerror.Mask(err)
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// flush hands the buffered block to a worker. It blocks while all workers
+// are busy.
+func (pw *parallelGzipWriter) flush() error {
+	err := pw.error()
+	if err != nil {
+		return err
+	}
+
+	block := pw.buf
+	pw.buf = make([]byte, 0, pgzipBlockSize)
+	pw.blocks++
+
+	c := make(chan compressedBlock, 1)
+	pw.pending <- c
+
+	go func() {
+		var out bytes.Buffer
+		gz, err := gzip.NewWriterLevel(&out, pw.level)
+		if err != nil {
+			c <- compressedBlock{err: err}
+			return
+		}
+		_, err = gz.Write(block)
+		if err == nil {
+			err = gz.Close()
+		}
+		c <- compressedBlock{data: out.Bytes(), err: err}
+	}()
+
+	return nil
+}
+
+// writeLoop writes compressed blocks in input order. After an error the
+// remaining blocks are discarded.
+func (pw *parallelGzipWriter) writeLoop() {
+	defer close(pw.done)
+
+	for c := range pw.pending {
+		b := <-c
+		if pw.error() != nil {
+			continue
+		}
+
+		err := b.err
+		if err == nil {
+			_, err = pw.w.Write(b.data)
+		}
+		if err != nil {
+			pw.mutex.Lock()
+			pw.err = err
+			pw.mutex.Unlock()
+		}
+	}
+}
+
+func (pw *parallelGzipWriter) error() error {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+
+	return pw.err
+}
+
+// Close compresses the remaining input and waits until everything is
+// written.
+func (pw *parallelGzipWriter) Close() error {
+	if pw.closed {
+		return microerror.Mask(pw.error())
+	}
+	pw.closed = true
+
+	// an empty input still needs one member to be valid gzip
+	var err error
+	if len(pw.buf) > 0 || pw.blocks == 0 {
+		err = pw.flush()
+	}
+	close(pw.pending)
+	<-pw.done
+
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return microerror.Mask(pw.error())
+}
@@ -0,0 +1,245 @@
+package etcd
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+// Stages of a backup. All but create are stages of the backup pipeline.
+const (
+	StageCreate   = "create"
+	StageRead     = "read"
+	StageCompress = "compress"
+	StageEncrypt  = "encrypt"
+	StageUpload   = "upload"
+)
+
+// StageStats describe the work of one pipeline stage. Busy excludes the time
+// the stage waited for its neighbours, so Bytes/Busy is about the throughput
+// the stage could reach on its own. The stage with the lowest throughput is
+// the bottleneck. Busy is approximate for stages writing from several
+// goroutines, e.g. parallel compression.
+type StageStats struct {
+	Name string
+	// Bytes is the amount of data the stage produced.
+	Bytes int64
+	Busy  time.Duration
+}
+
+type pipelineConfig struct {
+	Logger micrologger.Logger
+
+	Compression Compression
+	EncPass     string
+	Uploader    storage.Uploader
+
+	// ReadRateLimit and UploadRateLimit are in bytes per second, 0 means
+	// unlimited.
+	ReadRateLimit   int64
+	UploadRateLimit int64
+
+	// Name is the backup file name without compression and encryption
+	// extensions.
+	Name   string
+	Source io.Reader
+	// Metadata is stored in the backup manifest.
+	Metadata map[string]string
+	// VerifyReplication waits for the replication of the upload, if the
+	// uploader supports it. Only snapshots set it, the journal and exports
+	// must not be held up by the replication timeout.
+	VerifyReplication bool
+
+	// compress and encrypt wrap the output of their stage. They default to
+	// Compression and, with EncPass set, OpenPGP encryption.
+	compress func(io.Writer) (io.WriteCloser, error)
+	encrypt  func(io.Writer) (io.WriteCloser, error)
+}
+
+// errPipelineAborted unblocks stages writing to a stage that stopped.
+var errPipelineAborted = microerror.New("pipeline aborted")
+
+// runPipeline compresses, encrypts and uploads the source. All stages run
+// at the same time and pass chunks through pipes, so a backup takes about as
+// long as its slowest stage instead of the sum of all. Replication of a
+// successful upload is verified after the stages are timed, if enabled in c.
+// It returns the
+// name the backup was uploaded under.
+func runPipeline(c pipelineConfig) (string, storage.Result, []StageStats, error) {
+	name := c.Name + c.Compression.Ext()
+	if c.EncPass != "" {
+		name += encExt
+	} else {
+		c.Logger.Log("level", "warning", "msg", "No passphrase provided. Skipping backup encryption")
+	}
+	if c.compress == nil {
+		c.compress = c.Compression.newWriter
+	}
+	if c.encrypt == nil && c.EncPass != "" {
+		c.encrypt = func(w io.Writer) (io.WriteCloser, error) {
+			return openpgp.SymmetricallyEncrypt(w, []byte(c.EncPass), nil, nil)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var readStats, compressStats, encryptStats StageStats
+	var compressErr, encryptErr error
+
+	// read and compress
+	src := &timedReader{r: throttle(c.Source, c.ReadRateLimit)}
+	compressedR, compressedW := io.Pipe()
+	compressOut := &timedWriter{w: compressedW}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		start := time.Now()
+		compressErr = copyThrough(compressOut, src, c.compress)
+		total := time.Since(start)
+
+		readStats = StageStats{Name: StageRead, Bytes: src.n, Busy: src.busy}
+		compressStats = StageStats{Name: StageCompress, Bytes: compressOut.n, Busy: busy(total, src.busy, compressOut.busy)}
+		compressedW.CloseWithError(compressErr)
+	}()
+
+	// encrypt
+	last := compressedR
+	if c.encrypt != nil {
+		encryptIn := &timedReader{r: compressedR}
+		encryptedR, encryptedW := io.Pipe()
+		encryptOut := &timedWriter{w: encryptedW}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			encryptErr = copyThrough(encryptOut, encryptIn, c.encrypt)
+			total := time.Since(start)
+
+			encryptStats = StageStats{Name: StageEncrypt, Bytes: encryptOut.n, Busy: busy(total, encryptIn.busy, encryptOut.busy)}
+			encryptedW.CloseWithError(encryptErr)
+			compressedR.CloseWithError(errPipelineAborted)
+		}()
+
+		last = encryptedR
+	}
+
+	// upload
+	// throttling counts as busy time of the throttled stage
+	uploadIn := &timedReader{r: last}
+	start := time.Now()
+	result, uploadErr := c.Uploader.Upload(name, throttle(uploadIn, c.UploadRateLimit), c.Metadata)
+	total := time.Since(start)
+	last.CloseWithError(errPipelineAborted)
+
+	wg.Wait()
+
+	stats := []StageStats{
+		readStats,
+		compressStats,
+	}
+	if c.encrypt != nil {
+		stats = append(stats, encryptStats)
+	}
+	stats = append(stats, StageStats{Name: StageUpload, Bytes: uploadIn.n, Busy: busy(total, uploadIn.busy)})
+
+	// report the stage that failed first, the stages before it only see
+	// the aborted pipeline and compress sees the errors of read
+	for _, e := range []struct {
+		stage string
+		err   error
+	}{
+		{stage: StageRead, err: src.err},
+		{stage: StageCompress, err: compressErr},
+		{stage: StageEncrypt, err: encryptErr},
+		{stage: StageUpload, err: uploadErr},
+	} {
+		if e.err != nil && microerror.Cause(e.err) != errPipelineAborted {
+			return name, result, stats, stageError(e.stage, e.err)
+		}
+	}
+
+	// waiting for replication is no upload time, so it is timed by the
+	// replication results themselves
+	if v, ok := c.Uploader.(storage.ReplicationVerifier); ok && c.VerifyReplication {
+		result.Replication = v.VerifyReplication(name, result)
+	}
+
+	return name, result, stats, nil
+}
+
+// busy returns total without the waits. Waits of concurrent readers and
+// writers may overlap, the result is never negative.
+func busy(total time.Duration, waits ...time.Duration) time.Duration {
+	for _, w := range waits {
+		total -= w
+	}
+	if total < 0 {
+		return 0
+	}
+
+	return total
+}
+
+// copyThrough copies r to the writer created by wrap around w. The wrapping
+// writer is closed, e.g. to flush compression, before it returns.
+func copyThrough(w io.Writer, r io.Reader, wrap func(io.Writer) (io.WriteCloser, error)) error {
+	wc, err := wrap(w)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_, err = io.Copy(wc, r)
+	if cerr := wc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// timedReader counts bytes and the time spent waiting in Read. It keeps the
+// first error other than io.EOF.
+type timedReader struct {
+	r    io.Reader
+	n    int64
+	busy time.Duration
+	err  error
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(p)
+	t.busy += time.Since(start)
+	t.n += int64(n)
+	if err != nil && err != io.EOF && t.err == nil {
+		t.err = err
+	}
+
+	return n, err
+}
+
+// timedWriter counts bytes and the time spent waiting in Write.
+type timedWriter struct {
+	w    io.Writer
+	n    int64
+	busy time.Duration
+}
+
+func (t *timedWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.w.Write(p)
+	t.busy += time.Since(start)
+	t.n += int64(n)
+
+	return n, err
+}
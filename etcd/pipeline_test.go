@@ -0,0 +1,329 @@
+package etcd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+func testLogger(t *testing.T) micrologger.Logger {
+	logger, err := micrologger.New(micrologger.Config{IOWriter: ioutil.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return logger
+}
+
+// fakeUploader reads the whole backup and fails with err afterwards.
+type fakeUploader struct {
+	err  error
+	data []byte
+}
+
+func (u *fakeUploader) Upload(name string, r io.Reader, metadata map[string]string) (storage.Result, error) {
+	data, err := ioutil.ReadAll(r)
+	u.data = data
+	if err != nil {
+		return storage.Result{Size: -1}, err
+	}
+	if u.err != nil {
+		return storage.Result{Size: -1}, u.err
+	}
+
+	return storage.Result{Size: int64(len(data))}, nil
+}
+
+// replicatedUploader takes delay to verify the replication of an upload.
+type replicatedUploader struct {
+	fakeUploader
+	delay    time.Duration
+	verified []string
+}
+
+func (u *replicatedUploader) VerifyReplication(name string, result storage.Result) []storage.ReplicationResult {
+	time.Sleep(u.delay)
+	u.verified = append(u.verified, name)
+
+	return []storage.ReplicationResult{{Target: "dr", Status: storage.ReplicationReplicated, Lag: u.delay}}
+}
+
+func Test_runPipeline_VerifiesReplicationAfterUpload(t *testing.T) {
+	u := &replicatedUploader{delay: 300 * time.Millisecond}
+	c := pipelineConfig{
+		Logger:      testLogger(t),
+		Compression: Compression{Algorithm: CompressionNone},
+		Uploader:    u,
+		Name:        "backup.db",
+		Source:      bytes.NewReader([]byte("snapshot")),
+
+		VerifyReplication: true,
+	}
+
+	start := time.Now()
+	name, result, stats, err := runPipeline(c)
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	if time.Since(start) < u.delay {
+		t.Fatalf("expected runPipeline to wait for the replication")
+	}
+
+	if len(u.verified) != 1 || u.verified[0] != name {
+		t.Fatalf("expected replication of %s to be verified once, got %v", name, u.verified)
+	}
+	if len(result.Replication) != 1 || result.Replication[0].Status != storage.ReplicationReplicated {
+		t.Fatalf("expected the replication result, got %#v", result.Replication)
+	}
+	for _, s := range stats {
+		if s.Name == StageUpload && s.Busy >= u.delay {
+			t.Fatalf("expected the replication wait not to count as upload time, got %s", s.Busy)
+		}
+	}
+
+	// failed uploads are not verified
+	u.verified = nil
+	u.err = errors.New("bucket gone")
+	c.Source = bytes.NewReader([]byte("snapshot"))
+	_, _, _, err = runPipeline(c)
+	if err == nil {
+		t.Fatalf("expected the upload error")
+	}
+	if len(u.verified) != 0 {
+		t.Fatalf("expected no replication check after a failed upload, got %v", u.verified)
+	}
+
+	// journal segments and exports do not wait for the replication
+	u.err = nil
+	c.VerifyReplication = false
+	c.Source = bytes.NewReader([]byte("segment"))
+	_, result, _, err = runPipeline(c)
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	if len(u.verified) != 0 || result.Replication != nil {
+		t.Fatalf("expected no replication check without VerifyReplication, got %v", u.verified)
+	}
+}
+
+// failingReader returns err after the first read.
+type failingReader struct {
+	err  error
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, r.err
+	}
+	r.read = true
+
+	return copy(p, "snapshot"), nil
+}
+
+// failingWriter fails every write with err.
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func (w failingWriter) Close() error {
+	return nil
+}
+
+func failingStage(err error) func(io.Writer) (io.WriteCloser, error) {
+	return func(io.Writer) (io.WriteCloser, error) {
+		return failingWriter{err: err}, nil
+	}
+}
+
+func Test_runPipeline_FailedStage(t *testing.T) {
+	stageErr := errors.New("stage failed")
+
+	testCases := []struct {
+		name         string
+		config       func(c *pipelineConfig)
+		stage        string
+		errorMatcher func(error) bool
+	}{
+		{
+			name:   "case 0: all stages succeed",
+			config: func(c *pipelineConfig) {},
+		},
+		{
+			name: "case 1: read fails, compress only sees the read error",
+			config: func(c *pipelineConfig) {
+				c.Source = &failingReader{err: stageErr}
+			},
+			stage:        StageRead,
+			errorMatcher: IsReadFailed,
+		},
+		{
+			name: "case 2: compress fails",
+			config: func(c *pipelineConfig) {
+				c.compress = failingStage(stageErr)
+			},
+			stage:        StageCompress,
+			errorMatcher: IsCompressFailed,
+		},
+		{
+			name: "case 3: compress cannot start",
+			config: func(c *pipelineConfig) {
+				c.compress = func(io.Writer) (io.WriteCloser, error) {
+					return nil, stageErr
+				}
+			},
+			stage:        StageCompress,
+			errorMatcher: IsCompressFailed,
+		},
+		{
+			name: "case 4: encrypt fails",
+			config: func(c *pipelineConfig) {
+				c.encrypt = failingStage(stageErr)
+			},
+			stage:        StageEncrypt,
+			errorMatcher: IsEncryptFailed,
+		},
+		{
+			name: "case 5: upload fails",
+			config: func(c *pipelineConfig) {
+				c.Uploader = &fakeUploader{err: stageErr}
+			},
+			stage:        StageUpload,
+			errorMatcher: IsUploadFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := pipelineConfig{
+				Logger:      testLogger(t),
+				Compression: Compression{Algorithm: CompressionGzip},
+				EncPass:     "secret",
+				Uploader:    &fakeUploader{},
+				Name:        "backup.db",
+				Source:      bytes.NewReader([]byte("snapshot")),
+			}
+			tc.config(&c)
+
+			done := make(chan error, 1)
+			go func() {
+				_, _, _, err := runPipeline(c)
+				done <- err
+			}()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("runPipeline did not return")
+			}
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if FailedStage(err) != tc.stage {
+				t.Fatalf("expected stage %q, got %q", tc.stage, FailedStage(err))
+			}
+		})
+	}
+}
+
+// fakeBackup is a backup whose Create and Upload fail with createErr and
+// uploadErr.
+type fakeBackup struct {
+	createErr error
+	uploadErr error
+}
+
+func (b fakeBackup) Create() error {
+	return b.createErr
+}
+
+func (b fakeBackup) Upload() (storage.Result, []StageStats, error) {
+	if b.uploadErr != nil {
+		return storage.Result{}, nil, b.uploadErr
+	}
+
+	return storage.Result{Size: 8}, []StageStats{{Name: StageUpload, Bytes: 8}}, nil
+}
+
+func (b fakeBackup) Version() string {
+	return "v3"
+}
+
+func Test_FullBackup_FailedStage(t *testing.T) {
+	stageErr := errors.New("stage failed")
+
+	testCases := []struct {
+		name         string
+		backup       fakeBackup
+		stage        string
+		errorMatcher func(error) bool
+	}{
+		{
+			name:   "case 0: success",
+			backup: fakeBackup{},
+		},
+		{
+			name:         "case 1: create fails",
+			backup:       fakeBackup{createErr: stageErr},
+			stage:        StageCreate,
+			errorMatcher: IsCreateFailed,
+		},
+		{
+			name:         "case 2: pipeline stage fails",
+			backup:       fakeBackup{uploadErr: stageError(StageEncrypt, stageErr)},
+			stage:        StageEncrypt,
+			errorMatcher: IsEncryptFailed,
+		},
+		{
+			name:         "case 3: upload fails before the pipeline, e.g. opening the backup",
+			backup:       fakeBackup{uploadErr: stageErr},
+			stage:        StageRead,
+			errorMatcher: IsReadFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err, backupMetrics := FullBackup(tc.backup)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if FailedStage(err) != tc.stage {
+				t.Fatalf("expected stage %q, got %q", tc.stage, FailedStage(err))
+			}
+			if (err == nil) != (backupMetrics != nil) {
+				t.Fatalf("expected metrics only for successful backups, got %#v", backupMetrics)
+			}
+		})
+	}
+}
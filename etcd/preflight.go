@@ -0,0 +1,199 @@
+package etcd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+// Pre-flight checks, in the order they are run.
+const (
+	CheckHealth  = "health"
+	CheckLeader  = "leader"
+	CheckAlarms  = "alarms"
+	CheckDBSize  = "db_size"
+	CheckMembers = "members"
+)
+
+// dbSizeQuotaRatio is the share of the backend quota above which the
+// db_size check fails, as etcd raises NOSPACE soon after.
+const dbSizeQuotaRatio = 0.95
+
+// PreflightCheck is the outcome of one check run before a snapshot.
+type PreflightCheck struct {
+	Name    string
+	Passed  bool
+	Message string
+}
+
+// memberList is the JSON output of etcdctl member list.
+type memberList struct {
+	Members []struct {
+		ID   uint64 `json:"ID"`
+		Name string `json:"name"`
+	} `json:"members"`
+}
+
+// Preflight checks whether the cluster is in a state to take a snapshot
+// from: endpoint health, an agreed leader, no active alarms, the database
+// size against quota and a consistent member list. A quota of 0 disables the
+// db_size check. All checks are run, so every problem is reported at once.
+func Preflight(c Connection, quota int64, logger micrologger.Logger) []PreflightCheck {
+	statuses := endpointStatuses(c, logger)
+	alarms, alarmsErr := Alarms(c, logger)
+	members, membersErr := Members(c, logger)
+
+	return []PreflightCheck{
+		checkHealth(statuses),
+		checkLeader(statuses),
+		checkAlarms(alarms, alarmsErr),
+		checkDBSize(statuses, quota),
+		checkMembers(statuses, members, membersErr),
+	}
+}
+
+// FailedCheck returns the first failed check.
+func FailedCheck(checks []PreflightCheck) (PreflightCheck, bool) {
+	for _, c := range checks {
+		if !c.Passed {
+			return c, true
+		}
+	}
+
+	return PreflightCheck{}, false
+}
+
+// Members returns the names of the cluster members by member ID. Members
+// added but not started yet have an empty name.
+func Members(c Connection, logger micrologger.Logger) (map[uint64]string, error) {
+	etcdctlEnvs := []string{"ETCDCTL_API=3"}
+
+	args := append([]string{"member", "list", "-w", "json"}, c.args(c.Endpoints)...)
+	out, err := execCmd(etcdctlCmd, args, etcdctlEnvs, logger)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var l memberList
+	err = json.Unmarshal(out, &l)
+	if err != nil || len(l.Members) == 0 {
+		return nil, microerror.Maskf(invalidEtcdctlOutputError, "member list: %s", out)
+	}
+
+	members := map[uint64]string{}
+	for _, m := range l.Members {
+		members[m.ID] = m.Name
+	}
+
+	return members, nil
+}
+
+func checkHealth(statuses []MemberStatus) PreflightCheck {
+	var unhealthy []string
+	for _, m := range statuses {
+		if !m.Healthy() {
+			unhealthy = append(unhealthy, m.Endpoint)
+		}
+	}
+
+	switch {
+	case len(statuses) == 0:
+		return PreflightCheck{Name: CheckHealth, Message: "no endpoints"}
+	case len(unhealthy) == len(statuses):
+		return PreflightCheck{Name: CheckHealth, Message: "no endpoint is healthy: " + statuses[0].Err.Error()}
+	case len(unhealthy) > 0:
+		return PreflightCheck{Name: CheckHealth, Passed: true, Message: "unhealthy endpoints " + strings.Join(unhealthy, ",")}
+	}
+
+	return PreflightCheck{Name: CheckHealth, Passed: true}
+}
+
+func checkLeader(statuses []MemberStatus) PreflightCheck {
+	var leader uint64
+	for _, m := range statuses {
+		if !m.Healthy() {
+			continue
+		}
+		if m.Leader == 0 {
+			return PreflightCheck{Name: CheckLeader, Message: fmt.Sprintf("member %x has no leader", m.MemberID)}
+		}
+		if leader != 0 && m.Leader != leader {
+			return PreflightCheck{Name: CheckLeader, Message: fmt.Sprintf("members disagree on the leader: %x and %x", leader, m.Leader)}
+		}
+		leader = m.Leader
+	}
+
+	if leader == 0 {
+		return PreflightCheck{Name: CheckLeader, Message: "no healthy member to ask for the leader"}
+	}
+
+	return PreflightCheck{Name: CheckLeader, Passed: true, Message: fmt.Sprintf("leader %x", leader)}
+}
+
+func checkAlarms(alarms map[uint64][]string, err error) PreflightCheck {
+	if err != nil {
+		return PreflightCheck{Name: CheckAlarms, Message: "failed to list alarms: " + err.Error()}
+	}
+
+	var active []string
+	for id, names := range alarms {
+		for _, a := range names {
+			active = append(active, fmt.Sprintf("%s on member %x", a, id))
+		}
+	}
+	if len(active) > 0 {
+		sort.Strings(active)
+		return PreflightCheck{Name: CheckAlarms, Message: "active alarms: " + strings.Join(active, ", ")}
+	}
+
+	return PreflightCheck{Name: CheckAlarms, Passed: true}
+}
+
+func checkDBSize(statuses []MemberStatus, quota int64) PreflightCheck {
+	if quota <= 0 {
+		return PreflightCheck{Name: CheckDBSize, Passed: true, Message: "no quota configured"}
+	}
+
+	var largest MemberStatus
+	for _, m := range statuses {
+		if m.Healthy() && m.DBSize > largest.DBSize {
+			largest = m
+		}
+	}
+
+	message := fmt.Sprintf("%d of %d bytes", largest.DBSize, quota)
+	if float64(largest.DBSize) >= float64(quota)*dbSizeQuotaRatio {
+		return PreflightCheck{Name: CheckDBSize, Message: fmt.Sprintf("database of member %x close to quota: %s", largest.MemberID, message)}
+	}
+
+	return PreflightCheck{Name: CheckDBSize, Passed: true, Message: message}
+}
+
+func checkMembers(statuses []MemberStatus, members map[uint64]string, err error) PreflightCheck {
+	if err != nil {
+		return PreflightCheck{Name: CheckMembers, Message: "failed to list members: " + err.Error()}
+	}
+
+	for id, name := range members {
+		if name == "" {
+			return PreflightCheck{Name: CheckMembers, Message: fmt.Sprintf("member %x has not started", id)}
+		}
+	}
+	for _, m := range statuses {
+		if !m.Healthy() {
+			continue
+		}
+		if _, ok := members[m.MemberID]; !ok {
+			return PreflightCheck{Name: CheckMembers, Message: fmt.Sprintf("member %x at %s is not in the member list", m.MemberID, m.Endpoint)}
+		}
+		if _, ok := members[m.Leader]; !ok && m.Leader != 0 {
+			return PreflightCheck{Name: CheckMembers, Message: fmt.Sprintf("leader %x is not in the member list", m.Leader)}
+		}
+	}
+
+	return PreflightCheck{Name: CheckMembers, Passed: true, Message: fmt.Sprintf("%d members", len(members))}
+}
@@ -0,0 +1,173 @@
+package etcd
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_PreflightChecks(t *testing.T) {
+	unreachable := errors.New("connection refused")
+	healthy := []MemberStatus{
+		{Endpoint: "e1", MemberID: 1, Leader: 1, DBSize: 100},
+		{Endpoint: "e2", MemberID: 2, Leader: 1, DBSize: 90},
+		{Endpoint: "e3", MemberID: 3, Leader: 1, DBSize: 80},
+	}
+	members := map[uint64]string{1: "m1", 2: "m2", 3: "m3"}
+
+	testCases := []struct {
+		name   string
+		check  func() PreflightCheck
+		passed bool
+	}{
+		{
+			name:   "case 0: health passes",
+			check:  func() PreflightCheck { return checkHealth(healthy) },
+			passed: true,
+		},
+		{
+			name: "case 1: health passes with one unhealthy endpoint",
+			check: func() PreflightCheck {
+				return checkHealth([]MemberStatus{healthy[0], {Endpoint: "e2", Err: unreachable}})
+			},
+			passed: true,
+		},
+		{
+			name: "case 2: health fails without healthy endpoints",
+			check: func() PreflightCheck {
+				return checkHealth([]MemberStatus{{Endpoint: "e1", Err: unreachable}})
+			},
+			passed: false,
+		},
+		{
+			name:   "case 3: health fails without endpoints",
+			check:  func() PreflightCheck { return checkHealth(nil) },
+			passed: false,
+		},
+		{
+			name:   "case 4: leader passes",
+			check:  func() PreflightCheck { return checkLeader(healthy) },
+			passed: true,
+		},
+		{
+			name: "case 5: leader fails when a member has no leader",
+			check: func() PreflightCheck {
+				return checkLeader([]MemberStatus{healthy[0], {Endpoint: "e2", MemberID: 2}})
+			},
+			passed: false,
+		},
+		{
+			name: "case 6: leader fails when members disagree",
+			check: func() PreflightCheck {
+				return checkLeader([]MemberStatus{healthy[0], {Endpoint: "e2", MemberID: 2, Leader: 2}})
+			},
+			passed: false,
+		},
+		{
+			name: "case 7: leader ignores unhealthy members",
+			check: func() PreflightCheck {
+				return checkLeader([]MemberStatus{healthy[0], {Endpoint: "e2", Err: unreachable}})
+			},
+			passed: true,
+		},
+		{
+			name:   "case 8: alarms pass without alarms",
+			check:  func() PreflightCheck { return checkAlarms(map[uint64][]string{}, nil) },
+			passed: true,
+		},
+		{
+			name: "case 9: alarms fail with an active alarm",
+			check: func() PreflightCheck {
+				return checkAlarms(map[uint64][]string{2: {AlarmNoSpace}}, nil)
+			},
+			passed: false,
+		},
+		{
+			name:   "case 10: alarms fail when they cannot be listed",
+			check:  func() PreflightCheck { return checkAlarms(nil, unreachable) },
+			passed: false,
+		},
+		{
+			name:   "case 11: db size passes without quota",
+			check:  func() PreflightCheck { return checkDBSize(healthy, 0) },
+			passed: true,
+		},
+		{
+			name:   "case 12: db size passes below the threshold",
+			check:  func() PreflightCheck { return checkDBSize(healthy, 106) },
+			passed: true,
+		},
+		{
+			name:   "case 13: db size fails at the threshold",
+			check:  func() PreflightCheck { return checkDBSize(healthy, 105) },
+			passed: false,
+		},
+		{
+			name: "case 14: db size ignores unhealthy members",
+			check: func() PreflightCheck {
+				return checkDBSize([]MemberStatus{healthy[2], {Endpoint: "e1", DBSize: 100, Err: unreachable}}, 100)
+			},
+			passed: true,
+		},
+		{
+			name:   "case 15: members pass",
+			check:  func() PreflightCheck { return checkMembers(healthy, members, nil) },
+			passed: true,
+		},
+		{
+			name: "case 16: members fail with an unstarted member",
+			check: func() PreflightCheck {
+				return checkMembers(healthy, map[uint64]string{1: "m1", 2: "m2", 3: "m3", 4: ""}, nil)
+			},
+			passed: false,
+		},
+		{
+			name: "case 17: members fail when an endpoint is not listed",
+			check: func() PreflightCheck {
+				return checkMembers(healthy, map[uint64]string{1: "m1", 2: "m2"}, nil)
+			},
+			passed: false,
+		},
+		{
+			name: "case 18: members fail when the leader is not listed",
+			check: func() PreflightCheck {
+				return checkMembers([]MemberStatus{{Endpoint: "e2", MemberID: 2, Leader: 4}}, members, nil)
+			},
+			passed: false,
+		},
+		{
+			name:   "case 19: members fail when they cannot be listed",
+			check:  func() PreflightCheck { return checkMembers(healthy, nil, unreachable) },
+			passed: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := tc.check()
+			if c.Passed != tc.passed {
+				t.Fatalf("expected passed %t, got %#v", tc.passed, c)
+			}
+			if !c.Passed && c.Message == "" {
+				t.Fatalf("expected a message for the failed check %s", c.Name)
+			}
+		})
+	}
+}
+
+func Test_FailedCheck(t *testing.T) {
+	checks := []PreflightCheck{
+		{Name: CheckHealth, Passed: true},
+		{Name: CheckAlarms, Message: "active alarms"},
+		{Name: CheckDBSize, Message: "close to quota"},
+	}
+
+	c, failed := FailedCheck(checks)
+	if !failed || c.Name != CheckAlarms {
+		t.Fatalf("expected the alarms check, got %#v", c)
+	}
+
+	_, failed = FailedCheck(checks[:1])
+	if failed {
+		t.Fatalf("expected no failed check")
+	}
+}
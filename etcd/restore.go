@@ -0,0 +1,104 @@
+package etcd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/mholt/archiver"
+	"golang.org/x/crypto/openpgp"
+)
+
+// Unpack turns a downloaded backup file at srcPath back into the file or
+// directory created by etcdctl and writes it to dstDir. It returns the path
+// of the written file or directory. Decryption, decompression and tar are
+// detected from the file extensions. Encrypted backups need the passphrase
+// they were encrypted with. The download must be verified before, Unpack
+// does not check integrity.
+func Unpack(srcPath string, dstDir string, passphrase string) (string, error) {
+	name := filepath.Base(srcPath)
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	defer src.Close()
+
+	var r io.Reader = src
+	if strings.HasSuffix(name, encExt) {
+		if passphrase == "" {
+			return "", microerror.Maskf(missingPassphraseError, "%s is encrypted", name)
+		}
+
+		r, err = decryptReader(src, passphrase)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		name = strings.TrimSuffix(name, encExt)
+	}
+
+	rc, name, err := decompressReader(r, name)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	defer rc.Close()
+
+	// v2 backups and older v3 backups are tar archives.
+	if strings.HasSuffix(name, tarExt) {
+		err = archiver.Tar.Read(rc, dstDir)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		err = rc.Close()
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return filepath.Join(dstDir, strings.TrimSuffix(name, tarExt)), nil
+	}
+
+	dstPath := filepath.Join(dstDir, name)
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, os.FileMode(0600))
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	_, err = io.Copy(dst, rc)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = rc.Close()
+	}
+	if err != nil {
+		os.Remove(dstPath)
+		return "", microerror.Mask(err)
+	}
+
+	return dstPath, nil
+}
+
+// decryptReader returns the plaintext of data encrypted by the backup
+// pipeline.
+func decryptReader(r io.Reader, pass string) (io.Reader, error) {
+	prompted := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		// called again after a wrong passphrase, fail instead of looping
+		if prompted {
+			return nil, microerror.Maskf(decryptionFailedError, "wrong passphrase")
+		}
+		prompted = true
+		return []byte(pass), nil
+	}
+
+	md, err := openpgp.ReadMessage(r, nil, prompt, nil)
+	if IsDecryptionFailed(err) {
+		return nil, microerror.Mask(err)
+	} else if err != nil {
+		return nil, microerror.Maskf(decryptionFailedError, "%s", err)
+	}
+
+	return md.UnverifiedBody, nil
+}
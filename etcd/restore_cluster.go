@@ -0,0 +1,118 @@
+package etcd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+// DataDirExt is appended to the member name for its data directory, as etcd
+// does by default.
+const DataDirExt = ".etcd"
+
+// ClusterMember is a member of a cluster restored from a v3 snapshot.
+type ClusterMember struct {
+	Name    string
+	PeerURL string
+}
+
+// ParseMembers parses a comma separated member list of name=peer-url, i.e.
+// etcd1=https://10.0.0.1:2380,etcd2=https://10.0.0.2:2380. Names and peer
+// URLs must be unique.
+func ParseMembers(s string) ([]ClusterMember, error) {
+	var members []ClusterMember
+	names := map[string]bool{}
+	peerURLs := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, microerror.Maskf(invalidMemberError, "%q is not name=peer-url", item)
+		}
+		m := ClusterMember{
+			Name:    parts[0],
+			PeerURL: parts[1],
+		}
+		if strings.ContainsAny(m.Name, `/\`) || m.Name == "." || m.Name == ".." {
+			return nil, microerror.Maskf(invalidMemberError, "name %q is no valid directory name", m.Name)
+		}
+		u, err := url.Parse(m.PeerURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, microerror.Maskf(invalidMemberError, "peer url %q of %s is no http or https url", m.PeerURL, m.Name)
+		}
+		if names[m.Name] {
+			return nil, microerror.Maskf(invalidMemberError, "name %s is used twice", m.Name)
+		}
+		if peerURLs[m.PeerURL] {
+			return nil, microerror.Maskf(invalidMemberError, "peer url %s is used twice", m.PeerURL)
+		}
+		names[m.Name] = true
+		peerURLs[m.PeerURL] = true
+
+		members = append(members, m)
+	}
+	if len(members) == 0 {
+		return nil, microerror.Maskf(invalidMemberError, "no members")
+	}
+
+	return members, nil
+}
+
+// InitialCluster returns the --initial-cluster value of members.
+func InitialCluster(members []ClusterMember) string {
+	var b bytes.Buffer
+	for i, m := range members {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(m.Name + "=" + m.PeerURL)
+	}
+
+	return b.String()
+}
+
+// NewClusterToken returns a random initial cluster token, so that members of
+// the restored cluster never join the members of the cluster it replaces.
+func NewClusterToken() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return "etcd-restore-" + hex.EncodeToString(b), nil
+}
+
+// RestoreMember writes the data directory dataDir of member m of the cluster
+// members restored from the v3 snapshot at fpath. Every member must be
+// restored from the same snapshot with the same token. dataDir must not
+// exist.
+func RestoreMember(fpath string, m ClusterMember, members []ClusterMember, token string, dataDir string, logger micrologger.Logger) error {
+	etcdctlEnvs := []string{"ETCDCTL_API=3"}
+	etcdctlArgs := []string{
+		"snapshot",
+		"restore",
+		fpath,
+		"--name", m.Name,
+		"--initial-cluster", InitialCluster(members),
+		"--initial-cluster-token", token,
+		"--initial-advertise-peer-urls", m.PeerURL,
+		"--data-dir", dataDir,
+	}
+
+	out, err := execCmd(etcdctlCmd, etcdctlArgs, etcdctlEnvs, logger)
+	if err != nil {
+		return microerror.Maskf(err, "restore of member %s: %s", m.Name, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package etcd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParseMembers(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        string
+		expected     []ClusterMember
+		errorMatcher func(error) bool
+	}{
+		{
+			name:  "case 0: members",
+			input: "etcd1=https://10.0.0.1:2380, etcd2=http://10.0.0.2:2380,",
+			expected: []ClusterMember{
+				{Name: "etcd1", PeerURL: "https://10.0.0.1:2380"},
+				{Name: "etcd2", PeerURL: "http://10.0.0.2:2380"},
+			},
+			errorMatcher: nil,
+		},
+		{
+			name:         "case 1: no members",
+			input:        " , ",
+			errorMatcher: IsInvalidMember,
+		},
+		{
+			name:         "case 2: missing peer url",
+			input:        "etcd1",
+			errorMatcher: IsInvalidMember,
+		},
+		{
+			name:         "case 3: missing name",
+			input:        "=https://10.0.0.1:2380",
+			errorMatcher: IsInvalidMember,
+		},
+		{
+			name:         "case 4: name is no directory name",
+			input:        "../etcd1=https://10.0.0.1:2380",
+			errorMatcher: IsInvalidMember,
+		},
+		{
+			name:         "case 5: peer url is no http url",
+			input:        "etcd1=10.0.0.1:2380",
+			errorMatcher: IsInvalidMember,
+		},
+		{
+			name:         "case 6: name used twice",
+			input:        "etcd1=https://10.0.0.1:2380,etcd1=https://10.0.0.2:2380",
+			errorMatcher: IsInvalidMember,
+		},
+		{
+			name:         "case 7: peer url used twice",
+			input:        "etcd1=https://10.0.0.1:2380,etcd2=https://10.0.0.1:2380",
+			errorMatcher: IsInvalidMember,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			members, err := ParseMembers(tc.input)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !reflect.DeepEqual(members, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, members)
+			}
+			if tc.expected != nil && InitialCluster(members) != "etcd1=https://10.0.0.1:2380,etcd2=http://10.0.0.2:2380" {
+				t.Fatalf("unexpected initial cluster %s", InitialCluster(members))
+			}
+		})
+	}
+}
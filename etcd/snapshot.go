@@ -0,0 +1,188 @@
+package etcd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// keyBucket holds every revision of every key, keyed by revision.
+	keyBucket = "key"
+	// revisionKeySize is the size of a revision key: the main revision, '_'
+	// and the sub revision. Tombstones of deleted keys have 't' appended.
+	revisionKeySize = 17
+	tombstoneMark   = 't'
+)
+
+// Matcher selects keys by exact key or prefix. The zero value matches all
+// keys.
+type Matcher struct {
+	Keys     []string
+	Prefixes []string
+}
+
+// Match returns whether key is selected.
+func (m Matcher) Match(key string) bool {
+	if len(m.Keys) == 0 && len(m.Prefixes) == 0 {
+		return true
+	}
+	for _, k := range m.Keys {
+		if key == k {
+			return true
+		}
+	}
+	for _, p := range m.Prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadSnapshot reads the keys selected by m from the v3 snapshot at path as
+// of the snapshot revision, without restoring it. Deleted keys are left out.
+// It returns the keys sorted by key and the snapshot revision.
+func ReadSnapshot(path string, m Matcher) ([]KeyValue, int64, error) {
+	db, err := openBolt(path)
+	if err != nil {
+		return nil, 0, microerror.Mask(err)
+	}
+	defer db.Close()
+
+	// revisions are stored in order, the last one of a key is its state
+	latest := map[string]KeyValue{}
+	var revision int64
+	err = db.forEach(keyBucket, func(k, v []byte) error {
+		if len(k) < revisionKeySize {
+			return microerror.Maskf(invalidSnapshotError, "revision key %x", k)
+		}
+		revision = int64(binary.BigEndian.Uint64(k[0:8]))
+
+		kv, err := decodeKeyValue(v)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if !m.Match(kv.Key) {
+			return nil
+		}
+
+		if len(k) > revisionKeySize && k[revisionKeySize] == tombstoneMark {
+			delete(latest, kv.Key)
+			return nil
+		}
+		latest[kv.Key] = kv
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, microerror.Mask(err)
+	}
+
+	return sortKeyValues(latest), revision, nil
+}
+
+// ReadExport reads the keys selected by m from the logical export at path.
+// It returns the keys sorted by key.
+func ReadExport(path string, m Matcher) ([]KeyValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer f.Close()
+
+	keys := map[string]KeyValue{}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var kv KeyValue
+		err = dec.Decode(&kv)
+		if err != nil {
+			return nil, microerror.Maskf(invalidSnapshotError, "%s: %s", path, err)
+		}
+		if m.Match(kv.Key) {
+			keys[kv.Key] = kv
+		}
+	}
+
+	return sortKeyValues(keys), nil
+}
+
+func sortKeyValues(keys map[string]KeyValue) []KeyValue {
+	kvs := make([]KeyValue, 0, len(keys))
+	for _, kv := range keys {
+		kvs = append(kvs, kv)
+	}
+	sort.Slice(kvs, func(i, j int) bool {
+		return kvs[i].Key < kvs[j].Key
+	})
+
+	return kvs
+}
+
+// decodeKeyValue decodes an mvccpb.KeyValue protobuf message. The value is
+// copied, b belongs to a database page.
+func decodeKeyValue(b []byte) (KeyValue, error) {
+	var kv KeyValue
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return KeyValue{}, microerror.Maskf(invalidSnapshotError, "truncated key value")
+		}
+		b = b[n:]
+
+		field, wire := tag>>3, tag&7
+		switch wire {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return KeyValue{}, microerror.Maskf(invalidSnapshotError, "truncated key value")
+			}
+			b = b[n:]
+
+			switch field {
+			case 2:
+				kv.CreateRevision = int64(v)
+			case 3:
+				kv.ModRevision = int64(v)
+			case 4:
+				kv.Version = int64(v)
+			case 6:
+				kv.Lease = int64(v)
+			}
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return KeyValue{}, microerror.Maskf(invalidSnapshotError, "truncated key value")
+			}
+			v := b[n : n+int(l)]
+			b = b[n+int(l):]
+
+			switch field {
+			case 1:
+				kv.Key = string(v)
+			case 5:
+				kv.Value = append([]byte(nil), v...)
+			}
+		case 1:
+			if len(b) < 8 {
+				return KeyValue{}, microerror.Maskf(invalidSnapshotError, "truncated key value")
+			}
+			b = b[8:]
+		case 5:
+			if len(b) < 4 {
+				return KeyValue{}, microerror.Maskf(invalidSnapshotError, "truncated key value")
+			}
+			b = b[4:]
+		default:
+			return KeyValue{}, microerror.Maskf(invalidSnapshotError, "unknown wire type %d", wire)
+		}
+	}
+
+	return kv, nil
+}
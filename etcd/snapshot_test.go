@@ -0,0 +1,220 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata/snapshot.db is written by testdata/snapshotgen. Its last
+// revision is 211.
+const testSnapshot = "testdata/snapshot.db"
+
+func Test_ReadSnapshot(t *testing.T) {
+	kvs, revision, err := ReadSnapshot(testSnapshot, Matcher{})
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	if revision != 211 {
+		t.Fatalf("expected revision 211, got %d", revision)
+	}
+	// 200 events and 6 other keys, the deleted namespace is left out
+	if len(kvs) != 206 {
+		t.Fatalf("expected 206 keys, got %d", len(kvs))
+	}
+
+	keys := map[string]KeyValue{}
+	for _, kv := range kvs {
+		keys[kv.Key] = kv
+	}
+
+	testCases := []struct {
+		name     string
+		expected KeyValue
+	}{
+		{
+			name:     "case 0: updated key",
+			expected: KeyValue{Key: "/registry/namespaces/default", Value: []byte("default-v2"), CreateRevision: 2, ModRevision: 4, Version: 2},
+		},
+		{
+			name:     "case 1: key with lease",
+			expected: KeyValue{Key: "/registry/leases/kube-node-lease/node1", Value: []byte("lease"), CreateRevision: 6, ModRevision: 6, Version: 1, Lease: 0x694d7a5c2e1f0a03},
+		},
+		{
+			name:     "case 2: first put of a transaction",
+			expected: KeyValue{Key: "/registry/configmaps/default/a", Value: []byte("a"), CreateRevision: 7, ModRevision: 7, Version: 1},
+		},
+		{
+			name:     "case 3: second put of a transaction",
+			expected: KeyValue{Key: "/registry/configmaps/default/b", Value: []byte("b"), CreateRevision: 7, ModRevision: 7, Version: 1},
+		},
+		{
+			name:     "case 4: value on overflow pages",
+			expected: KeyValue{Key: "/registry/configmaps/default/big", Value: []byte(strings.Repeat("0123456789", 2000)), CreateRevision: 8, ModRevision: 8, Version: 1},
+		},
+		{
+			name:     "case 5: key created again after it was deleted",
+			expected: KeyValue{Key: "/registry/secrets/default/recreated", Value: []byte("v2"), CreateRevision: 211, ModRevision: 211, Version: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kv, ok := keys[tc.expected.Key]
+			if !ok {
+				t.Fatalf("expected key %s", tc.expected.Key)
+			}
+			if !bytes.Equal(kv.Value, tc.expected.Value) {
+				t.Fatalf("expected value %q, got %q", tc.expected.Value, kv.Value)
+			}
+			kv.Value = tc.expected.Value
+			if kv.Key != tc.expected.Key || kv.CreateRevision != tc.expected.CreateRevision || kv.ModRevision != tc.expected.ModRevision || kv.Version != tc.expected.Version || kv.Lease != tc.expected.Lease {
+				t.Fatalf("expected %#v, got %#v", tc.expected, kv)
+			}
+		})
+	}
+
+	if _, ok := keys["/registry/namespaces/gone"]; ok {
+		t.Fatalf("expected the deleted key to be left out")
+	}
+}
+
+func Test_ReadSnapshot_Matcher(t *testing.T) {
+	kvs, revision, err := ReadSnapshot(testSnapshot, Matcher{Keys: []string{"/registry/namespaces/gone", "/registry/namespaces/default"}, Prefixes: []string{"/registry/secrets/"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	// the revision is the snapshot's, not the one of the last selected key
+	if revision != 211 {
+		t.Fatalf("expected revision 211, got %d", revision)
+	}
+
+	var keys []string
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+	expected := "/registry/namespaces/default,/registry/secrets/default/recreated"
+	if strings.Join(keys, ",") != expected {
+		t.Fatalf("expected %s, got %v", expected, keys)
+	}
+}
+
+// corruptSnapshot copies the test snapshot, calls fn with its content and
+// page size and returns the path of the copy.
+func corruptSnapshot(t *testing.T, fn func(data []byte, pageSize int) []byte) string {
+	data, err := ioutil.ReadFile(testSnapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageSize := int(binary.LittleEndian.Uint32(data[boltPageHeader+8:]))
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "snapshot.db")
+	err = ioutil.WriteFile(path, fn(data, pageSize), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// firstPage returns the offset of the first page with flags.
+func firstPage(t *testing.T, data []byte, pageSize int, flags uint16) int {
+	for offset := 2 * pageSize; offset+pageSize <= len(data); offset += pageSize {
+		if binary.LittleEndian.Uint16(data[offset+8:]) == flags {
+			return offset
+		}
+	}
+	t.Fatalf("no page with flags %#x", flags)
+	return 0
+}
+
+func Test_ReadSnapshot_Corrupt(t *testing.T) {
+	testCases := []struct {
+		name    string
+		corrupt func(t *testing.T, data []byte, pageSize int) []byte
+	}{
+		{
+			name: "case 0: branch page pointing to itself",
+			corrupt: func(t *testing.T, data []byte, pageSize int) []byte {
+				offset := firstPage(t, data, pageSize, boltBranchPage)
+				copy(data[offset+boltPageHeader+8:], data[offset:offset+8])
+				return data
+			},
+		},
+		{
+			name: "case 1: overflow beyond the end of the file",
+			corrupt: func(t *testing.T, data []byte, pageSize int) []byte {
+				// the first child of the key bucket root, the first
+				// leaf page in the file may be free
+				branch := firstPage(t, data, pageSize, boltBranchPage)
+				offset := int(binary.LittleEndian.Uint64(data[branch+boltPageHeader+8:])) * pageSize
+				binary.LittleEndian.PutUint32(data[offset+12:], 0xffffffff)
+				return data
+			},
+		},
+		{
+			name: "case 2: truncated file",
+			corrupt: func(t *testing.T, data []byte, pageSize int) []byte {
+				return data[:4*pageSize]
+			},
+		},
+		{
+			name: "case 3: no valid meta page",
+			corrupt: func(t *testing.T, data []byte, pageSize int) []byte {
+				data[boltPageHeader] ^= 0xff
+				data[pageSize+boltPageHeader] ^= 0xff
+				return data
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := corruptSnapshot(t, func(data []byte, pageSize int) []byte {
+				return tc.corrupt(t, data, pageSize)
+			})
+			defer os.RemoveAll(filepath.Dir(path))
+
+			_, _, err := ReadSnapshot(path, Matcher{})
+			if !IsInvalidSnapshot(err) {
+				t.Fatalf("expected invalid snapshot error, got %#v", err)
+			}
+		})
+	}
+}
+
+func Test_decodeKeyValue(t *testing.T) {
+	// key "k", create revision 2, mod revision 3, version 1, value "v",
+	// lease 7 and the unknown fixed64 field 8
+	message := []byte{0x0a, 0x01, 'k', 0x10, 0x02, 0x18, 0x03, 0x20, 0x01, 0x2a, 0x01, 'v', 0x30, 0x07, 0x41, 1, 2, 3, 4, 5, 6, 7, 8}
+
+	kv, err := decodeKeyValue(message)
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	expected := KeyValue{Key: "k", Value: []byte("v"), CreateRevision: 2, ModRevision: 3, Version: 1, Lease: 7}
+	if kv.Key != expected.Key || !bytes.Equal(kv.Value, expected.Value) || kv.CreateRevision != expected.CreateRevision || kv.ModRevision != expected.ModRevision || kv.Version != expected.Version || kv.Lease != expected.Lease {
+		t.Fatalf("expected %#v, got %#v", expected, kv)
+	}
+
+	// every prefix cutting a field short is invalid
+	for _, n := range []int{1, 2, 4, 10, 11, 16, 22} {
+		_, err := decodeKeyValue(message[:n])
+		if !IsInvalidSnapshot(err) {
+			t.Fatalf("expected invalid snapshot error for %d bytes, got %#v", n, err)
+		}
+	}
+
+	_, err = decodeKeyValue([]byte{0x0b})
+	if !IsInvalidSnapshot(err) {
+		t.Fatalf("expected invalid snapshot error for wire type 3, got %#v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package etcd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+// Alarm types as reported by etcdctl alarm list.
+const (
+	AlarmNoSpace = "NOSPACE"
+	AlarmCorrupt = "CORRUPT"
+)
+
+// Metadata keys describing the etcd member a snapshot was taken from.
+const (
+	MetadataEndpoint  = "etcd-endpoint"
+	MetadataMemberID  = "etcd-member-id"
+	MetadataRaftIndex = "etcd-raft-index"
+	MetadataRevision  = "etcd-revision"
+	MetadataVersion   = "etcd-version"
+)
+
+// Connection holds everything etcdctl needs to connect to a v3 cluster.
+type Connection struct {
+	CACert string
+	Cert   string
+	Key    string
+	// Endpoints are comma separated.
+	Endpoints string
+}
+
+// args returns the etcdctl connection flags for endpoints.
+func (c Connection) args(endpoints string) []string {
+	var args []string
+	if endpoints != "" {
+		args = append(args, "--endpoints", endpoints)
+	}
+	if c.CACert != "" {
+		args = append(args, "--cacert", c.CACert)
+	}
+	if c.Cert != "" {
+		args = append(args, "--cert", c.Cert)
+	}
+	if c.Key != "" {
+		args = append(args, "--key", c.Key)
+	}
+
+	return args
+}
+
+// EndpointList returns the endpoints one by one.
+func (c Connection) EndpointList() []string {
+	var endpoints []string
+	for _, e := range strings.Split(c.Endpoints, ",") {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			endpoints = append(endpoints, e)
+		}
+	}
+
+	return endpoints
+}
+
+// MemberStatus is the status of the member behind one endpoint. Err is set
+// when the endpoint could not be queried.
+type MemberStatus struct {
+	Endpoint  string
+	MemberID  uint64
+	Leader    uint64
+	RaftIndex uint64
+	RaftTerm  uint64
+	Revision  int64
+	DBSize    int64
+	Version   string
+	Alarms    []string
+	Err       error
+}
+
+// Healthy returns whether the member answered.
+func (m MemberStatus) Healthy() bool {
+	return m.Err == nil
+}
+
+// IsLeader returns whether the member is the raft leader.
+func (m MemberStatus) IsLeader() bool {
+	return m.Err == nil && m.MemberID == m.Leader
+}
+
+// Metadata describes the member for the backup manifest.
+func (m MemberStatus) Metadata() map[string]string {
+	metadata := map[string]string{
+		MetadataEndpoint: m.Endpoint,
+	}
+	if m.Healthy() {
+		metadata[MetadataMemberID] = fmt.Sprintf("%x", m.MemberID)
+		metadata[MetadataRaftIndex] = fmt.Sprintf("%d", m.RaftIndex)
+		metadata[MetadataRevision] = fmt.Sprintf("%d", m.Revision)
+		metadata[MetadataVersion] = m.Version
+	}
+
+	return metadata
+}
+
+// endpointStatus is the JSON output of etcdctl endpoint status.
+type endpointStatus struct {
+	Endpoint string `json:"Endpoint"`
+	Status   struct {
+		Header struct {
+			MemberID uint64 `json:"member_id"`
+			Revision int64  `json:"revision"`
+		} `json:"header"`
+		Version   string `json:"version"`
+		DBSize    int64  `json:"dbSize"`
+		Leader    uint64 `json:"leader"`
+		RaftIndex uint64 `json:"raftIndex"`
+		RaftTerm  uint64 `json:"raftTerm"`
+	} `json:"Status"`
+}
+
+// alarmList is the JSON output of etcdctl alarm list.
+type alarmList struct {
+	Alarms []struct {
+		MemberID uint64 `json:"memberID"`
+		Alarm    int    `json:"alarm"`
+	} `json:"alarms"`
+}
+
+// MemberStatuses queries the status of every endpoint on its own, so one
+// unreachable member does not hide the others, and adds the active alarms.
+func MemberStatuses(c Connection, logger micrologger.Logger) ([]MemberStatus, error) {
+	statuses := endpointStatuses(c, logger)
+
+	alarms, err := Alarms(c, logger)
+	if err != nil {
+		return statuses, microerror.Mask(err)
+	}
+	addAlarms(statuses, alarms)
+
+	return statuses, nil
+}
+
+// endpointStatuses returns the status of every endpoint without alarms.
+func endpointStatuses(c Connection, logger micrologger.Logger) []MemberStatus {
+	etcdctlEnvs := []string{"ETCDCTL_API=3"}
+
+	var statuses []MemberStatus
+	for _, endpoint := range c.EndpointList() {
+		m := MemberStatus{
+			Endpoint: endpoint,
+		}
+
+		args := append([]string{"endpoint", "status", "-w", "json"}, c.args(endpoint)...)
+		out, err := execCmd(etcdctlCmd, args, etcdctlEnvs, logger)
+		if err != nil {
+			m.Err = microerror.Maskf(err, "%s", strings.TrimSpace(string(out)))
+			statuses = append(statuses, m)
+			continue
+		}
+
+		var s []endpointStatus
+		err = json.Unmarshal(out, &s)
+		if err != nil || len(s) != 1 {
+			m.Err = microerror.Maskf(invalidEtcdctlOutputError, "endpoint status of %s: %s", endpoint, out)
+			statuses = append(statuses, m)
+			continue
+		}
+
+		m.MemberID = s[0].Status.Header.MemberID
+		m.Revision = s[0].Status.Header.Revision
+		m.Version = s[0].Status.Version
+		m.DBSize = s[0].Status.DBSize
+		m.Leader = s[0].Status.Leader
+		m.RaftIndex = s[0].Status.RaftIndex
+		m.RaftTerm = s[0].Status.RaftTerm
+		statuses = append(statuses, m)
+	}
+
+	return statuses
+}
+
+// addAlarms sets the alarms of every healthy member.
+func addAlarms(statuses []MemberStatus, alarms map[uint64][]string) {
+	for i := range statuses {
+		if statuses[i].Healthy() {
+			statuses[i].Alarms = alarms[statuses[i].MemberID]
+		}
+	}
+}
+
+// Alarms returns the active alarms per member ID.
+func Alarms(c Connection, logger micrologger.Logger) (map[uint64][]string, error) {
+	etcdctlEnvs := []string{"ETCDCTL_API=3"}
+
+	args := append([]string{"alarm", "list", "-w", "json"}, c.args(c.Endpoints)...)
+	out, err := execCmd(etcdctlCmd, args, etcdctlEnvs, logger)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var l alarmList
+	err = json.Unmarshal(out, &l)
+	if err != nil {
+		return nil, microerror.Maskf(invalidEtcdctlOutputError, "alarm list: %s", out)
+	}
+
+	alarms := map[uint64][]string{}
+	for _, a := range l.Alarms {
+		alarms[a.MemberID] = append(alarms[a.MemberID], alarmName(a.Alarm))
+	}
+
+	return alarms, nil
+}
+
+func alarmName(alarm int) string {
+	switch alarm {
+	case 1:
+		return AlarmNoSpace
+	case 2:
+		return AlarmCorrupt
+	}
+
+	return fmt.Sprintf("ALARM_%d", alarm)
+}
+
+// RankMembers orders members by preference for taking a snapshot: healthy
+// followers without alarms with the highest raft index first, so the leader
+// keeps serving, then a healthy leader without alarms, then the rest in their
+// original order.
+func RankMembers(statuses []MemberStatus) []MemberStatus {
+	rank := func(m MemberStatus) int {
+		switch {
+		case m.Healthy() && len(m.Alarms) == 0 && !m.IsLeader():
+			return 0
+		case m.Healthy() && len(m.Alarms) == 0:
+			return 1
+		}
+		return 2
+	}
+
+	ranked := make([]MemberStatus, len(statuses))
+	copy(ranked, statuses)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		ri, rj := rank(ranked[i]), rank(ranked[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ri == 0 {
+			return ranked[i].RaftIndex > ranked[j].RaftIndex
+		}
+		return false
+	})
+
+	return ranked
+}
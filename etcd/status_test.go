@@ -0,0 +1,63 @@
+package etcd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_RankMembers(t *testing.T) {
+	unreachable := errors.New("connection refused")
+
+	testCases := []struct {
+		name     string
+		statuses []MemberStatus
+		expected []string
+	}{
+		{
+			name: "case 0: followers before the leader",
+			statuses: []MemberStatus{
+				{Endpoint: "leader", MemberID: 1, Leader: 1, RaftIndex: 10},
+				{Endpoint: "follower", MemberID: 2, Leader: 1, RaftIndex: 10},
+			},
+			expected: []string{"follower", "leader"},
+		},
+		{
+			name: "case 1: followers by raft index",
+			statuses: []MemberStatus{
+				{Endpoint: "behind", MemberID: 2, Leader: 1, RaftIndex: 8},
+				{Endpoint: "leader", MemberID: 1, Leader: 1, RaftIndex: 10},
+				{Endpoint: "ahead", MemberID: 3, Leader: 1, RaftIndex: 10},
+			},
+			expected: []string{"ahead", "behind", "leader"},
+		},
+		{
+			name: "case 2: members with alarms and unhealthy members last",
+			statuses: []MemberStatus{
+				{Endpoint: "down", Err: unreachable},
+				{Endpoint: "alarm", MemberID: 2, Leader: 1, RaftIndex: 12, Alarms: []string{AlarmNoSpace}},
+				{Endpoint: "leader", MemberID: 1, Leader: 1, RaftIndex: 10},
+			},
+			expected: []string{"leader", "down", "alarm"},
+		},
+		{
+			name:     "case 3: no members",
+			statuses: nil,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ranked := RankMembers(tc.statuses)
+
+			var endpoints []string
+			for _, m := range ranked {
+				endpoints = append(endpoints, m.Endpoint)
+			}
+			if !reflect.DeepEqual(endpoints, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, endpoints)
+			}
+		})
+	}
+}
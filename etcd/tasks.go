@@ -6,6 +6,10 @@ import (
 	"time"
 )
 
+// FullBackup creates and uploads the backup b. It stops at the first stage
+// that fails and returns the error of that stage, see FailedStage. Upload
+// errors outside of the pipeline, e.g. opening the created backup, are read
+// errors. Metrics are only returned for successful backups.
 func FullBackup(b BackupInterface) (error, *metrics.BackupMetrics) {
 	var err error
 
@@ -15,27 +19,51 @@ func FullBackup(b BackupInterface) (error, *metrics.BackupMetrics) {
 
 	err = b.Create()
 	if err != nil {
-		return microerror.Maskf(err, "Etcd %s creation failed: %s", version, err), nil
+		return microerror.Maskf(stageError(StageCreate, err), "etcd %s", version), nil
 	}
 
 	creationTime := time.Since(start).Milliseconds()
 
-	start = time.Now()
-
-	err = b.Encrypt()
+	// compression, encryption and upload run as one pipeline, their times
+	// are the busy times of the stages
+	// the destination success policy decides whether the upload failed
+	result, stats, err := b.Upload()
 	if err != nil {
-		microerror.Maskf(err, "Etcd %s encryption failed: %s", version, err)
+		return microerror.Maskf(stageError(StageRead, err), "etcd %s", version), nil
 	}
 
-	encryptionTime := time.Since(start).Milliseconds()
-	start = time.Now()
-
-	size, err := b.Upload()
-	if err != nil {
-		microerror.Maskf(err, "Etcd %s upload failed: %s", version, err)
+	var encryptionTime, uploadTime int64
+	var stages []metrics.StageMetrics
+	for _, s := range stats {
+		switch s.Name {
+		case StageEncrypt:
+			encryptionTime = s.Busy.Milliseconds()
+		case StageUpload:
+			uploadTime = s.Busy.Milliseconds()
+		}
+		stages = append(stages, metrics.StageMetrics{
+			Name:            s.Name,
+			Bytes:           s.Bytes,
+			BusyMeasurement: s.Busy.Milliseconds(),
+		})
 	}
 
-	uploadTime := time.Since(start).Milliseconds()
+	backupMetrics := metrics.NewSuccessfulBackupMetrics(result.Size, creationTime, encryptionTime, uploadTime)
+	backupMetrics.Stages = stages
+	for _, d := range result.Destinations {
+		backupMetrics.Destinations = append(backupMetrics.Destinations, metrics.DestinationMetrics{
+			Name:                  d.Name,
+			Successful:            d.Err == nil,
+			UploadTimeMeasurement: d.Duration.Milliseconds(),
+		})
+	}
+	for _, r := range result.Replication {
+		backupMetrics.Replication = append(backupMetrics.Replication, metrics.ReplicationMetrics{
+			Target:         r.Target,
+			Status:         r.Status,
+			LagMeasurement: r.Lag.Milliseconds(),
+		})
+	}
 
-	return nil, metrics.NewSuccessfulBackupMetrics(size, creationTime, encryptionTime, uploadTime)
+	return nil, backupMetrics
 }
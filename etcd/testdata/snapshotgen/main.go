@@ -0,0 +1,145 @@
+// snapshotgen writes testdata/snapshot.db, a small v3 snapshot in the layout
+// etcd uses for its backend: every revision of a key is stored in the key
+// bucket under its revision, tombstones of deleted keys carry a 't' suffix,
+// and the file ends with the sha256 etcdctl appends to saved snapshots.
+//
+// It needs go.etcd.io/bbolt and go.etcd.io/etcd/api/v3 and is not part of
+// the build:
+//
+//	go run ./testdata/snapshotgen testdata/snapshot.db
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+func revisionKey(main, sub int64, tombstone bool) []byte {
+	b := make([]byte, 17, 18)
+	binary.BigEndian.PutUint64(b, uint64(main))
+	b[8] = '_'
+	binary.BigEndian.PutUint64(b[9:], uint64(sub))
+	if tombstone {
+		b = append(b, 't')
+	}
+	return b
+}
+
+type store struct {
+	b        *bolt.Bucket
+	revision int64
+	keys     map[string]mvccpb.KeyValue
+}
+
+// txn writes ops as one revision, like an etcd transaction. An op without
+// value deletes its key.
+func (s *store) txn(ops ...mvccpb.KeyValue) {
+	s.revision++
+	for sub, op := range ops {
+		key := string(op.Key)
+		if op.Value == nil {
+			// etcd stores only the key in a tombstone
+			s.put(revisionKey(s.revision, int64(sub), true), mvccpb.KeyValue{Key: op.Key})
+			delete(s.keys, key)
+			continue
+		}
+
+		kv := s.keys[key]
+		if kv.CreateRevision == 0 {
+			kv.CreateRevision = s.revision
+		}
+		kv.Key = op.Key
+		kv.Value = op.Value
+		kv.Lease = op.Lease
+		kv.ModRevision = s.revision
+		kv.Version++
+		s.keys[key] = kv
+		s.put(revisionKey(s.revision, int64(sub), false), kv)
+	}
+}
+
+func (s *store) put(k []byte, kv mvccpb.KeyValue) {
+	data, err := kv.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	err = s.b.Put(k, data)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func put(key, value string) mvccpb.KeyValue {
+	return mvccpb.KeyValue{Key: []byte(key), Value: []byte(value)}
+}
+
+func main() {
+	path := os.Args[1]
+	os.Remove(path)
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		panic(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{"alarm", "auth", "authRoles", "authUsers", "cluster", "lease", "members", "members_removed", "meta"} {
+			_, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+		}
+		b, err := tx.CreateBucket([]byte("key"))
+		if err != nil {
+			return err
+		}
+		s := &store{b: b, revision: 1, keys: map[string]mvccpb.KeyValue{}}
+
+		s.txn(put("/registry/namespaces/default", "default"))
+		s.txn(put("/registry/namespaces/gone", "gone"))
+		s.txn(put("/registry/namespaces/default", "default-v2"))
+		s.txn(mvccpb.KeyValue{Key: []byte("/registry/namespaces/gone")})
+		s.txn(mvccpb.KeyValue{Key: []byte("/registry/leases/kube-node-lease/node1"), Value: []byte("lease"), Lease: 0x694d7a5c2e1f0a03})
+		s.txn(put("/registry/configmaps/default/a", "a"), put("/registry/configmaps/default/b", "b"))
+		s.txn(put("/registry/configmaps/default/big", strings.Repeat("0123456789", 2000)))
+		for i := 0; i < 200; i++ {
+			s.txn(put(fmt.Sprintf("/registry/events/default/e%03d", i), strings.Repeat("event", 20)))
+		}
+		s.txn(put("/registry/secrets/default/recreated", "v1"))
+		s.txn(mvccpb.KeyValue{Key: []byte("/registry/secrets/default/recreated")})
+		s.txn(put("/registry/secrets/default/recreated", "v2"))
+
+		lease := make([]byte, 8)
+		binary.BigEndian.PutUint64(lease, 0x694d7a5c2e1f0a03)
+		err = tx.Bucket([]byte("lease")).Put(lease, []byte{0x08, 0x03})
+		if err != nil {
+			return err
+		}
+		index := make([]byte, 8)
+		binary.BigEndian.PutUint64(index, uint64(s.revision+10))
+		return tx.Bucket([]byte("meta")).Put([]byte("consistent_index"), index)
+	})
+	if err != nil {
+		panic(err)
+	}
+	err = db.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	err = ioutil.WriteFile(path, append(data, sum[:]...), 0600)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,54 @@
+package etcd
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// maxThrottleBurst caps the amount read at once from a throttled reader.
+const maxThrottleBurst = 1 << 20
+
+// throttledReader limits reads to a number of bytes per second with a token
+// bucket.
+type throttledReader struct {
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+// throttle returns r limited to bytesPerSecond. Without limit r is returned
+// as is.
+func throttle(r io.Reader, bytesPerSecond int64) io.Reader {
+	if bytesPerSecond <= 0 {
+		return r
+	}
+
+	burst := maxThrottleBurst
+	if bytesPerSecond < int64(burst) {
+		burst = int(bytesPerSecond)
+	}
+
+	t := &throttledReader{
+		r:       r,
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), burst),
+	}
+
+	return t
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > t.limiter.Burst() {
+		p = p[:t.limiter.Burst()]
+	}
+
+	n, err := t.r.Read(p)
+	if n > 0 {
+		werr := t.limiter.WaitN(context.Background(), n)
+		if werr != nil && err == nil {
+			err = werr
+		}
+	}
+
+	return n, err
+}
@@ -1,8 +1,11 @@
 package etcd
 
+import "github.com/giantswarm/etcd-backup/storage"
+
 type BackupInterface interface {
+	// Create writes the backup to the temporary directory.
 	Create() error
-	Encrypt() error
-	Upload() (int64, error)
+	// Upload compresses, encrypts and uploads the backup.
+	Upload() (storage.Result, []StageStats, error)
 	Version() string
 }
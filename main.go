@@ -1,20 +1,39 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/giantswarm/micrologger"
 
 	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/report"
 	"github.com/giantswarm/etcd-backup/service"
 )
 
 // TODO:
 // - check etcdctl exists and right version
-const backupFailedCode = 1
+
+// Exit codes by failure reason, so that e.g. an unreachable S3 bucket can be
+// told from an unreachable etcd. Invalid flags exit with 2 as well.
+const (
+	backupFailedCode        = 1
+	configFailedCode        = 2
+	discoveryFailedCode     = 3
+	certificateFailedCode   = 4
+	snapshotFailedCode      = 5
+	encryptionFailedCode    = 6
+	uploadFailedCode        = 7
+	partialGuestFailureCode = 8
+	preflightFailedCode     = 9
+)
 
 // Common variables.
 var (
@@ -24,8 +43,41 @@ var (
 	source      string = "https://github.com/giantswarm/etcd-backup"
 )
 
+const (
+	commandDiff           = "diff"
+	commandExtract        = "extract"
+	commandList           = "list"
+	commandReplay         = "replay"
+	commandRestore        = "restore"
+	commandRestoreCluster = "restore-cluster"
+)
+
 var (
 	f config.Flags
+
+	// flags of the subcommands
+	apply        bool
+	at           string
+	backupName   string
+	format       string
+	fromRef      string
+	guestCluster string
+	keyPrefixes  string
+	keys         string
+	members      string
+	noVerify     bool
+	outputDir    string
+	revision     int64
+	tarball      bool
+	toRef        string
+	values       bool
+	verify       bool
+)
+
+// Output formats of diff.
+const (
+	diffFormatJSON = "json"
+	diffFormatText = "text"
 )
 
 func main() {
@@ -44,34 +96,92 @@ func main() {
 	// Print flags related messages to stdout instead of stderr.
 	flag.CommandLine.SetOutput(os.Stdout)
 
-	flag.StringVar(&f.AwsS3Bucket, "aws-s3-bucket", "etcdbackups", "AWS S3 bucket for backups")
-	flag.StringVar(&f.AwsS3Region, "aws-s3-region", "us-east-1", "AWS S3 region for backups")
-	flag.BoolVar(&f.GuestBackup, "guest-backup", false, "Enable guest clusters etcd backup.")
-	flag.StringVar(&f.EtcdV2DataDir, "etcd-v2-datadir", "", "Etcd datadir. If not set V2 etcd will be skipped")
-	flag.StringVar(&f.EtcdV3Cert, "etcd-v3-cert", "", "Client certificate for etcd connection")
-	flag.StringVar(&f.EtcdV3CACert, "etcd-v3-cacert", "", "Client CA certificate for etcd connection")
-	flag.StringVar(&f.EtcdV3Key, "etcd-v3-key", "", "Client private key for etcd connection")
-	flag.StringVar(&f.EtcdV3Endpoints, "etcd-v3-endpoints", "http://127.0.0.1:2379", "Endpoints for etcd connection")
-	flag.StringVar(&f.Prefix, "prefix", "", "[mandatory] Prefix to use in etcd filenames")
-	flag.StringVar(&f.Provider, "provider", "", "[mandatory] provider (aws, azure or kvm)")
-	flag.BoolVar(&f.SkipV2, "skip-v2", false, "flag for skipping etcd v2 backup")
-	flag.StringVar(&f.PushGatewayURL, "prometheus-url", "", "URL of the Prometheus push gateway (i.e. http://pushgw.example.com:9001)")
-	flag.StringVar(&f.PushGatewayJob, "prometheus-job", "", "Job name for the Prometheus push gateway (i.e. etcd_backup)")
-
-	flag.BoolVar(&f.Help, "help", false, "Print usage and exit")
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == commandList || args[0] == commandRestore || args[0] == commandExtract || args[0] == commandDiff || args[0] == commandReplay || args[0] == commandRestoreCluster) {
+		f.Command = args[0]
+		args = args[1:]
+	}
+
+	config.RegisterFlags(flag.CommandLine, &f)
+	switch f.Command {
+	case commandList:
+		flag.BoolVar(&verify, "verify", false, "Download every backup and check it against its manifest checksum")
+	case commandRestore:
+		flag.StringVar(&backupName, "backup", "", "Name of the backup to restore, as shown by list")
+		flag.StringVar(&at, "at", "", "Restore the newest v3 backup of -prefix at or before this time (RFC3339) instead of -backup")
+		flag.Int64Var(&revision, "revision", 0, "Restore the newest v3 backup of -prefix at or before this revision instead of -backup")
+		flag.StringVar(&outputDir, "output", ".", "Directory the decrypted backup is written to")
+		flag.BoolVar(&noVerify, "no-verify", false, "Restore without checking the manifest checksum, i.e. for backups made before checksums were recorded")
+		flag.StringVar(&guestCluster, "guest-cluster", "", "Plan the restore of this guest cluster from its latest backup, or the one selected with -backup, -at or -revision")
+		flag.StringVar(&members, "members", "", "Comma separated etcd members of the guest cluster as name=peer-url (i.e. etcd0=https://etcd.abc12.example.com:2380)")
+		flag.BoolVar(&tarball, "tarball", false, "Write the data directory of every guest cluster member as <name>.etcd.tar.gz")
+		flag.BoolVar(&apply, "apply", false, "Write the data directories and certificates of the guest cluster to -output instead of only printing the plan")
+	case commandRestoreCluster:
+		flag.StringVar(&backupName, "backup", "", "Name of the v3 snapshot to restore the cluster from, as shown by list")
+		flag.StringVar(&at, "at", "", "Restore the newest v3 backup of -prefix at or before this time (RFC3339) instead of -backup")
+		flag.Int64Var(&revision, "revision", 0, "Restore the newest v3 backup of -prefix at or before this revision instead of -backup")
+		flag.StringVar(&members, "members", "", "Comma separated members of the restored cluster as name=peer-url (i.e. etcd1=https://10.0.0.1:2380,etcd2=https://10.0.0.2:2380)")
+		flag.StringVar(&outputDir, "output", ".", "Directory the data directory of every member is written to, as <name>.etcd")
+		flag.BoolVar(&tarball, "tarball", false, "Write the data directory of every member as <name>.etcd.tar.gz")
+		flag.BoolVar(&noVerify, "no-verify", false, "Restore without checking the manifest checksum, i.e. for backups made before checksums were recorded")
+	case commandExtract:
+		flag.StringVar(&backupName, "backup", "", "Name of the v3 backup or export to extract from, as shown by list")
+		flag.StringVar(&at, "at", "", "Extract the keys of -prefix at this time (RFC3339) instead of from -backup, using the newest v3 backup before and its journal")
+		flag.Int64Var(&revision, "revision", 0, "Extract the keys of -prefix at this revision instead of from -backup, using the newest v3 backup before and its journal")
+		flag.StringVar(&keys, "key", "", "Comma separated keys to extract (i.e. /registry/configmaps/default/app)")
+		flag.StringVar(&keyPrefixes, "key-prefix", "", "Comma separated key prefixes to extract (i.e. /registry/secrets/kube-system/)")
+		flag.StringVar(&format, "format", etcd.FormatYAML, "Output format of the values: yaml, json or raw. Kubernetes objects are decoded for yaml and json")
+		flag.BoolVar(&noVerify, "no-verify", false, "Extract without checking the manifest checksum, i.e. for backups made before checksums were recorded")
+	case commandDiff:
+		flag.StringVar(&fromRef, "from", "", "Older v3 backup or export to compare, as shown by list, or a time (RFC3339) selecting the newest snapshot of -prefix taken at or before it")
+		flag.StringVar(&toRef, "to", "", "Newer v3 backup or export to compare, as shown by list, or a time (RFC3339)")
+		flag.StringVar(&keys, "key", "", "Comma separated keys to compare, all keys if neither -key nor -key-prefix is set")
+		flag.StringVar(&keyPrefixes, "key-prefix", "", "Comma separated key prefixes to compare (i.e. /registry/secrets/)")
+		flag.BoolVar(&values, "values", false, "Show a line diff of the YAML of modified Kubernetes objects")
+		flag.StringVar(&format, "format", diffFormatText, "Output format: text or json")
+		flag.BoolVar(&noVerify, "no-verify", false, "Compare without checking the manifest checksums, i.e. for backups made before checksums were recorded")
+	case commandReplay:
+		flag.StringVar(&backupName, "backup", "", "Name of the v3 snapshot the cluster at -etcd-v3-endpoints is restored from, as shown by list")
+		flag.Int64Var(&revision, "revision", 0, "Apply the journal up to this revision. If neither -revision nor -at is set the whole journal is applied")
+		flag.StringVar(&at, "at", "", "Apply the journal segments closed at or before this time (RFC3339)")
+		flag.BoolVar(&noVerify, "no-verify", false, "Apply the journal without checking the manifest checksums")
+	}
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stdout, "Usage of %s:\n", os.Args[0])
 		fmt.Fprintf(os.Stdout, "\n")
-		fmt.Fprintf(os.Stdout, "  variable %s - [mandatory] AWS access key for S3\n", config.EnvAwsAccessKey)
-		fmt.Fprintf(os.Stdout, "  variable %s - [mandatory] AWS secret access key for S3\n", config.EnvAwsSecretKey)
+		fmt.Fprintf(os.Stdout, "  %s [flags]                       backup etcd\n", name)
+		fmt.Fprintf(os.Stdout, "  %s list [-verify] [flags]        list backups of the first destination\n", name)
+		fmt.Fprintf(os.Stdout, "  %s restore -backup NAME [flags]  download, verify and decrypt a backup\n", name)
+		fmt.Fprintf(os.Stdout, "  %s restore -at TIME|-revision N [flags]\n", name)
+		fmt.Fprintf(os.Stdout, "                                           restore the newest v3 backup before a point\n")
+		fmt.Fprintf(os.Stdout, "  %s restore -guest-cluster ID -members NAME=PEER-URL,... [-apply] [flags]\n", name)
+		fmt.Fprintf(os.Stdout, "                                           plan, and with -apply prepare, the restore of a guest cluster\n")
+		fmt.Fprintf(os.Stdout, "  %s restore-cluster -backup NAME|-at TIME|-revision N -members NAME=PEER-URL,... [-tarball] [flags]\n", name)
+		fmt.Fprintf(os.Stdout, "                                           write the data directories of every member of a v3 backup\n")
+		fmt.Fprintf(os.Stdout, "  %s extract -backup NAME|-at TIME|-revision N -key KEY|-key-prefix PREFIX [flags]\n", name)
+		fmt.Fprintf(os.Stdout, "                                           print keys of a v3 backup without restoring it\n")
+		fmt.Fprintf(os.Stdout, "  %s diff -from NAME|TIME -to NAME|TIME [-values] [flags]\n", name)
+		fmt.Fprintf(os.Stdout, "                                           show keys changed between two v3 backups\n")
+		fmt.Fprintf(os.Stdout, "  %s replay -backup NAME [-revision N|-at TIME] [flags]\n", name)
+		fmt.Fprintf(os.Stdout, "                                           apply the journal after a v3 backup to the restored cluster\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "  variable %s - AWS access key for S3\n", config.EnvAwsAccessKey)
+		fmt.Fprintf(os.Stdout, "  variable %s - AWS secret access key for S3\n", config.EnvAwsSecretKey)
 		fmt.Fprintf(os.Stdout, "  variable %s - passphrase for AES encryption\n", config.EnvEncryptPassph)
 		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "  Instead of the variables above the secrets can be read from files with\n")
+		fmt.Fprintf(os.Stdout, "  -aws-access-key-file, -aws-secret-key-file and -passphrase-file, i.e. mounted\n")
+		fmt.Fprintf(os.Stdout, "  Kubernetes secrets. The files are re-read on every backup.\n")
+		fmt.Fprintf(os.Stdout, "  Without static keys the AWS credential chain is used: web identity token\n")
+		fmt.Fprintf(os.Stdout, "  (IRSA), environment, shared config and ECS/EC2 instance metadata.\n")
+		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stdout, "  Every flag can also be set with the variable %s<FLAG>, i.e. %s.\n", config.EnvPrefix, config.EnvName("aws-s3-bucket"))
+		fmt.Fprintf(os.Stdout, "\n")
 		flag.PrintDefaults()
 	}
 	// parse flags
-	flag.Parse()
-	config.ParseEnvs(&f)
+	flag.CommandLine.Parse(args)
 
 	// Print usage.
 	if f.Help {
@@ -79,28 +189,504 @@ func main() {
 		return
 	}
 
-	// check flags
-	config.CheckConfig(f)
 	// create micrologger
 	loggerConfig := micrologger.Config{}
+	if f.Command != "" {
+		// keep stdout for the command output, i.e. extracted keys
+		loggerConfig.IOWriter = os.Stderr
+	}
 	logger, err := micrologger.New(loggerConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create logger: %s\n", err)
+		os.Exit(backupFailedCode)
+	}
+
+	// apply config file and environment
+	err = config.Load(flag.CommandLine, &f)
+	if err != nil {
+		logger.Log("level", "error", "msg", "failed to load config", "reason", err)
+		os.Exit(configFailedCode)
+	}
+
+	// check flags
+	err = config.CheckConfig(&f)
+	if err != nil {
+		logger.Log("level", "error", "msg", "invalid config", "reason", err)
+		os.Exit(configFailedCode)
+	}
+	if f.PushGatewayURL == "" && f.Command == "" {
+		logger.Log("level", "info", "msg", "Skipping prometheus metrics push as --prometheus-url is not set")
+	}
+	if f.EtcdV2DataDir == "" && f.Command == "" {
+		logger.Log("level", "info", "msg", "Skipping etcd V2 etcd as -etcd-v2-datadir is not set")
+	}
 
 	// create backup service
 	backupService := service.CreateService(f, logger)
 
+	switch f.Command {
+	case commandList:
+		err = list(backupService)
+	case commandRestore:
+		err = restore(backupService)
+	case commandRestoreCluster:
+		err = restoreCluster(backupService)
+	case commandExtract:
+		err = extract(backupService)
+	case commandDiff:
+		err = diff(backupService)
+	case commandReplay:
+		err = replay(backupService)
+	}
+	if f.Command != "" {
+		if err != nil {
+			logger.Log("level", "error", "msg", fmt.Sprintf("%s failed", f.Command), "reason", err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	// run once, or forever when a schedule is set
+	for {
+		err = run(backupService, logger)
+
+		if f.ReportFile != "" {
+			reportErr := backupService.Report.Write(f.ReportFile)
+			if reportErr != nil {
+				logger.Log("level", "error", "msg", "failed to write report", "reason", reportErr)
+			}
+		}
+
+		if f.ScheduleInterval == 0 {
+			break
+		}
+
+		if err == nil {
+			logger.Log("level", "info", "msg", "Success")
+		}
+		logger.Log("level", "info", "msg", fmt.Sprintf("Next backup in %s", f.ScheduleInterval))
+		if f.Journal {
+			// record the changes until the next backup
+			journalErr := backupService.RunJournals(f.ScheduleInterval)
+			if journalErr != nil {
+				logger.Log("level", "error", "msg", "failed to record journals", "reason", journalErr)
+			}
+		} else {
+			time.Sleep(f.ScheduleInterval)
+		}
+		backupService.Report = report.New()
+	}
+
+	if err != nil {
+		os.Exit(exitCode(err))
+	}
+	logger.Log("level", "info", "msg", "Success")
+}
+
+// exitCode returns the exit code for the reason of err.
+func exitCode(err error) int {
+	switch service.FailureReason(err) {
+	case service.ReasonConfig:
+		return configFailedCode
+	case service.ReasonDiscovery:
+		return discoveryFailedCode
+	case service.ReasonCertificate:
+		return certificateFailedCode
+	case service.ReasonSnapshot:
+		return snapshotFailedCode
+	case service.ReasonEncryption:
+		return encryptionFailedCode
+	case service.ReasonUpload:
+		return uploadFailedCode
+	case service.ReasonPartialGuestFailure:
+		return partialGuestFailureCode
+	case service.ReasonPreflight:
+		return preflightFailedCode
+	}
+
+	return backupFailedCode
+}
+
+func run(backupService *service.Service, logger micrologger.Logger) error {
+	// backup inventory targets instead of host and guest clusters
+	if f.Inventory != "" {
+		err := backupService.BackupInventory()
+		if err != nil {
+			logger.Log("level", "error", "msg", "failed to backup inventory etcd", "reason", err)
+			return err
+		}
+		return nil
+	}
+
 	// backup host cluster
-	err = backupService.BackupHostCluster()
+	err := backupService.BackupHostCluster()
 	if err != nil {
 		logger.Log("level", "error", "msg", "failed to backup host cluster etcd", "reason", err)
-		os.Exit(backupFailedCode)
+		return err
 	}
 	// backup guest cluster
 	if f.GuestBackup {
 		err = backupService.BackupGuestClusters()
 		if err != nil {
 			logger.Log("level", "error", "msg", "failed to backup guest cluster etcd", "reason", err)
-			os.Exit(backupFailedCode)
+			return err
 		}
 	}
-	logger.Log("level", "info", "msg", "Success")
+
+	return nil
+}
+
+// list prints the stored backups. It fails when a verified backup does not
+// match its manifest.
+func list(backupService *service.Service) error {
+	backups, err := backupService.ListBackups(verify)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if verify {
+		fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED\tSHA256\tVERIFIED")
+	} else {
+		fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED")
+	}
+
+	var failed int
+	for _, b := range backups {
+		modified := b.Modified.UTC().Format(time.RFC3339)
+		if !verify {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", b.Name, b.Size, modified)
+			continue
+		}
+
+		status := "ok"
+		if b.Err != nil {
+			failed++
+			status = b.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", b.Name, b.Size, modified, b.SHA256, status)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d backups failed verification", failed, len(backups))
+	}
+
+	return nil
+}
+
+func restore(backupService *service.Service) error {
+	if guestCluster != "" {
+		return restoreGuest(backupService)
+	}
+
+	p, ok, err := point()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		restored, err := backupService.RestoreBackup(backupName, outputDir, !noVerify)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, restored)
+		return nil
+	}
+
+	restored, b, err := backupService.RestorePoint(p, outputDir, !noVerify)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, restored)
+	replayHint(b)
+
+	return nil
+}
+
+// replayHint tells how to reach the point of b after restoring its snapshot.
+func replayHint(b service.PointBackup) {
+	if len(b.Segments) > 0 {
+		fmt.Fprintf(os.Stderr, "%s is at revision %d, after restoring it apply the journal with:\n", b.Snapshot, b.SnapshotRevision)
+		fmt.Fprintf(os.Stderr, "  %s replay -backup %s -revision %d\n", name, b.Snapshot, b.Revision)
+	}
+}
+
+// restoreGuest prints the plan to restore a guest cluster and with -apply
+// writes the data directories of its members.
+func restoreGuest(backupService *service.Service) error {
+	var p service.Point
+	if backupName != "" || at != "" || revision != 0 {
+		var err error
+		p, _, err = point()
+		if err != nil {
+			return err
+		}
+	}
+	ms, err := etcd.ParseMembers(members)
+	if err != nil {
+		return fmt.Errorf("-members: %s", err)
+	}
+
+	r, err := backupService.RestoreGuestCluster(guestCluster, backupName, p, ms, outputDir, tarball, apply, !noVerify)
+	if err != nil {
+		return err
+	}
+	b := r.Backup
+
+	fmt.Fprintf(os.Stdout, "guest cluster %s at %s, certificates in secret %s/%s\n", r.Cluster.ID, r.Cluster.Endpoint, r.Cluster.CertSecretRef.Namespace, r.Cluster.CertSecretRef.Name)
+	fmt.Fprintf(os.Stdout, "backup %s at revision %d", b.Snapshot, b.SnapshotRevision)
+	if len(b.Segments) > 0 {
+		fmt.Fprintf(os.Stdout, " with %d journal segments up to revision %d", len(b.Segments), b.Revision)
+	}
+	fmt.Fprintf(os.Stdout, "\n\n")
+
+	token := "<generated with -apply>"
+	certs := []string{"<ca file>", "<cert file>", "<key file>"}
+	if r.Applied {
+		token = r.Restore.Token
+		certs = []string{r.CACert, r.Cert, r.Key}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPEER URL\tDATA")
+	for _, m := range r.Members {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Name, m.PeerURL, m.Path)
+	}
+	w.Flush()
+
+	fmt.Fprintf(os.Stdout, "\nsteps:\n")
+	fmt.Fprintf(os.Stdout, "  1. stop etcd on every member of %s\n", r.Cluster.ID)
+	fmt.Fprintf(os.Stdout, "  2. on every member move the etcd data directory away and put the restored one in its place\n")
+	fmt.Fprintf(os.Stdout, "  3. start every member with:\n")
+	for _, m := range r.Members {
+		fmt.Fprintf(os.Stdout, "       %s: --name %s --initial-advertise-peer-urls %s\n", m.Name, m.Name, m.PeerURL)
+	}
+	fmt.Fprintf(os.Stdout, "     and on all of them:\n")
+	fmt.Fprintf(os.Stdout, "       --initial-cluster %s\n", etcd.InitialCluster(ms))
+	fmt.Fprintf(os.Stdout, "       --initial-cluster-token %s\n", token)
+	step := 4
+	if len(b.Segments) > 0 {
+		fmt.Fprintf(os.Stdout, "  %d. apply the journal recorded after the backup, with the storage flags of this run:\n", step)
+		fmt.Fprintf(os.Stdout, "       %s replay -backup %s -revision %d -etcd-v3-endpoints %s -etcd-v3-cacert %s -etcd-v3-cert %s -etcd-v3-key %s\n", name, b.Snapshot, b.Revision, r.Cluster.Endpoint, certs[0], certs[1], certs[2])
+		step++
+	}
+	fmt.Fprintf(os.Stdout, "  %d. check that every member is healthy and at revision %d or later:\n", step, b.Revision)
+	fmt.Fprintf(os.Stdout, "       ETCDCTL_API=3 etcdctl endpoint status -w table --endpoints %s --cacert %s --cert %s --key %s\n", r.Cluster.Endpoint, certs[0], certs[1], certs[2])
+
+	if !r.Applied {
+		fmt.Fprintf(os.Stdout, "\ndry run, nothing was written. Run again with -apply to write the data directories and certificates to %s\n", r.OutputDir)
+	}
+
+	return nil
+}
+
+// restoreCluster writes the data directories of every member of a cluster
+// restored from one v3 snapshot and prints the flags to start them with.
+func restoreCluster(backupService *service.Service) error {
+	p, byPoint, err := point()
+	if err != nil {
+		return err
+	}
+	ms, err := etcd.ParseMembers(members)
+	if err != nil {
+		return fmt.Errorf("-members: %s", err)
+	}
+
+	snapshot := backupName
+	var b service.PointBackup
+	if byPoint {
+		b, err = backupService.ResolvePoint(p)
+		if err != nil {
+			return err
+		}
+		snapshot = b.Snapshot
+	}
+
+	r, err := backupService.RestoreCluster(snapshot, ms, outputDir, tarball, !noVerify)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPEER URL\tDATA")
+	for _, m := range r.Members {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Name, m.PeerURL, m.Path)
+	}
+	w.Flush()
+	fmt.Fprintf(os.Stdout, "\nrestored from %s, start every member with its data directory and:\n", r.Snapshot)
+	fmt.Fprintf(os.Stdout, "  --name <name> --initial-advertise-peer-urls <peer url>\n")
+	fmt.Fprintf(os.Stdout, "  --initial-cluster %s\n", r.InitialCluster)
+	fmt.Fprintf(os.Stdout, "  --initial-cluster-token %s\n", r.Token)
+	if byPoint {
+		replayHint(b)
+	}
+
+	return nil
+}
+
+// point returns the point in time or revision selected with -at and
+// -revision. It returns false when -backup is set instead.
+func point() (service.Point, bool, error) {
+	switch {
+	case backupName != "" && (at != "" || revision != 0):
+		return service.Point{}, false, fmt.Errorf("-backup must not be set with -at or -revision")
+	case backupName != "":
+		return service.Point{}, false, nil
+	case at == "" && revision == 0:
+		return service.Point{}, false, fmt.Errorf("-backup, -at or -revision must be set")
+	case revision < 0:
+		return service.Point{}, false, fmt.Errorf("-revision must be positive")
+	}
+
+	p := service.Point{
+		Revision: revision,
+	}
+	if at != "" {
+		var err error
+		p.At, err = time.Parse(time.RFC3339, at)
+		if err != nil {
+			return service.Point{}, false, fmt.Errorf("-at must be RFC3339, i.e. 2019-01-01T00:00:00Z: %s", err)
+		}
+	}
+
+	return p, true, nil
+}
+
+// extract prints the selected keys of a backup, decoded as Kubernetes
+// objects unless the format is raw.
+func extract(backupService *service.Service) error {
+	p, byPoint, err := point()
+	if err != nil {
+		return err
+	}
+	if keys == "" && keyPrefixes == "" {
+		return fmt.Errorf("-key or -key-prefix must be set")
+	}
+	switch format {
+	case etcd.FormatJSON, etcd.FormatRaw, etcd.FormatYAML:
+	default:
+		return fmt.Errorf("-format must be yaml, json or raw, got %q", format)
+	}
+
+	m := etcd.Matcher{
+		Keys:     config.SplitList(keys),
+		Prefixes: config.SplitList(keyPrefixes),
+	}
+	var kvs []etcd.KeyValue
+	source := backupName
+	if byPoint {
+		var b service.PointBackup
+		kvs, b, err = backupService.ExtractPoint(p, m, !noVerify)
+		if err != nil {
+			return err
+		}
+		source = fmt.Sprintf("%s with %d journal segments up to revision %d", b.Snapshot, len(b.Segments), b.Revision)
+		fmt.Fprintf(os.Stderr, "extracting from %s\n", source)
+	} else {
+		kvs, err = backupService.ExtractKeys(backupName, m, !noVerify)
+		if err != nil {
+			return err
+		}
+	}
+	if len(kvs) == 0 {
+		return fmt.Errorf("no matching keys in %s", source)
+	}
+
+	for _, kv := range kvs {
+		value, decoded, err := etcd.Decode(kv.Value, format)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s: %s", kv.Key, err)
+		}
+
+		switch {
+		case format == etcd.FormatRaw:
+			os.Stdout.Write(value)
+		case !decoded:
+			fmt.Fprintf(os.Stderr, "%s: value is no Kubernetes object, use -format raw\n", kv.Key)
+		case format == etcd.FormatYAML:
+			fmt.Fprintf(os.Stdout, "---\n# %s (mod revision %d)\n", kv.Key, kv.ModRevision)
+			os.Stdout.Write(value)
+		default:
+			os.Stdout.Write(value)
+		}
+	}
+
+	return nil
+}
+
+// diff prints the keys changed between two backups grouped by resource type.
+func diff(backupService *service.Service) error {
+	if fromRef == "" || toRef == "" {
+		return fmt.Errorf("-from and -to must not be empty")
+	}
+	if format != diffFormatText && format != diffFormatJSON {
+		return fmt.Errorf("-format must be text or json, got %q", format)
+	}
+
+	m := etcd.Matcher{
+		Keys:     config.SplitList(keys),
+		Prefixes: config.SplitList(keyPrefixes),
+	}
+	d, err := backupService.DiffBackups(fromRef, toRef, m, values, !noVerify)
+	if err != nil {
+		return err
+	}
+
+	if format == diffFormatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+
+	fmt.Fprintf(os.Stdout, "--- %s\n+++ %s\n", d.From, d.To)
+	if len(d.Resources) == 0 {
+		fmt.Fprintln(os.Stdout, "no changes")
+	}
+	marks := map[string]string{
+		etcd.ChangeAdded:    "+",
+		etcd.ChangeModified: "~",
+		etcd.ChangeRemoved:  "-",
+	}
+	for _, r := range d.Resources {
+		fmt.Fprintf(os.Stdout, "\n%s: %d added, %d removed, %d modified\n", r.Resource, r.Added, r.Removed, r.Modified)
+		for _, c := range r.Changes {
+			fmt.Fprintf(os.Stdout, "%s %s\n", marks[c.Change], c.Key)
+			for _, line := range strings.Split(strings.TrimSuffix(c.Diff, "\n"), "\n") {
+				if line != "" {
+					fmt.Fprintf(os.Stdout, "    %s\n", line)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// replay applies the journal recorded after a v3 snapshot to the cluster
+// restored from it.
+func replay(backupService *service.Service) error {
+	if backupName == "" {
+		return fmt.Errorf("-backup must not be empty")
+	}
+	if revision != 0 && at != "" {
+		return fmt.Errorf("-revision and -at must not be set both")
+	}
+	var atTime time.Time
+	if at != "" {
+		var err error
+		atTime, err = time.Parse(time.RFC3339, at)
+		if err != nil {
+			return fmt.Errorf("-at must be RFC3339, i.e. 2019-01-01T00:00:00Z: %s", err)
+		}
+	}
+
+	r, err := backupService.ReplayJournal(backupName, revision, atTime, !noVerify)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "applied %d keys from %d journal segments after %s up to revision %d\n", r.Keys, r.Segments, r.Snapshot, r.Revision)
+
+	return nil
 }
@@ -2,16 +2,44 @@ package metrics
 
 import (
 	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/storage"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/push"
 )
 
-const labelTenantClusterId = "tenant_cluster_id"
+const (
+	labelCheck             = "check"
+	labelDestination       = "destination"
+	labelReason            = "reason"
+	labelReplicationTarget = "replication_target"
+	labelStage             = "stage"
+	labelTenantClusterId   = "tenant_cluster_id"
+)
 
 var (
 	labels = []string{
 		labelTenantClusterId,
 	}
+	checkLabels = []string{
+		labelTenantClusterId,
+		labelCheck,
+	}
+	failureLabels = []string{
+		labelTenantClusterId,
+		labelReason,
+	}
+	destinationLabels = []string{
+		labelTenantClusterId,
+		labelDestination,
+	}
+	replicationLabels = []string{
+		labelTenantClusterId,
+		labelReplicationTarget,
+	}
+	stageLabels = []string{
+		labelTenantClusterId,
+		labelStage,
+	}
 	namespace    = "etcd_backup"
 	creationTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: prometheus.BuildFQName(namespace, "", "creation_time_ms"),
@@ -35,7 +63,63 @@ var (
 	}, labels)
 	failureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: prometheus.BuildFQName(namespace, "", "failure_count"),
-		Help: "Count of failed backups",
+		Help: "Count of failed backups by reason, e.g. snapshot when etcd could not be backed up or upload when no destination was reachable",
+	}, failureLabels)
+	stageFailureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "stage_failure_count"),
+		Help: "Count of failed backups per stage the backup stopped at, e.g. create or upload",
+	}, stageLabels)
+	destinationUploadTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: prometheus.BuildFQName(namespace, "", "destination_upload_time_ms"),
+		Help: "Gauge about the time in ms spent uploading the ETCD backup to a single destination.",
+	}, destinationLabels)
+	destinationSuccessCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "destination_success_count"),
+		Help: "Count of successful uploads per backup destination",
+	}, destinationLabels)
+	destinationFailureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "destination_failure_count"),
+		Help: "Count of failed uploads per backup destination",
+	}, destinationLabels)
+	replicationLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: prometheus.BuildFQName(namespace, "", "replication_lag_ms"),
+		Help: "Gauge about the time in ms until the ETCD backup showed up in a replication target.",
+	}, replicationLabels)
+	replicationMismatchCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "replication_mismatch_count"),
+		Help: "Count of replicated backups whose size or checksum differs from the primary",
+	}, replicationLabels)
+	replicationMissingCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "replication_missing_count"),
+		Help: "Count of backups not replicated within the replication timeout",
+	}, replicationLabels)
+	exportSuccessCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "export_success_count"),
+		Help: "Count of successful logical exports of backups",
+	}, labels)
+	exportFailureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "export_failure_count"),
+		Help: "Count of failed logical exports of backups by reason. The backup itself counts as successful",
+	}, failureLabels)
+	stageBusyTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: prometheus.BuildFQName(namespace, "", "stage_busy_time_ms"),
+		Help: "Gauge about the time in ms a backup pipeline stage was busy, without waiting for other stages.",
+	}, stageLabels)
+	stageThroughput = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: prometheus.BuildFQName(namespace, "", "stage_throughput_bytes_per_second"),
+		Help: "Gauge about the bytes per second a backup pipeline stage produced while busy. The lowest stage is the bottleneck.",
+	}, stageLabels)
+	preflightCheckPassed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: prometheus.BuildFQName(namespace, "", "preflight_check_passed"),
+		Help: "Gauge whether a pre-flight check passed (1) or failed (0) before the last ETCD backup.",
+	}, checkLabels)
+	skippedPreflightCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "skipped_preflight_count"),
+		Help: "Count of backups skipped because a pre-flight check failed",
+	}, labels)
+	skippedUnsupportedVersionCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "skipped_unsupported_version"),
+		Help: "Count of backups skipped because the cluster release version does not support etcd backups",
 	}, labels)
 )
 
@@ -48,9 +132,49 @@ func Send(prometheusConfig *config.PrometheusConfig, metrics *BackupMetrics, ten
 			labelTenantClusterId: tenantClusterName,
 		}
 
-		if metrics.Successful {
+		// pre-flight checks are run before skipped, successful and failed
+		// backups alike
+		if len(metrics.Preflight) > 0 {
+			registry.MustRegister(preflightCheckPassed)
+			for _, c := range metrics.Preflight {
+				checkLabels := prometheus.Labels{
+					labelTenantClusterId: tenantClusterName,
+					labelCheck:           c.Check,
+				}
+				passed := 0.0
+				if c.Passed {
+					passed = 1
+				}
+				preflightCheckPassed.With(checkLabels).Set(passed)
+			}
+		}
+
+		if metrics.SkippedPreflight {
+			// skipped backup
+			registry.MustRegister(skippedPreflightCounter)
+			pusher := push.New(prometheusConfig.Url, prometheusConfig.Job).Gatherer(registry)
+
+			skippedPreflightCounter.With(labels).Inc()
+
+			if err := pusher.Add(); err != nil {
+				return true, err
+			}
+		} else if metrics.SkippedUnsupportedVersion {
+			// skipped backup
+			registry.MustRegister(skippedUnsupportedVersionCounter)
+			pusher := push.New(prometheusConfig.Url, prometheusConfig.Job).Gatherer(registry)
+
+			skippedUnsupportedVersionCounter.With(labels).Inc()
+
+			if err := pusher.Add(); err != nil {
+				return true, err
+			}
+		} else if metrics.Successful {
 			// successful backup
 			registry.MustRegister(creationTime, encryptionTime, uploadTime, backupSize, successCounter)
+			registry.MustRegister(destinationUploadTime, destinationSuccessCounter, destinationFailureCounter)
+			registry.MustRegister(replicationLag, replicationMismatchCounter, replicationMissingCounter)
+			registry.MustRegister(stageBusyTime, stageThroughput)
 			pusher := push.New(prometheusConfig.Url, prometheusConfig.Job).Gatherer(registry)
 
 			creationTime.With(labels).Set(float64(metrics.CreationTimeMeasurement))
@@ -59,6 +183,59 @@ func Send(prometheusConfig *config.PrometheusConfig, metrics *BackupMetrics, ten
 			backupSize.With(labels).Set(float64(metrics.BackupSizeMeasurement))
 			successCounter.With(labels).Inc()
 
+			for _, d := range metrics.Destinations {
+				destinationLabels := prometheus.Labels{
+					labelTenantClusterId: tenantClusterName,
+					labelDestination:     d.Name,
+				}
+				if d.Successful {
+					destinationUploadTime.With(destinationLabels).Set(float64(d.UploadTimeMeasurement))
+					destinationSuccessCounter.With(destinationLabels).Inc()
+				} else {
+					destinationFailureCounter.With(destinationLabels).Inc()
+				}
+			}
+
+			for _, st := range metrics.Stages {
+				stageLabels := prometheus.Labels{
+					labelTenantClusterId: tenantClusterName,
+					labelStage:           st.Name,
+				}
+				stageBusyTime.With(stageLabels).Set(float64(st.BusyMeasurement))
+				if st.BusyMeasurement > 0 {
+					stageThroughput.With(stageLabels).Set(float64(st.Bytes) * 1000 / float64(st.BusyMeasurement))
+				}
+			}
+
+			for _, r := range metrics.Replication {
+				replicationLabels := prometheus.Labels{
+					labelTenantClusterId:   tenantClusterName,
+					labelReplicationTarget: r.Target,
+				}
+				switch r.Status {
+				case storage.ReplicationReplicated:
+					replicationLag.With(replicationLabels).Set(float64(r.LagMeasurement))
+				case storage.ReplicationMismatch:
+					replicationMismatchCounter.With(replicationLabels).Inc()
+				default:
+					replicationMissingCounter.With(replicationLabels).Inc()
+				}
+			}
+
+			if metrics.Export != nil {
+				if metrics.Export.Successful {
+					registry.MustRegister(exportSuccessCounter)
+					exportSuccessCounter.With(labels).Inc()
+				} else {
+					registry.MustRegister(exportFailureCounter)
+					exportLabels := prometheus.Labels{
+						labelTenantClusterId: tenantClusterName,
+						labelReason:          metrics.Export.FailureReason,
+					}
+					exportFailureCounter.With(exportLabels).Inc()
+				}
+			}
+
 			if err := pusher.Add(); err != nil {
 				return true, err
 			}
@@ -67,7 +244,20 @@ func Send(prometheusConfig *config.PrometheusConfig, metrics *BackupMetrics, ten
 			registry.MustRegister(failureCounter)
 			pusher := push.New(prometheusConfig.Url, prometheusConfig.Job).Gatherer(registry)
 
-			failureCounter.With(labels).Inc()
+			failureLabels := prometheus.Labels{
+				labelTenantClusterId: tenantClusterName,
+				labelReason:          metrics.FailureReason,
+			}
+			failureCounter.With(failureLabels).Inc()
+
+			if metrics.FailedStage != "" {
+				registry.MustRegister(stageFailureCounter)
+				stageLabels := prometheus.Labels{
+					labelTenantClusterId: tenantClusterName,
+					labelStage:           metrics.FailedStage,
+				}
+				stageFailureCounter.With(stageLabels).Inc()
+			}
 
 			if err := pusher.Add(); err != nil {
 				return true, err
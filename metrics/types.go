@@ -2,10 +2,60 @@ package metrics
 
 type BackupMetrics struct {
 	Successful                bool
+	SkippedUnsupportedVersion bool
+	SkippedPreflight          bool
 	BackupSizeMeasurement     int64
 	CreationTimeMeasurement   int64
 	EncryptionTimeMeasurement int64
 	UploadTimeMeasurement     int64
+	Destinations              []DestinationMetrics
+	Preflight                 []PreflightMetrics
+	Replication               []ReplicationMetrics
+	Stages                    []StageMetrics
+	// Export is set for successful v3 backups when exports are enabled.
+	Export *ExportMetrics
+
+	// FailedStage is the stage a failed backup stopped at, e.g. upload.
+	FailedStage string
+	// FailureReason tells why a backup failed, e.g. upload for an
+	// unavailable destination.
+	FailureReason string
+}
+
+// DestinationMetrics is the upload outcome for a single backup destination.
+type DestinationMetrics struct {
+	Name                  string
+	Successful            bool
+	UploadTimeMeasurement int64
+}
+
+// ReplicationMetrics is the replication outcome for a single replication
+// target.
+type ReplicationMetrics struct {
+	Target         string
+	Status         string
+	LagMeasurement int64
+}
+
+// ExportMetrics is the outcome of the logical export of a backup.
+type ExportMetrics struct {
+	Successful bool
+	// FailureReason tells why a failed export failed, see BackupMetrics.
+	FailureReason string
+}
+
+// StageMetrics is the work of one stage of the backup pipeline, e.g.
+// compress. BusyMeasurement excludes time spent waiting for other stages.
+type StageMetrics struct {
+	Name            string
+	Bytes           int64
+	BusyMeasurement int64
+}
+
+// PreflightMetrics is the outcome of one check run before the snapshot.
+type PreflightMetrics struct {
+	Check  string
+	Passed bool
 }
 
 type ClusterInfo struct {
@@ -22,6 +72,14 @@ func NewSuccessfulBackupMetrics(backupSize int64, creationTime int64, encryption
 	}
 }
 
+// NewStageFailureMetrics returns the metrics of a backup that failed at stage.
+func NewStageFailureMetrics(stage string) *BackupMetrics {
+	m := NewFailureMetrics()
+	m.FailedStage = stage
+
+	return m
+}
+
 func NewFailureMetrics() *BackupMetrics {
 	return &BackupMetrics{
 		Successful:                false,
@@ -31,3 +89,26 @@ func NewFailureMetrics() *BackupMetrics {
 		UploadTimeMeasurement:     -1,
 	}
 }
+
+func NewSkippedUnsupportedVersionMetrics() *BackupMetrics {
+	return &BackupMetrics{
+		Successful:                false,
+		SkippedUnsupportedVersion: true,
+		BackupSizeMeasurement:     -1,
+		CreationTimeMeasurement:   -1,
+		EncryptionTimeMeasurement: -1,
+		UploadTimeMeasurement:     -1,
+	}
+}
+
+func NewSkippedPreflightMetrics(preflight []PreflightMetrics) *BackupMetrics {
+	return &BackupMetrics{
+		Successful:                false,
+		SkippedPreflight:          true,
+		BackupSizeMeasurement:     -1,
+		CreationTimeMeasurement:   -1,
+		EncryptionTimeMeasurement: -1,
+		UploadTimeMeasurement:     -1,
+		Preflight:                 preflight,
+	}
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+const (
+	// ReasonUnsupportedVersion marks guest clusters skipped by the version
+	// policy.
+	ReasonUnsupportedVersion = "skipped_unsupported_version"
+	// ReasonPreflightFailed marks clusters skipped because a pre-flight
+	// check failed. The failed check is named in the message.
+	ReasonPreflightFailed = "skipped_preflight_failed"
+)
+
+// Report collects the outcome of every cluster processed in a run.
+type Report struct {
+	mutex sync.Mutex
+
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Entries  []Entry   `json:"entries"`
+}
+
+// Entry is the outcome for a single cluster. ClusterID is empty for the host
+// cluster.
+type Entry struct {
+	ClusterID string `json:"clusterID"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+	// Preflight is set for v3 backups unless pre-flight checks are
+	// disabled.
+	Preflight []Check `json:"preflight,omitempty"`
+	// Replication is set when replication verification is enabled.
+	Replication []Replication `json:"replication,omitempty"`
+	// Export is set for v3 backups when exports are enabled.
+	Export *Export `json:"export,omitempty"`
+}
+
+// Export is the outcome of the logical export of a backup. It does not
+// change the status of the backup.
+type Export struct {
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Replication is the replication outcome of a backup for a single
+// replication target.
+type Replication struct {
+	Target string `json:"target"`
+	Status string `json:"status"`
+	LagMs  int64  `json:"lagMs"`
+}
+
+// Check is the outcome of one pre-flight check.
+type Check struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+func New() *Report {
+	return &Report{
+		Started: time.Now(),
+	}
+}
+
+// Add records an entry. It is safe to call on a nil report.
+func (r *Report) Add(e Entry) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Entries = append(r.Entries, e)
+}
+
+// Write stores the report as JSON at path.
+func (r *Report) Write(path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Finished = time.Now()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+)
+
+// BackupDiff are the changed keys between the backups From and To.
+type BackupDiff struct {
+	From      string                 `json:"from"`
+	To        string                 `json:"to"`
+	Resources []etcd.ResourceChanges `json:"resources"`
+}
+
+// DiffBackups compares the keys selected by m of the backups from and to.
+// Both are a backup name or a time, which selects the newest v3 snapshot of
+// the host cluster taken at or before it. With values modified Kubernetes
+// objects get a line diff. The backups are checked against their manifests
+// unless verify is false.
+func (s *Service) DiffBackups(from string, to string, m etcd.Matcher, values bool, verify bool) (BackupDiff, error) {
+	fromName, err := s.resolveBackup(from)
+	if err != nil {
+		return BackupDiff{}, microerror.Mask(err)
+	}
+	toName, err := s.resolveBackup(to)
+	if err != nil {
+		return BackupDiff{}, microerror.Mask(err)
+	}
+
+	tmpDir, err := CreateTMPDir()
+	if err != nil {
+		return BackupDiff{}, microerror.Mask(err)
+	}
+	defer ClearTMPDir(tmpDir)
+
+	// each backup gets its own directory, both may have the same name
+	fromKeys, err := s.readBackup(fromName, filepath.Join(tmpDir, "from"), m, verify)
+	if err != nil {
+		return BackupDiff{}, microerror.Mask(err)
+	}
+	toKeys, err := s.readBackup(toName, filepath.Join(tmpDir, "to"), m, verify)
+	if err != nil {
+		return BackupDiff{}, microerror.Mask(err)
+	}
+
+	resources, err := etcd.DiffKeys(fromKeys, toKeys, values)
+	if err != nil {
+		return BackupDiff{}, microerror.Mask(err)
+	}
+
+	d := BackupDiff{
+		From:      fromName,
+		To:        toName,
+		Resources: resources,
+	}
+
+	return d, nil
+}
+
+// readBackup fetches the backup name into dir and reads the keys selected by
+// m from it.
+func (s *Service) readBackup(name string, dir string, m etcd.Matcher, verify bool) ([]etcd.KeyValue, error) {
+	// unpacked apart from the download, which may have the same name
+	dstDir := filepath.Join(dir, "unpacked")
+	err := os.MkdirAll(dstDir, 0700)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	fpath, err := s.fetch(name, dir, dstDir, verify)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return readKeys(fpath, m)
+}
+
+// resolveBackup returns the name of the backup ref refers to. A ref in
+// RFC3339 or the time format of backup names selects the newest v3 snapshot
+// of the host cluster taken at or before that time, any other ref is a
+// backup name.
+func (s *Service) resolveBackup(ref string) (string, error) {
+	at, err := time.Parse(time.RFC3339, ref)
+	if err != nil {
+		at, err = time.ParseInLocation(etcd.TimestampFormat, ref, time.Local)
+	}
+	if err != nil {
+		return ref, nil
+	}
+
+	src, err := s.source()
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	objects, err := src.List(s.Prefix)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	var name string
+	var taken time.Time
+	for _, o := range objects {
+		t, ok := etcd.SnapshotTime(s.Prefix, o.Name)
+		if !ok || t.After(at) || t.Before(taken) {
+			continue
+		}
+		name, taken = o.Name, t
+	}
+	if name == "" {
+		return "", microerror.Maskf(backupNotFoundError, "no v3 snapshot of %s taken at or before %s", s.Prefix, at.Format(time.RFC3339))
+	}
+
+	s.Logger.Log("level", "info", "msg", "Selected backup "+name+" for "+ref)
+
+	return name, nil
+}
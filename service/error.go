@@ -2,16 +2,114 @@ package service
 
 import "github.com/giantswarm/microerror"
 
-var invalidProviderError = microerror.New("invalid provider")
+var invalidConfigError = microerror.New("invalid config")
 
 // IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidProviderError = microerror.New("invalid provider")
+
+// IsInvalidProvider asserts invalidProviderError.
 func IsInvalidProvider(err error) bool {
 	return microerror.Cause(err) == invalidProviderError
 }
 
 var failedBackupError = microerror.New("backup failed")
 
-// IsInvalidConfig asserts invalidConfigError.
+// IsFailedBackupError asserts failedBackupError.
 func IsFailedBackupError(err error) bool {
 	return microerror.Cause(err) == failedBackupError
 }
+
+var invalidKubeconfigError = microerror.New("invalid kubeconfig")
+
+// IsInvalidKubeconfig asserts invalidKubeconfigError.
+func IsInvalidKubeconfig(err error) bool {
+	return microerror.Cause(err) == invalidKubeconfigError
+}
+
+var invalidCertSecretError = microerror.New("invalid cert secret")
+
+// IsInvalidCertSecret asserts invalidCertSecretError.
+func IsInvalidCertSecret(err error) bool {
+	return microerror.Cause(err) == invalidCertSecretError
+}
+
+var unsupportedBackupError = microerror.New("unsupported backup")
+
+// IsUnsupportedBackup asserts unsupportedBackupError.
+func IsUnsupportedBackup(err error) bool {
+	return microerror.Cause(err) == unsupportedBackupError
+}
+
+var backupNotFoundError = microerror.New("backup not found")
+
+// IsBackupNotFound asserts backupNotFoundError.
+func IsBackupNotFound(err error) bool {
+	return microerror.Cause(err) == backupNotFoundError
+}
+
+var invalidRevisionError = microerror.New("invalid revision")
+
+// IsInvalidRevision asserts invalidRevisionError.
+func IsInvalidRevision(err error) bool {
+	return microerror.Cause(err) == invalidRevisionError
+}
+
+var notRestoredError = microerror.New("not restored")
+
+// IsNotRestored asserts notRestoredError.
+func IsNotRestored(err error) bool {
+	return microerror.Cause(err) == notRestoredError
+}
+
+var outsideWindowError = microerror.New("outside retained window")
+
+// IsOutsideWindow asserts outsideWindowError.
+func IsOutsideWindow(err error) bool {
+	return microerror.Cause(err) == outsideWindowError
+}
+
+var outputExistsError = microerror.New("output exists")
+
+// IsOutputExists asserts outputExistsError.
+func IsOutputExists(err error) bool {
+	return microerror.Cause(err) == outputExistsError
+}
+
+var guestClusterNotFoundError = microerror.New("guest cluster not found")
+
+// IsGuestClusterNotFound asserts guestClusterNotFoundError.
+func IsGuestClusterNotFound(err error) bool {
+	return microerror.Cause(err) == guestClusterNotFoundError
+}
+
+var discoveryFailedError = microerror.New("discovery failed")
+
+// IsDiscoveryFailed asserts discoveryFailedError.
+func IsDiscoveryFailed(err error) bool {
+	return microerror.Cause(err) == discoveryFailedError
+}
+
+var certificateFailedError = microerror.New("certificate failed")
+
+// IsCertificateFailed asserts certificateFailedError.
+func IsCertificateFailed(err error) bool {
+	return microerror.Cause(err) == certificateFailedError
+}
+
+var preflightFailedError = microerror.New("pre-flight check failed")
+
+// IsPreflightFailed asserts preflightFailedError.
+func IsPreflightFailed(err error) bool {
+	return microerror.Cause(err) == preflightFailedError
+}
+
+var partialGuestFailureError = microerror.New("partial guest failure")
+
+// IsPartialGuestFailure asserts partialGuestFailureError.
+func IsPartialGuestFailure(err error) bool {
+	return microerror.Cause(err) == partialGuestFailureError
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+)
+
+// ExtractKeys reads the keys selected by m from the backup name, a v3
+// snapshot or a logical export, without restoring a cluster. The backup is
+// checked against its manifest unless verify is false.
+func (s *Service) ExtractKeys(name string, m etcd.Matcher, verify bool) ([]etcd.KeyValue, error) {
+	tmpDir, err := CreateTMPDir()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer ClearTMPDir(tmpDir)
+
+	// unpacked apart from the download, which may have the same name
+	dstDir := filepath.Join(tmpDir, "unpacked")
+	err = os.Mkdir(dstDir, 0700)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	fpath, err := s.fetch(name, tmpDir, dstDir, verify)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return readKeys(fpath, m)
+}
+
+// readKeys reads the keys selected by m from the unpacked backup at fpath.
+func readKeys(fpath string, m etcd.Matcher) ([]etcd.KeyValue, error) {
+	switch {
+	case strings.HasSuffix(fpath, ".journal.jsonl"):
+		// journals hold changes, not keys, they are applied with replay
+		return nil, microerror.Maskf(unsupportedBackupError, "%s is a journal segment", filepath.Base(fpath))
+	case strings.HasSuffix(fpath, ".db"):
+		kvs, _, err := etcd.ReadSnapshot(fpath, m)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		return kvs, nil
+	case strings.HasSuffix(fpath, ".jsonl"):
+		kvs, err := etcd.ReadExport(fpath, m)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		return kvs, nil
+	}
+
+	return nil, microerror.Maskf(unsupportedBackupError, "%s is no v3 snapshot or export", filepath.Base(fpath))
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"path/filepath"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/discovery"
+	"github.com/giantswarm/etcd-backup/etcd"
+)
+
+// GuestRestore is the plan to restore the etcd of a guest cluster from the
+// backups written by BackupGuestClusters, and its outcome once applied.
+type GuestRestore struct {
+	Cluster discovery.GuestCluster
+	Backup  PointBackup
+	// Members lists where the data directory of every member is written.
+	Members []MemberRestore
+	// OutputDir is where the data directories and client certificates of
+	// the cluster are written.
+	OutputDir string
+
+	// Applied is set when the data directories were written. Restore and
+	// the certificate files are only set then.
+	Applied bool
+	Restore ClusterRestore
+	CACert  string
+	Cert    string
+	Key     string
+}
+
+// RestoreGuestCluster plans the restore of the guest cluster clusterID
+// found by the same discovery as its backups. The v3 snapshot name is
+// restored, or without name the newest one before p and its journal
+// segments, where the zero Point selects the latest backup. Only backups
+// named Prefix + BackupPrefix(clusterID) are considered. Unless apply is
+// set nothing is downloaded or written, otherwise the data directories of
+// members are written like RestoreCluster and the client certificates of the
+// cluster next to them, both to a directory named after the cluster in
+// outputDir.
+func (s *Service) RestoreGuestCluster(clusterID string, name string, p Point, members []etcd.ClusterMember, outputDir string, tarball bool, apply bool, verify bool) (GuestRestore, error) {
+	certSecret, err := s.certSecret()
+	if err != nil {
+		return GuestRestore{}, microerror.Mask(err)
+	}
+	source, err := s.clusterSource(certSecret)
+	if err != nil {
+		return GuestRestore{}, microerror.Mask(err)
+	}
+	clusterList, err := source.Clusters()
+	if err != nil {
+		return GuestRestore{}, microerror.Mask(err)
+	}
+
+	r := GuestRestore{
+		OutputDir: filepath.Join(outputDir, clusterID),
+	}
+	for _, m := range members {
+		r.Members = append(r.Members, MemberRestore{ClusterMember: m, Path: memberPath(r.OutputDir, m, tarball)})
+	}
+	found := false
+	for _, c := range clusterList {
+		if c.ID == clusterID {
+			r.Cluster = c
+			found = true
+			break
+		}
+	}
+	if !found {
+		return GuestRestore{}, microerror.Maskf(guestClusterNotFoundError, "%s is not in the guest cluster list %v", clusterID, clusterIDs(clusterList))
+	}
+
+	src, err := s.source()
+	if err != nil {
+		return GuestRestore{}, microerror.Mask(err)
+	}
+	prefix := s.Prefix + BackupPrefix(clusterID)
+	if name == "" {
+		r.Backup, err = s.resolvePoint(src, prefix, p)
+		if err != nil {
+			return GuestRestore{}, microerror.Mask(err)
+		}
+	} else {
+		taken, ok := etcd.SnapshotTime(prefix, name)
+		if !ok {
+			return GuestRestore{}, microerror.Maskf(unsupportedBackupError, "%s is no v3 snapshot of guest cluster %s", name, clusterID)
+		}
+		sp, err := s.snapshotPoint(src, name, taken)
+		if err != nil {
+			return GuestRestore{}, microerror.Mask(err)
+		}
+		r.Backup = PointBackup{
+			Snapshot:         name,
+			SnapshotRevision: sp.revision,
+			Revision:         sp.revision,
+		}
+	}
+
+	if !apply {
+		s.Logger.Log("level", "info", "msg", "Planned restore of guest cluster "+clusterID, "backup", r.Backup.Snapshot)
+		return r, nil
+	}
+
+	// fail on missing certificates before anything is downloaded
+	k8sClient, err := CreateK8sClient(s.Logger, s.Kubeconfig, s.KubeContext)
+	if err != nil {
+		return GuestRestore{}, microerror.Mask(err)
+	}
+	certs, err := FetchCerts(r.Cluster.CertSecretRef, certSecret.Keys, k8sClient)
+	if err != nil {
+		return GuestRestore{}, microerror.Mask(err)
+	}
+
+	r.Restore, err = s.RestoreCluster(r.Backup.Snapshot, members, r.OutputDir, tarball, verify)
+	if err != nil {
+		return GuestRestore{}, microerror.Mask(err)
+	}
+	err = CreateCertFiles(clusterID, certs, r.OutputDir)
+	if err != nil {
+		return GuestRestore{}, microerror.Mask(err)
+	}
+	r.Applied = true
+	r.CACert = certs.CAFile
+	r.Cert = certs.CrtFile
+	r.Key = certs.KeyFile
+
+	s.Logger.Log("level", "info", "msg", "Restored guest cluster "+clusterID+" to "+r.OutputDir, "backup", r.Backup.Snapshot)
+
+	return r, nil
+}
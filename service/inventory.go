@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"os"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/discovery"
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/metrics"
+	"github.com/giantswarm/etcd-backup/report"
+	"github.com/giantswarm/microerror"
+)
+
+// backup all etcd clusters listed in the inventory file
+func (s *Service) BackupInventory() error {
+	inventory, err := discovery.LoadInventory(s.Inventory)
+	if err != nil {
+		return s.guestDiscoveryFailed(err)
+	}
+
+	tmpDir, err := CreateTMPDir()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer ClearTMPDir(tmpDir)
+
+	awsConfig, defaultPass, err := s.secrets()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	uploader, err := s.uploader(awsConfig)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	certSecret, err := s.certSecret()
+	if err != nil {
+		return s.guestDiscoveryFailed(err)
+	}
+
+	// k8s client is only needed when certificates are read from secrets
+	var k8sClient kubernetes.Interface
+
+	// one failed target should not cancel backup of the rest
+	failed := 0
+
+	for _, target := range inventory.Targets {
+		encryptPass, err := targetPassphrase(target, defaultPass)
+		if err != nil {
+			failed++
+			s.Logger.Log("level", "error", "msg", "Failed to read passphrase for target "+target.Name, "reason", err)
+			s.Report.Add(report.Entry{ClusterID: target.Name, Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()})
+			metrics.Send(s.PrometheusConfig, failureMetrics(err), target.Name)
+			continue
+		}
+
+		// limits were validated when loading the inventory
+		uploadLimit, readLimit, err := s.rateLimits(target.RateLimits)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		backupConfig := etcd.EtcdBackupV3{
+			Logger: s.Logger,
+
+			Uploader: uploader,
+			CACert:   target.CAFile,
+			Cert:     target.CertFile,
+			Key:      target.KeyFile,
+
+			Prefix:    target.BackupPrefix(),
+			EncPass:   encryptPass,
+			Endpoints: target.EndpointList(),
+
+			Compression:               s.Compression,
+			SnapshotDiskReadRateLimit: readLimit,
+			UploadRateLimit:           uploadLimit,
+			TmpDir:                    tmpDir,
+		}
+
+		if target.CertSecretRef != nil {
+			if k8sClient == nil {
+				k8sClient, err = CreateK8sClient(s.Logger, s.Kubeconfig, s.KubeContext)
+				if err != nil {
+					return s.guestDiscoveryFailed(err)
+				}
+			}
+
+			certs, err := FetchCerts(*target.CertSecretRef, certSecret.Keys, k8sClient)
+			if err != nil {
+				failed++
+				s.Logger.Log("level", "error", "msg", "Failed to fetch etcd certs for target "+target.Name, "reason", err)
+				s.Report.Add(report.Entry{ClusterID: target.Name, Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()})
+				metrics.Send(s.PrometheusConfig, failureMetrics(err), target.Name)
+				continue
+			}
+			err = CreateCertFiles(target.Name, certs, tmpDir)
+			if err != nil {
+				failed++
+				s.Logger.Log("level", "error", "msg", "Failed to write etcd certs to tmpdir for target "+target.Name, "reason", err)
+				s.Report.Add(report.Entry{ClusterID: target.Name, Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()})
+				metrics.Send(s.PrometheusConfig, failureMetrics(err), target.Name)
+				continue
+			}
+
+			backupConfig.CACert = certs.CAFile
+			backupConfig.Cert = certs.CrtFile
+			backupConfig.Key = certs.KeyFile
+		}
+
+		err = s.backupWithRetry(&backupConfig, target.Name)
+		if err != nil {
+			failed++
+			s.Logger.Log("level", "error", "msg", "Failed to backup etcd target "+target.Name, "reason", err)
+		}
+	}
+
+	if failed > 0 {
+		s.Logger.Log("level", "error", "msg", "Failed to backup all inventory targets")
+		return microerror.Maskf(partialGuestFailureError, "%d of %d inventory targets failed", failed, len(inventory.Targets))
+	}
+
+	s.Logger.Log("level", "info", "msg", fmt.Sprintf("Finished inventory backup. Total targets: %d", len(inventory.Targets)))
+
+	return nil
+}
+
+// targetPassphrase returns the passphrase to encrypt backups of the target
+// with. An empty passphrase disables encryption.
+func targetPassphrase(target discovery.Target, defaultPass string) (string, error) {
+	if target.Encryption.Disabled {
+		return "", nil
+	}
+	if target.Encryption.PassphraseFile != "" {
+		pass, err := config.Secret{File: target.Encryption.PassphraseFile}.Get()
+		if err != nil {
+			return "", microerror.Maskf(invalidConfigError, "failed to read passphrase of target %s: %s", target.Name, err)
+		}
+		return pass, nil
+	}
+	if target.Encryption.PassphraseEnv != "" {
+		return os.Getenv(target.Encryption.PassphraseEnv), nil
+	}
+	return defaultPass, nil
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+)
+
+// ReplayResult describes the journal segments applied after a snapshot.
+type ReplayResult struct {
+	Snapshot string
+	// Revision is the last revision applied, the snapshot revision when no
+	// segment was applied.
+	Revision int64
+	Segments int
+	Keys     int
+}
+
+// addJournal records the changes after the snapshot v3 has taken until the
+// next backup, if enabled. The connection files are copied, they are
+// removed with the temporary directory of the backup.
+func (s *Service) addJournal(v3 *etcd.EtcdBackupV3) {
+	if !s.Journal {
+		return
+	}
+
+	j, err := s.newJournal(v3)
+	if err != nil {
+		s.Logger.Log("level", "error", "msg", "Failed to start journal for: "+v3.Prefix, "reason", err)
+		return
+	}
+	s.journals = append(s.journals, j)
+}
+
+func (s *Service) newJournal(v3 *etcd.EtcdBackupV3) (*etcd.EtcdJournal, error) {
+	if s.journalDir == "" {
+		dir, err := CreateTMPDir()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		s.journalDir = dir
+	}
+
+	dir := filepath.Join(s.journalDir, strconv.Itoa(len(s.journals)))
+	err := os.Mkdir(dir, 0700)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	c := v3.Connection()
+	files := []struct {
+		path *string
+		name string
+	}{
+		{path: &c.CACert, name: "ca.pem"},
+		{path: &c.Cert, name: "crt.pem"},
+		{path: &c.Key, name: "key.pem"},
+	}
+	for _, file := range files {
+		if *file.path == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(*file.path)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		copied := filepath.Join(dir, file.name)
+		err = ioutil.WriteFile(copied, data, fileMode)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		*file.path = copied
+	}
+
+	return v3.Journal(c, s.JournalSegmentInterval, dir), nil
+}
+
+// RunJournals records the changes of every cluster backed up since the last
+// call for d, i.e. until the next backup. It returns after d, also without
+// journals or when they failed early. It fails when any journal failed, the
+// others are recorded until the end.
+func (s *Service) RunJournals(d time.Duration) error {
+	journals := s.journals
+	s.journals = nil
+	defer func() {
+		ClearTMPDir(s.journalDir)
+		s.journalDir = ""
+	}()
+
+	stop := make(chan struct{})
+	timer := time.AfterFunc(d, func() { close(stop) })
+	defer timer.Stop()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var failed int
+	for _, j := range journals {
+		wg.Add(1)
+		go func(j *etcd.EtcdJournal) {
+			defer wg.Done()
+
+			err := j.Run(stop)
+			if err != nil {
+				s.Logger.Log("level", "error", "msg", "Failed to record journal for: "+j.Prefix, "revision", j.Revision, "reason", err)
+				mutex.Lock()
+				failed++
+				mutex.Unlock()
+			}
+		}(j)
+	}
+	wg.Wait()
+	<-stop
+
+	if failed > 0 {
+		return microerror.Maskf(failedBackupError, "%d of %d journals failed", failed, len(journals))
+	}
+
+	return nil
+}
+
+// ReplayJournal applies the journal segments recorded after the v3 snapshot
+// name to the cluster at the configured v3 endpoints, which must be restored
+// from the snapshot. Changes up to toRevision are applied, or with at set up
+// to the last segment closed at or before it. Without either all recorded
+// changes are applied. Segments are checked against their manifests unless
+// verify is false.
+func (s *Service) ReplayJournal(name string, toRevision int64, at time.Time, verify bool) (ReplayResult, error) {
+	prefix, ok := etcd.SnapshotPrefix(name)
+	if !ok {
+		return ReplayResult{}, microerror.Maskf(unsupportedBackupError, "%s is no v3 snapshot", name)
+	}
+
+	src, err := s.source()
+	if err != nil {
+		return ReplayResult{}, microerror.Mask(err)
+	}
+	m, err := src.Manifest(name)
+	if err != nil {
+		return ReplayResult{}, microerror.Mask(err)
+	}
+	revision, err := strconv.ParseInt(m.Metadata[etcd.MetadataRevision], 10, 64)
+	if err != nil {
+		return ReplayResult{}, microerror.Maskf(unsupportedBackupError, "manifest of %s has no revision", name)
+	}
+	if toRevision != 0 && toRevision < revision {
+		return ReplayResult{}, microerror.Maskf(invalidRevisionError, "%s is at revision %d, after revision %d", name, revision, toRevision)
+	}
+
+	objects, err := src.List(prefix)
+	if err != nil {
+		return ReplayResult{}, microerror.Mask(err)
+	}
+	var segments []etcd.JournalSegment
+	for _, o := range objects {
+		segment, ok := etcd.ParseJournalSegment(prefix, o.Name)
+		if ok {
+			segments = append(segments, segment)
+		}
+	}
+	segments, err = etcd.SelectSegments(segments, revision, toRevision, at)
+	if err != nil {
+		return ReplayResult{}, microerror.Mask(err)
+	}
+
+	c := etcd.Connection{
+		CACert:    s.EtcdV3CACert,
+		Cert:      s.EtcdV3Cert,
+		Key:       s.EtcdV3Key,
+		Endpoints: s.EtcdV3Endpoints,
+	}
+	err = s.checkRestored(c, name, revision)
+	if err != nil {
+		return ReplayResult{}, microerror.Mask(err)
+	}
+
+	b := PointBackup{
+		Snapshot:         name,
+		SnapshotRevision: revision,
+		Segments:         segments,
+		Revision:         toRevision,
+	}
+	if toRevision == 0 {
+		b.Revision = revision
+		if len(segments) > 0 {
+			b.Revision = segments[len(segments)-1].To
+		}
+	}
+
+	tmpDir, err := CreateTMPDir()
+	if err != nil {
+		return ReplayResult{}, microerror.Mask(err)
+	}
+	defer ClearTMPDir(tmpDir)
+
+	changes, err := s.readJournal(b, tmpDir, verify)
+	if err != nil {
+		return ReplayResult{}, microerror.Mask(err)
+	}
+
+	err = etcd.ApplyJournal(c, changes, s.Logger)
+	if err != nil {
+		return ReplayResult{}, microerror.Mask(err)
+	}
+
+	r := ReplayResult{
+		Snapshot: name,
+		Revision: b.Revision,
+		Segments: len(segments),
+		Keys:     len(changes),
+	}
+	s.Logger.Log("level", "info", "msg", "Replayed journal of "+name, "revision", r.Revision, "segments", r.Segments, "keys", r.Keys)
+
+	return r, nil
+}
+
+// readJournal fetches the journal segments of b into tmpDir and returns the
+// last change of every key after the snapshot up to the revision of b.
+func (s *Service) readJournal(b PointBackup, tmpDir string, verify bool) (map[string]etcd.JournalEvent, error) {
+	changes := map[string]etcd.JournalEvent{}
+	for i, segment := range b.Segments {
+		dir := filepath.Join(tmpDir, "journal", strconv.Itoa(i))
+		dstDir := filepath.Join(dir, "unpacked")
+		err := os.MkdirAll(dstDir, 0700)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		fpath, err := s.fetch(segment.Name, dir, dstDir, verify)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		events, err := etcd.ReadJournal(fpath)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		etcd.JournalChanges(changes, events, b.SnapshotRevision, b.Revision)
+	}
+
+	return changes, nil
+}
+
+// checkRestored fails unless the cluster c looks restored from the snapshot
+// name at revision. Its revision may be a little higher, e.g. when leases
+// expired after the restore.
+func (s *Service) checkRestored(c etcd.Connection, name string, revision int64) error {
+	statuses, err := etcd.MemberStatuses(c, s.Logger)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var current int64
+	for _, m := range statuses {
+		if m.Healthy() && m.Revision > current {
+			current = m.Revision
+		}
+	}
+	if current == 0 {
+		return microerror.Maskf(notRestoredError, "no healthy etcd member at %s", c.Endpoints)
+	}
+	if current < revision {
+		return microerror.Maskf(notRestoredError, "etcd at %s is at revision %d, before %s at revision %d, restore the snapshot first", c.Endpoints, current, name, revision)
+	}
+	if current > revision {
+		s.Logger.Log("level", "warning", "msg", "Etcd changed after the restore of "+name, "revision", current, "snapshotRevision", revision)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+)
+
+func Test_Service_RunJournals_WaitsForTheInterval(t *testing.T) {
+	// etcdctl reports the revision to watch from as compacted, so the journal
+	// fails right away
+	_, cleanup := fakeEtcdctl(t, `echo '{"CompactRevision":9}'`)
+	defer cleanup()
+
+	logger := testLogger(t)
+
+	testCases := []struct {
+		name         string
+		journals     []*etcd.EtcdJournal
+		errorMatcher func(error) bool
+	}{
+		{
+			name: "case 0: no journal",
+		},
+		{
+			name: "case 1: journal failing early",
+			journals: []*etcd.EtcdJournal{
+				{Logger: logger, Prefix: "host", SegmentInterval: time.Hour},
+			},
+			errorMatcher: IsFailedBackupError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Service{Logger: logger, journals: tc.journals}
+
+			interval := 300 * time.Millisecond
+			start := time.Now()
+			err := s.RunJournals(interval)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if time.Since(start) < interval {
+				t.Fatalf("expected RunJournals to return after %s, returned after %s", interval, time.Since(start))
+			}
+		})
+	}
+}
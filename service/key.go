@@ -3,8 +3,6 @@ package service
 import (
 	"fmt"
 	"path"
-
-	"github.com/coreos/go-semver/semver"
 )
 
 const (
@@ -13,19 +11,10 @@ const (
 	azure = "azure"
 	kvm   = "kvm"
 
-	// namespace where secrets are located
-	secretNamespace = "default"
-
-	// namespace where crds are located
-	crdNamespace = "default"
-
 	fileMode = 0600
 	retries  = 3
 )
 
-var awsSupportFrom *semver.Version = semver.Must(semver.NewVersion("3.1.1"))
-var azureSupportFrom *semver.Version = semver.Must(semver.NewVersion("0.2.0"))
-
 func BackupPrefix(clusterID string) string {
 	return "-" + clusterID
 }
@@ -41,13 +30,3 @@ func CAFile(clusterID string, tmpDir string) string {
 func KeyFile(clusterID string, tmpDir string) string {
 	return path.Join(tmpDir, fmt.Sprintf("%s-%s.pem", clusterID, "key"))
 }
-
-func AwsEtcdEndpoint(etcdDomain string) string {
-	return fmt.Sprintf("https://%s:2379", etcdDomain)
-}
-func AzureEtcdEndpoint(etcdDomain string) string {
-	return fmt.Sprintf("https://%s:2379", etcdDomain)
-}
-func KVMEtcdEndpoint(etcdDomain string) string {
-	return fmt.Sprintf("https://%s:443", etcdDomain)
-}
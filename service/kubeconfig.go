@@ -0,0 +1,238 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/operatorkit/client/k8srestconfig"
+	"sigs.k8s.io/yaml"
+)
+
+// kubeconfig is the subset of the kubeconfig file format needed to reach the
+// host cluster API.
+type kubeconfig struct {
+	Clusters []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData []byte `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			ClientCertificate     string `json:"client-certificate"`
+			ClientCertificateData []byte `json:"client-certificate-data"`
+			ClientKey             string `json:"client-key"`
+			ClientKeyData         []byte `json:"client-key-data"`
+			Token                 string `json:"token"`
+			TokenFile             string `json:"tokenFile"`
+			Username              string `json:"username"`
+			Password              string `json:"password"`
+
+			// The credentials below are not supported and only read to
+			// fail instead of connecting without credentials.
+			AuthProvider interface{} `json:"auth-provider"`
+			Exec         interface{} `json:"exec"`
+			Impersonate  string      `json:"as"`
+		} `json:"user"`
+	} `json:"users"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+	CurrentContext string `json:"current-context"`
+}
+
+// kubeconfigCluster holds the settings of the selected kubeconfig context.
+type kubeconfigCluster struct {
+	Address  string
+	Insecure bool
+	TLS      k8srestconfig.TLSClientConfig
+	Token    string
+	Username string
+	Password string
+}
+
+// kubeconfigPaths returns the kubeconfig files to read, which are path when
+// set and the files listed in $KUBECONFIG otherwise. No paths means
+// in-cluster config.
+func kubeconfigPaths(path string) []string {
+	if path != "" {
+		return []string{path}
+	}
+
+	var paths []string
+	for _, p := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
+
+// loadKubeconfig reads the kubeconfig files at paths and resolves the given
+// context, or the current context when kubeContext is empty. Several files
+// are merged like kubectl does: the first file setting a cluster, user,
+// context or the current context wins, and files that do not exist are
+// skipped unless there is only one. Users with credentials other than client
+// certificates, tokens or basic auth are rejected.
+func loadKubeconfig(paths []string, kubeContext string) (*kubeconfigCluster, error) {
+	source := strings.Join(paths, string(filepath.ListSeparator))
+
+	var kc kubeconfig
+	{
+		clusters := map[string]bool{}
+		users := map[string]bool{}
+		contexts := map[string]bool{}
+		read := 0
+		for _, path := range paths {
+			data, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) && len(paths) > 1 {
+				continue
+			} else if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			read++
+
+			var f kubeconfig
+			err = yaml.Unmarshal(data, &f)
+			if err != nil {
+				return nil, microerror.Maskf(invalidKubeconfigError, "%s: %s", path, err)
+			}
+
+			// relative paths in kubeconfig are relative to the file itself
+			dir := filepath.Dir(path)
+			resolve := func(p string) string {
+				if p == "" || filepath.IsAbs(p) {
+					return p
+				}
+				return filepath.Join(dir, p)
+			}
+
+			for _, c := range f.Clusters {
+				if clusters[c.Name] {
+					continue
+				}
+				clusters[c.Name] = true
+				c.Cluster.CertificateAuthority = resolve(c.Cluster.CertificateAuthority)
+				kc.Clusters = append(kc.Clusters, c)
+			}
+			for _, u := range f.Users {
+				if users[u.Name] {
+					continue
+				}
+				users[u.Name] = true
+				u.User.ClientCertificate = resolve(u.User.ClientCertificate)
+				u.User.ClientKey = resolve(u.User.ClientKey)
+				u.User.TokenFile = resolve(u.User.TokenFile)
+				kc.Users = append(kc.Users, u)
+			}
+			for _, c := range f.Contexts {
+				if contexts[c.Name] {
+					continue
+				}
+				contexts[c.Name] = true
+				kc.Contexts = append(kc.Contexts, c)
+			}
+			if kc.CurrentContext == "" {
+				kc.CurrentContext = f.CurrentContext
+			}
+		}
+		if read == 0 {
+			return nil, microerror.Maskf(invalidKubeconfigError, "none of the files %s exists", source)
+		}
+	}
+
+	if kubeContext == "" {
+		kubeContext = kc.CurrentContext
+	}
+	if kubeContext == "" {
+		return nil, microerror.Maskf(invalidKubeconfigError, "%s: no context given and no current-context set", source)
+	}
+
+	var clusterName, userName string
+	{
+		found := false
+		for _, c := range kc.Contexts {
+			if c.Name == kubeContext {
+				clusterName = c.Context.Cluster
+				userName = c.Context.User
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, microerror.Maskf(invalidKubeconfigError, "%s: context %q not found", source, kubeContext)
+		}
+	}
+
+	cluster := &kubeconfigCluster{}
+	{
+		found := false
+		for _, c := range kc.Clusters {
+			if c.Name == clusterName {
+				cluster.Address = c.Cluster.Server
+				cluster.Insecure = c.Cluster.InsecureSkipTLSVerify
+				cluster.TLS.CAFile = c.Cluster.CertificateAuthority
+				cluster.TLS.CAData = c.Cluster.CertificateAuthorityData
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, microerror.Maskf(invalidKubeconfigError, "%s: cluster %q of context %q not found", source, clusterName, kubeContext)
+		}
+	}
+
+	// a context without user connects without credentials
+	if userName == "" {
+		return cluster, nil
+	}
+
+	found := false
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		found = true
+
+		if u.User.Exec != nil || u.User.AuthProvider != nil {
+			return nil, microerror.Maskf(invalidKubeconfigError, "%s: user %q of context %q uses exec or auth-provider credentials, which are not supported; use client certificates or a token", source, userName, kubeContext)
+		}
+		if u.User.Impersonate != "" {
+			return nil, microerror.Maskf(invalidKubeconfigError, "%s: user %q of context %q uses impersonation, which is not supported", source, userName, kubeContext)
+		}
+
+		cluster.TLS.CrtFile = u.User.ClientCertificate
+		cluster.TLS.CrtData = u.User.ClientCertificateData
+		cluster.TLS.KeyFile = u.User.ClientKey
+		cluster.TLS.KeyData = u.User.ClientKeyData
+		cluster.Token = u.User.Token
+		cluster.Username = u.User.Username
+		cluster.Password = u.User.Password
+
+		if cluster.Token == "" && u.User.TokenFile != "" {
+			token, err := ioutil.ReadFile(u.User.TokenFile)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			cluster.Token = strings.TrimSpace(string(token))
+		}
+		break
+	}
+	if !found {
+		return nil, microerror.Maskf(invalidKubeconfigError, "%s: user %q of context %q not found", source, userName, kubeContext)
+	}
+
+	return cluster, nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+const testKubeconfig = `
+current-context: main
+clusters:
+- name: main
+  cluster:
+    server: https://api.example.com
+    certificate-authority: ca.pem
+contexts:
+- name: main
+  context:
+    cluster: main
+    user: admin
+- name: token
+  context:
+    cluster: main
+    user: token
+- name: exec
+  context:
+    cluster: main
+    user: exec
+- name: auth-provider
+  context:
+    cluster: main
+    user: auth-provider
+- name: missing-user
+  context:
+    cluster: main
+    user: nobody
+- name: missing-cluster
+  context:
+    cluster: nowhere
+    user: admin
+- name: anonymous
+  context:
+    cluster: main
+users:
+- name: admin
+  user:
+    client-certificate: admin.pem
+    client-key: /etc/admin-key.pem
+- name: token
+  user:
+    tokenFile: token
+- name: exec
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws-iam-authenticator
+- name: auth-provider
+  user:
+    auth-provider:
+      name: oidc
+`
+
+// testOverride shadows the main cluster and sets its own current context, both
+// of which lose against a file listed before it.
+const testOverride = `
+current-context: token
+clusters:
+- name: main
+  cluster:
+    server: https://override.example.com
+- name: other
+  cluster:
+    server: https://other.example.com
+contexts:
+- name: other
+  context:
+    cluster: other
+`
+
+func Test_loadKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-backup-kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config":   testKubeconfig,
+		"override": testOverride,
+		"token":    "secret-token\n",
+	}
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := filepath.Join(dir, "config")
+	override := filepath.Join(dir, "override")
+	missing := filepath.Join(dir, "missing")
+
+	main := kubeconfigCluster{Address: "https://api.example.com"}
+	main.TLS.CAFile = filepath.Join(dir, "ca.pem")
+
+	admin := main
+	admin.TLS.CrtFile = filepath.Join(dir, "admin.pem")
+	admin.TLS.KeyFile = "/etc/admin-key.pem"
+
+	token := main
+	token.Token = "secret-token"
+
+	testCases := []struct {
+		name         string
+		paths        []string
+		context      string
+		expected     *kubeconfigCluster
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: current context with paths relative to the file",
+			paths:    []string{config},
+			expected: &admin,
+		},
+		{
+			name:     "case 1: token file",
+			paths:    []string{config},
+			context:  "token",
+			expected: &token,
+		},
+		{
+			name:     "case 2: context without user",
+			paths:    []string{config},
+			context:  "anonymous",
+			expected: &main,
+		},
+		{
+			name:         "case 3: exec credentials",
+			paths:        []string{config},
+			context:      "exec",
+			errorMatcher: IsInvalidKubeconfig,
+		},
+		{
+			name:         "case 4: auth-provider credentials",
+			paths:        []string{config},
+			context:      "auth-provider",
+			errorMatcher: IsInvalidKubeconfig,
+		},
+		{
+			name:         "case 5: missing user",
+			paths:        []string{config},
+			context:      "missing-user",
+			errorMatcher: IsInvalidKubeconfig,
+		},
+		{
+			name:         "case 6: missing cluster",
+			paths:        []string{config},
+			context:      "missing-cluster",
+			errorMatcher: IsInvalidKubeconfig,
+		},
+		{
+			name:         "case 7: missing context",
+			paths:        []string{config},
+			context:      "nope",
+			errorMatcher: IsInvalidKubeconfig,
+		},
+		{
+			name:     "case 8: first file wins when merging, missing files are skipped",
+			paths:    []string{missing, config, override},
+			expected: &admin,
+		},
+		{
+			name:     "case 9: contexts of later files are merged",
+			paths:    []string{config, override},
+			context:  "other",
+			expected: &kubeconfigCluster{Address: "https://other.example.com"},
+		},
+		{
+			name:         "case 10: none of the files exists",
+			paths:        []string{missing, missing + "2"},
+			errorMatcher: IsInvalidKubeconfig,
+		},
+		{
+			name:         "case 11: a single file must exist",
+			paths:        []string{missing},
+			errorMatcher: func(err error) bool { return os.IsNotExist(microerror.Cause(err)) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster, err := loadKubeconfig(tc.paths, tc.context)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !reflect.DeepEqual(cluster, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, cluster)
+			}
+		})
+	}
+}
+
+func Test_kubeconfigPaths(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	t.Setenv("KUBECONFIG", "/a"+sep+sep+"/b")
+
+	paths := kubeconfigPaths("")
+	if !reflect.DeepEqual(paths, []string{"/a", "/b"}) {
+		t.Fatalf("expected the files in KUBECONFIG, got %#v", paths)
+	}
+
+	paths = kubeconfigPaths("/c")
+	if !reflect.DeepEqual(paths, []string{"/c"}) {
+		t.Fatalf("expected the given file to win over KUBECONFIG, got %#v", paths)
+	}
+
+	t.Setenv("KUBECONFIG", "")
+	paths = kubeconfigPaths("")
+	if len(paths) != 0 {
+		t.Fatalf("expected no files for in-cluster config, got %#v", paths)
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+// Point is a point in time or a revision of a cluster to restore. Both may
+// be set, the backup must then be at or before both. The zero Point is the
+// latest recorded state.
+type Point struct {
+	At       time.Time
+	Revision int64
+}
+
+func (p Point) String() string {
+	switch {
+	case p.At.IsZero() && p.Revision == 0:
+		return "latest"
+	case !p.At.IsZero() && p.Revision != 0:
+		return fmt.Sprintf("%s and revision %d", p.At.Format(time.RFC3339), p.Revision)
+	case p.Revision != 0:
+		return fmt.Sprintf("revision %d", p.Revision)
+	}
+
+	return p.At.Format(time.RFC3339)
+}
+
+// PointBackup is the backup selected for a Point: the newest v3 snapshot
+// before it and the journal segments recorded after the snapshot up to it.
+type PointBackup struct {
+	Snapshot         string
+	SnapshotRevision int64
+	Segments         []etcd.JournalSegment
+	// Revision is the revision the snapshot and its segments restore.
+	Revision int64
+}
+
+// snapshotPoint is a stored v3 snapshot as described by its manifest.
+type snapshotPoint struct {
+	name     string
+	created  time.Time
+	revision int64
+}
+
+// resolvePoint selects the backups of prefix that restore p: the newest v3
+// snapshot taken at or before p and the journal segments after it up to p.
+// The time of a snapshot and its revision are read from its manifest. It
+// fails when p is before the oldest snapshot or after the last change that
+// was recorded.
+func (s *Service) resolvePoint(src storage.Source, prefix string, p Point) (PointBackup, error) {
+	objects, err := src.List(prefix)
+	if err != nil {
+		return PointBackup{}, microerror.Mask(err)
+	}
+
+	type snapshotName struct {
+		name string
+		time time.Time
+	}
+	var names []snapshotName
+	var segments []etcd.JournalSegment
+	for _, o := range objects {
+		if t, ok := etcd.SnapshotTime(prefix, o.Name); ok {
+			names = append(names, snapshotName{name: o.Name, time: t})
+		} else if segment, ok := etcd.ParseJournalSegment(prefix, o.Name); ok {
+			segments = append(segments, segment)
+		}
+	}
+	if len(names) == 0 {
+		return PointBackup{}, microerror.Maskf(backupNotFoundError, "no v3 snapshot of %s", prefix)
+	}
+	// newest first, revisions grow with time
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].time.After(names[j].time)
+	})
+
+	var newer, selected, oldest *snapshotPoint
+	for _, n := range names {
+		sp, err := s.snapshotPoint(src, n.name, n.time)
+		if err != nil {
+			return PointBackup{}, microerror.Mask(err)
+		}
+		oldest = &sp
+
+		if p.Revision != 0 && sp.revision == 0 {
+			// taken before revisions were recorded
+			continue
+		}
+		if (p.At.IsZero() || !sp.created.After(p.At)) && (p.Revision == 0 || sp.revision <= p.Revision) {
+			selected = &sp
+			break
+		}
+		newer = &sp
+	}
+	if selected == nil {
+		return PointBackup{}, microerror.Maskf(outsideWindowError, "%s is before the oldest backup %s of %s", p, oldest.name, oldest.describe())
+	}
+
+	b := PointBackup{
+		Snapshot:         selected.name,
+		SnapshotRevision: selected.revision,
+		Revision:         selected.revision,
+	}
+	// last is the time of the last change recorded after the snapshot,
+	// including segments closed after p
+	last := selected.created
+	if selected.revision != 0 {
+		b.Segments, err = etcd.SelectSegments(segments, selected.revision, 0, p.At)
+		if err != nil {
+			return PointBackup{}, microerror.Mask(err)
+		}
+		if n := len(b.Segments); n > 0 {
+			b.Revision = b.Segments[n-1].To
+		}
+
+		recorded, err := etcd.SelectSegments(segments, selected.revision, 0, time.Time{})
+		if err != nil {
+			return PointBackup{}, microerror.Mask(err)
+		}
+		if n := len(recorded); n > 0 {
+			last = recorded[n-1].Time
+		}
+	}
+
+	if p.Revision != 0 {
+		if b.Revision < p.Revision {
+			msg := fmt.Sprintf("%s is not retained, %s is at revision %d", p, selected.name, selected.revision)
+			if len(b.Segments) > 0 {
+				msg += fmt.Sprintf(" and its journal ends at revision %d", b.Revision)
+			}
+			if newer != nil {
+				msg += fmt.Sprintf(", the next backup %s is at revision %d", newer.name, newer.revision)
+			}
+			return PointBackup{}, microerror.Maskf(outsideWindowError, "%s", msg)
+		}
+		b.Revision = p.Revision
+		b.Segments, err = etcd.SelectSegments(segments, selected.revision, p.Revision, time.Time{})
+		if err != nil {
+			return PointBackup{}, microerror.Mask(err)
+		}
+	} else if newer == nil && p.At.After(last) {
+		// nothing recorded after the newest backup tells the state at p
+		return PointBackup{}, microerror.Maskf(outsideWindowError, "%s is after the newest backup, the last recorded change is from %s", p, last.UTC().Format(time.RFC3339))
+	}
+
+	s.Logger.Log("level", "info", "msg", fmt.Sprintf("Selected backup %s and %d journal segments for %s", b.Snapshot, len(b.Segments), p), "revision", b.Revision)
+
+	return b, nil
+}
+
+// snapshotPoint reads the manifest of the snapshot name. Snapshots without
+// manifest time use the time in their name.
+func (s *Service) snapshotPoint(src storage.Source, name string, taken time.Time) (snapshotPoint, error) {
+	m, err := src.Manifest(name)
+	if err != nil {
+		return snapshotPoint{}, microerror.Mask(err)
+	}
+
+	sp := snapshotPoint{
+		name:    name,
+		created: m.Created,
+	}
+	if sp.created.IsZero() {
+		sp.created = taken
+	}
+	if r, ok := m.Metadata[etcd.MetadataRevision]; ok {
+		sp.revision, err = strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return snapshotPoint{}, microerror.Maskf(invalidRevisionError, "manifest of %s: %s", name, err)
+		}
+	}
+
+	return sp, nil
+}
+
+func (sp snapshotPoint) describe() string {
+	if sp.revision == 0 {
+		return sp.created.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s at revision %d", sp.created.UTC().Format(time.RFC3339), sp.revision)
+}
+
+// ResolvePoint selects the backups of the host cluster restoring p, like
+// RestorePoint without downloading them.
+func (s *Service) ResolvePoint(p Point) (PointBackup, error) {
+	src, err := s.source()
+	if err != nil {
+		return PointBackup{}, microerror.Mask(err)
+	}
+	b, err := s.resolvePoint(src, s.Prefix, p)
+	if err != nil {
+		return PointBackup{}, microerror.Mask(err)
+	}
+
+	return b, nil
+}
+
+// RestorePoint restores the newest v3 snapshot of the host cluster before p
+// like RestoreBackup. The returned backup lists the journal segments to
+// replay on the restored cluster to reach p.
+func (s *Service) RestorePoint(p Point, outputDir string, verify bool) (string, PointBackup, error) {
+	b, err := s.ResolvePoint(p)
+	if err != nil {
+		return "", PointBackup{}, microerror.Mask(err)
+	}
+
+	restored, err := s.RestoreBackup(b.Snapshot, outputDir, verify)
+	if err != nil {
+		return "", PointBackup{}, microerror.Mask(err)
+	}
+
+	return restored, b, nil
+}
+
+// ExtractPoint reads the keys selected by m of the host cluster at p, from
+// the newest v3 snapshot before p with the journal segments after it
+// applied.
+func (s *Service) ExtractPoint(p Point, m etcd.Matcher, verify bool) ([]etcd.KeyValue, PointBackup, error) {
+	src, err := s.source()
+	if err != nil {
+		return nil, PointBackup{}, microerror.Mask(err)
+	}
+	b, err := s.resolvePoint(src, s.Prefix, p)
+	if err != nil {
+		return nil, PointBackup{}, microerror.Mask(err)
+	}
+
+	tmpDir, err := CreateTMPDir()
+	if err != nil {
+		return nil, PointBackup{}, microerror.Mask(err)
+	}
+	defer ClearTMPDir(tmpDir)
+
+	kvs, err := s.readBackup(b.Snapshot, filepath.Join(tmpDir, "snapshot"), m, verify)
+	if err != nil {
+		return nil, PointBackup{}, microerror.Mask(err)
+	}
+
+	changes, err := s.readJournal(b, tmpDir, verify)
+	if err != nil {
+		return nil, PointBackup{}, microerror.Mask(err)
+	}
+
+	return etcd.ApplyChanges(kvs, changes, m), b, nil
+}
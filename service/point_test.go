@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+// pointSource lists v3 snapshots and journal segments and serves the
+// manifests of the snapshots.
+type pointSource struct {
+	storage.Source
+	manifests map[string]storage.Manifest
+	segments  []string
+}
+
+func (s *pointSource) List(prefix string) ([]storage.Object, error) {
+	var objects []storage.Object
+	for name := range s.manifests {
+		objects = append(objects, storage.Object{Name: name})
+	}
+	for _, name := range s.segments {
+		objects = append(objects, storage.Object{Name: name})
+	}
+
+	return objects, nil
+}
+
+func (s *pointSource) Manifest(name string) (storage.Manifest, error) {
+	m, ok := s.manifests[name]
+	if !ok {
+		return storage.Manifest{}, microerror.Maskf(backupNotFoundError, "no manifest for %s", name)
+	}
+
+	return m, nil
+}
+
+func Test_Service_resolvePoint(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.Local)
+
+	snapshot := func(t time.Time, revision int64) (string, storage.Manifest) {
+		name := "host-backup-etcd-v3-" + t.Format(etcd.TimestampFormat) + ".db.gz"
+		return name, storage.Manifest{Name: name, Created: t, Metadata: map[string]string{etcd.MetadataRevision: fmt.Sprint(revision)}}
+	}
+	segment := func(t time.Time, from, to int64) string {
+		return fmt.Sprintf("host-journal-etcd-v3-%s-%d-%d.journal.jsonl.gz", t.Format(etcd.TimestampFormat), from, to)
+	}
+
+	first, firstManifest := snapshot(base, 100)
+	second, secondManifest := snapshot(base.Add(time.Hour), 200)
+	src := &pointSource{
+		manifests: map[string]storage.Manifest{
+			first:  firstManifest,
+			second: secondManifest,
+		},
+		segments: []string{
+			segment(base.Add(10*time.Minute), 101, 150),
+			segment(base.Add(70*time.Minute), 201, 250),
+			segment(base.Add(80*time.Minute), 251, 300),
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		point        Point
+		snapshot     string
+		segments     int
+		revision     int64
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: latest",
+			point:    Point{},
+			snapshot: second,
+			segments: 2,
+			revision: 300,
+		},
+		{
+			name:     "case 1: time between two segments",
+			point:    Point{At: base.Add(75 * time.Minute)},
+			snapshot: second,
+			segments: 1,
+			revision: 250,
+		},
+		{
+			name:     "case 2: revision in the journal of the older snapshot",
+			point:    Point{Revision: 120},
+			snapshot: first,
+			segments: 1,
+			revision: 120,
+		},
+		{
+			name:     "case 3: revision of a snapshot",
+			point:    Point{Revision: 200},
+			snapshot: second,
+			segments: 0,
+			revision: 200,
+		},
+		{
+			name:         "case 4: time before the oldest backup",
+			point:        Point{At: base.Add(-time.Minute)},
+			errorMatcher: IsOutsideWindow,
+		},
+		{
+			name:         "case 5: revision before the oldest backup",
+			point:        Point{Revision: 99},
+			errorMatcher: IsOutsideWindow,
+		},
+		{
+			name:         "case 6: revision in the gap after a journal",
+			point:        Point{Revision: 160},
+			errorMatcher: IsOutsideWindow,
+		},
+		{
+			name:         "case 7: revision past the journal end",
+			point:        Point{Revision: 400},
+			errorMatcher: IsOutsideWindow,
+		},
+		{
+			name:         "case 8: time after the last segment",
+			point:        Point{At: base.Add(2 * time.Hour)},
+			errorMatcher: IsOutsideWindow,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Service{Logger: testLogger(t)}
+
+			b, err := s.resolvePoint(src, "host", tc.point)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if b.Snapshot != tc.snapshot {
+				t.Fatalf("expected snapshot %s, got %s", tc.snapshot, b.Snapshot)
+			}
+			if len(b.Segments) != tc.segments {
+				t.Fatalf("expected %d segments, got %#v", tc.segments, b.Segments)
+			}
+			if b.Revision != tc.revision {
+				t.Fatalf("expected revision %d, got %d", tc.revision, b.Revision)
+			}
+		})
+	}
+}
+
+// emptySource has no backups.
+type emptySource struct {
+	storage.Source
+}
+
+func (s emptySource) List(prefix string) ([]storage.Object, error) {
+	return nil, nil
+}
+
+func Test_Service_resolvePoint_NoSnapshot(t *testing.T) {
+	s := &Service{Logger: testLogger(t)}
+
+	_, err := s.resolvePoint(emptySource{}, "host", Point{})
+	if !IsBackupNotFound(err) {
+		t.Fatalf("error == %#v, want backup not found", err)
+	}
+}
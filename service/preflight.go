@@ -0,0 +1,84 @@
+package service
+
+import (
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/metrics"
+	"github.com/giantswarm/etcd-backup/report"
+)
+
+// transientChecks fail during short disruptions, e.g. a leader election.
+// They are retried like backups, the other checks fail right away.
+var transientChecks = map[string]bool{
+	etcd.CheckHealth: true,
+	etcd.CheckLeader: true,
+}
+
+// preflight runs the pre-flight checks against the cluster of b and returns
+// them. It fails with preflightFailedError when the backup has to be
+// skipped, which is logged, reported and counted here. Metrics are labelled
+// with clusterID.
+func (s *Service) preflight(b *etcd.EtcdBackupV3, clusterID string) ([]etcd.PreflightCheck, error) {
+	if s.SkipPreflight {
+		return nil, nil
+	}
+
+	var checks []etcd.PreflightCheck
+	var failed etcd.PreflightCheck
+	o := func() error {
+		checks = etcd.Preflight(b.Connection(), s.EtcdV3Quota, s.Logger)
+
+		var ok bool
+		failed, ok = etcd.FailedCheck(checks)
+		if !ok {
+			return nil
+		}
+
+		err := microerror.Maskf(preflightFailedError, "%s: %s", failed.Name, failed.Message)
+		if !transientChecks[failed.Name] {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+
+	err := s.retry(o, "pre-flight checks of "+b.Prefix)
+	if err == nil {
+		return checks, nil
+	}
+
+	message := failed.Name + ": " + failed.Message
+	s.Logger.Log("level", "warning", "msg", "Pre-flight check failed for cluster "+b.Prefix+". Skipping.", "reason", message)
+	s.Report.Add(report.Entry{ClusterID: clusterID, Status: report.StatusSkipped, Reason: report.ReasonPreflightFailed, Message: message, Preflight: preflightReport(checks)})
+	metrics.Send(s.PrometheusConfig, metrics.NewSkippedPreflightMetrics(preflightMetrics(checks)), clusterID)
+
+	return checks, microerror.Mask(err)
+}
+
+// preflightMetrics converts pre-flight checks into metrics.
+func preflightMetrics(checks []etcd.PreflightCheck) []metrics.PreflightMetrics {
+	var m []metrics.PreflightMetrics
+	for _, c := range checks {
+		m = append(m, metrics.PreflightMetrics{
+			Check:  c.Name,
+			Passed: c.Passed,
+		})
+	}
+
+	return m
+}
+
+// preflightReport converts pre-flight checks into report entries.
+func preflightReport(checks []etcd.PreflightCheck) []report.Check {
+	var r []report.Check
+	for _, c := range checks {
+		r = append(r, report.Check{
+			Name:    c.Name,
+			Passed:  c.Passed,
+			Message: c.Message,
+		})
+	}
+
+	return r
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/report"
+)
+
+func Test_Service_Preflight(t *testing.T) {
+	interval := retryInterval
+	retryInterval = time.Millisecond
+	defer func() { retryInterval = interval }()
+
+	testCases := []struct {
+		name         string
+		leader       int
+		alarms       string
+		attempts     int
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: all checks pass",
+			leader:   1,
+			alarms:   `{"alarms":[]}`,
+			attempts: 1,
+		},
+		{
+			name:         "case 1: no leader is retried",
+			alarms:       `{"alarms":[]}`,
+			attempts:     retries,
+			errorMatcher: IsPreflightFailed,
+		},
+		{
+			name:         "case 2: NOSPACE alarm fails right away",
+			leader:       1,
+			alarms:       `{"alarms":[{"memberID":1,"alarm":1}]}`,
+			attempts:     1,
+			errorMatcher: IsPreflightFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, cleanup := fakeEtcdctl(t, fmt.Sprintf(`echo "$1" >> "$(dirname "$0")/calls"
+case "$1" in
+endpoint) echo '[{"Endpoint":"e","Status":{"header":{"member_id":1},"leader":%d}}]';;
+alarm) echo '%s';;
+member) echo '{"members":[{"ID":1,"name":"m1"}]}';;
+esac`, tc.leader, tc.alarms))
+			defer cleanup()
+
+			s := &Service{
+				Logger:           testLogger(t),
+				PrometheusConfig: &config.PrometheusConfig{},
+				Report:           report.New(),
+			}
+			b := &etcd.EtcdBackupV3{Prefix: "host", Endpoints: "https://etcd:2379"}
+
+			checks, err := s.preflight(b, "")
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if len(checks) != 5 {
+				t.Fatalf("expected the checks of the last attempt, got %#v", checks)
+			}
+
+			data, readErr := ioutil.ReadFile(filepath.Join(dir, "calls"))
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			attempts := strings.Count(string(data), "endpoint")
+			if attempts != tc.attempts {
+				t.Fatalf("expected %d attempts, got %d", tc.attempts, attempts)
+			}
+
+			skipped := len(s.Report.Entries) == 1 && s.Report.Entries[0].Status == report.StatusSkipped
+			if skipped != (tc.errorMatcher != nil) {
+				t.Fatalf("expected skipped backups to be reported, got %#v", s.Report.Entries)
+			}
+			if tc.errorMatcher != nil && FailureReason(err) != ReasonPreflight {
+				t.Fatalf("expected reason %q, got %q", ReasonPreflight, FailureReason(err))
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/discovery"
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/metrics"
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+// Reasons a backup failed for. They label the failure metrics and report
+// entries, and select the exit code.
+const (
+	ReasonConfig              = "config"
+	ReasonDiscovery           = "discovery"
+	ReasonCertificate         = "certificate"
+	ReasonSnapshot            = "snapshot"
+	ReasonEncryption          = "encryption"
+	ReasonUpload              = "upload"
+	ReasonPreflight           = "preflight"
+	ReasonPartialGuestFailure = "partial-guest-failure"
+	ReasonUnknown             = "unknown"
+)
+
+// FailureReason returns the reason err failed a backup for, ReasonUnknown
+// for errors outside of the taxonomy and an empty string for nil. Snapshot
+// covers the stages creating and reading the backup and failed journals,
+// which stop recording changes. Encryption and upload cover the stages of
+// the same name.
+func FailureReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case IsPartialGuestFailure(err):
+		return ReasonPartialGuestFailure
+	case IsInvalidConfig(err), config.IsInvalidConfig(err), discovery.IsInvalidConfig(err), storage.IsInvalidConfig(err), IsInvalidProvider(err), IsInvalidKubeconfig(err):
+		return ReasonConfig
+	case IsDiscoveryFailed(err), discovery.IsInvalidSourceFile(err):
+		return ReasonDiscovery
+	case IsCertificateFailed(err), IsInvalidCertSecret(err):
+		return ReasonCertificate
+	case etcd.IsCreateFailed(err), etcd.IsReadFailed(err), etcd.IsCompressFailed(err), IsFailedBackupError(err):
+		return ReasonSnapshot
+	case etcd.IsEncryptFailed(err), etcd.IsMissingPassphrase(err):
+		return ReasonEncryption
+	case etcd.IsUploadFailed(err), storage.IsUploadFailed(err):
+		return ReasonUpload
+	case IsPreflightFailed(err):
+		return ReasonPreflight
+	}
+
+	return ReasonUnknown
+}
+
+// discoveryError returns err as discovery failure unless it has a reason
+// already, e.g. an invalid provider.
+func discoveryError(err error) error {
+	if FailureReason(err) != ReasonUnknown {
+		return microerror.Mask(err)
+	}
+
+	return microerror.Maskf(discoveryFailedError, "%s", err)
+}
+
+// failureMetrics returns the metrics of a backup that failed with err.
+func failureMetrics(err error) *metrics.BackupMetrics {
+	m := metrics.NewStageFailureMetrics(etcd.FailedStage(err))
+	m.FailureReason = FailureReason(err)
+
+	return m
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/discovery"
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/report"
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+// stageFailure returns the error FullBackup returns when create fails.
+type stageFailure struct {
+	err error
+}
+
+func (b stageFailure) Create() error {
+	return b.err
+}
+
+func (b stageFailure) Upload() (storage.Result, []etcd.StageStats, error) {
+	return storage.Result{}, nil, nil
+}
+
+func (b stageFailure) Version() string {
+	return "v3"
+}
+
+func Test_FailureReason(t *testing.T) {
+	unknown := errors.New("unknown")
+	createErr, _ := etcd.FullBackup(stageFailure{err: unknown})
+	_, rateLimitErr := config.ParseRateLimit("fast")
+	_, certKeysErr := discovery.ParseCertKeys("ca")
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "case 0: no error",
+			expected: "",
+		},
+		{
+			name:     "case 1: unknown error",
+			err:      unknown,
+			expected: ReasonUnknown,
+		},
+		{
+			name:     "case 2: invalid service config",
+			err:      microerror.Maskf(invalidConfigError, "passphrase"),
+			expected: ReasonConfig,
+		},
+		{
+			name:     "case 3: invalid rate limit",
+			err:      rateLimitErr,
+			expected: ReasonConfig,
+		},
+		{
+			name:     "case 4: invalid cert keys",
+			err:      certKeysErr,
+			expected: ReasonConfig,
+		},
+		{
+			name:     "case 5: invalid provider",
+			err:      microerror.Mask(invalidProviderError),
+			expected: ReasonConfig,
+		},
+		{
+			name:     "case 6: discovery failed",
+			err:      discoveryError(unknown),
+			expected: ReasonDiscovery,
+		},
+		{
+			name:     "case 7: discovery keeps the reason of config errors",
+			err:      discoveryError(rateLimitErr),
+			expected: ReasonConfig,
+		},
+		{
+			name:     "case 8: certificate failed",
+			err:      microerror.Mask(certificateFailedError),
+			expected: ReasonCertificate,
+		},
+		{
+			name:     "case 9: snapshot failed",
+			err:      createErr,
+			expected: ReasonSnapshot,
+		},
+		{
+			name:     "case 10: journal failed",
+			err:      microerror.Maskf(failedBackupError, "1 of 2 journals failed"),
+			expected: ReasonSnapshot,
+		},
+		{
+			name:     "case 11: partial guest failure",
+			err:      microerror.Maskf(partialGuestFailureError, "1 of 2 guest clusters failed"),
+			expected: ReasonPartialGuestFailure,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason := FailureReason(tc.err)
+			if reason != tc.expected {
+				t.Fatalf("expected reason %q, got %q", tc.expected, reason)
+			}
+		})
+	}
+}
+
+// pushGateway keeps the bodies of all pushed metrics.
+type pushGateway struct {
+	mutex  sync.Mutex
+	pushes []string
+}
+
+func (g *pushGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	g.mutex.Lock()
+	g.pushes = append(g.pushes, string(body))
+	g.mutex.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func Test_Service_BackupInventory_FailureReasons(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inventory := filepath.Join(dir, "inventory.yaml")
+	err = ioutil.WriteFile(inventory, []byte(`targets:
+- name: vault
+  endpoints:
+  - https://10.0.1.10:2379
+  encryption:
+    passphraseFile: /nonexistent/passphrase
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		inventory    string
+		reason       string
+		targetReason string
+	}{
+		{
+			name:      "case 0: missing inventory aborts the run as discovery failure",
+			inventory: filepath.Join(dir, "missing.yaml"),
+			reason:    ReasonDiscovery,
+		},
+		{
+			name:         "case 1: unreadable target passphrase is a config failure of the target",
+			inventory:    inventory,
+			reason:       ReasonPartialGuestFailure,
+			targetReason: ReasonConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &pushGateway{}
+			server := httptest.NewServer(g)
+			defer server.Close()
+
+			s := &Service{
+				Logger:            testLogger(t),
+				CertSecretKeys:    config.DefaultCertKeys,
+				Destinations:      []string{"file://" + dir},
+				DestinationPolicy: storage.PolicyAll,
+				Inventory:         tc.inventory,
+				PrometheusConfig:  &config.PrometheusConfig{Job: "etcd-backup", Url: server.URL},
+				Report:            report.New(),
+			}
+
+			err := s.BackupInventory()
+			if FailureReason(err) != tc.reason {
+				t.Fatalf("expected reason %q, got %q for %#v", tc.reason, FailureReason(err), err)
+			}
+
+			// the failure of the run or the target is pushed
+			reason := tc.reason
+			if tc.targetReason != "" {
+				reason = tc.targetReason
+				if len(s.Report.Entries) != 1 || s.Report.Entries[0].Reason != reason {
+					t.Fatalf("expected a report entry with reason %q, got %#v", reason, s.Report.Entries)
+				}
+			}
+			if len(g.pushes) != 1 || !strings.Contains(g.pushes[0], reason) {
+				t.Fatalf("expected failure metrics with reason %q, got %q", reason, g.pushes)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+// BackupStatus is a stored backup and, when verified, the outcome of the
+// verification.
+type BackupStatus struct {
+	storage.Object
+
+	// SHA256 is the checksum recorded in the manifest, empty when the
+	// backup was not verified.
+	SHA256   string
+	Verified bool
+	Err      error
+}
+
+// ListBackups returns the backups stored in the first destination whose names
+// start with the configured prefix. With verify every backup is downloaded
+// and checked against its manifest.
+func (s *Service) ListBackups(verify bool) ([]BackupStatus, error) {
+	src, err := s.source()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	objects, err := src.List(s.Prefix)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var backups []BackupStatus
+	for _, o := range objects {
+		b := BackupStatus{
+			Object: o,
+		}
+
+		if verify {
+			m, err := storage.Verify(src, o.Name)
+			b.SHA256 = m.SHA256
+			if err != nil {
+				b.Err = err
+				s.Logger.Log("level", "error", "msg", "Failed to verify backup "+o.Name, "reason", err)
+			} else {
+				b.Verified = true
+			}
+		}
+
+		backups = append(backups, b)
+	}
+
+	return backups, nil
+}
+
+// RestoreBackup downloads the backup name from the first destination, checks
+// it against its manifest unless verify is false, and writes the decrypted
+// backup to outputDir. It returns the path of the written file.
+func (s *Service) RestoreBackup(name string, outputDir string, verify bool) (string, error) {
+	tmpDir, err := CreateTMPDir()
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	defer ClearTMPDir(tmpDir)
+
+	err = os.MkdirAll(outputDir, 0700)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	restored, err := s.fetch(name, tmpDir, outputDir, verify)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	s.Logger.Log("level", "info", "msg", "Restored backup "+name+" to "+restored)
+
+	return restored, nil
+}
+
+// fetch downloads the backup name from the first destination to tmpDir,
+// checks it against its manifest unless verify is false, and writes the
+// decrypted backup to dstDir. It returns the path of the written file.
+func (s *Service) fetch(name string, tmpDir string, dstDir string, verify bool) (string, error) {
+	src, err := s.source()
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	_, encryptPass, err := s.secrets()
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	fpath := filepath.Join(tmpDir, filepath.Base(name))
+	if verify {
+		m, err := storage.Fetch(src, name, fpath)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		s.Logger.Log("level", "info", "msg", "Verified backup "+name, "sha256", m.SHA256)
+	} else {
+		s.Logger.Log("level", "warning", "msg", "Skipping verification of backup "+name)
+
+		f, err := os.OpenFile(fpath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		err = src.Download(name, f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+	}
+
+	unpacked, err := etcd.Unpack(fpath, dstDir, encryptPass)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return unpacked, nil
+}
+
+// source returns the first configured destination, or the S3 bucket given
+// by -aws-s3-bucket without destinations.
+func (s *Service) source() (storage.Source, error) {
+	awsConfig, _, err := s.secrets()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if len(s.Destinations) > 0 {
+		return storage.ParseSource(s.Destinations[0], awsConfig, s.Logger)
+	}
+
+	return storage.NewS3(storage.S3Config{Logger: s.Logger, AWS: awsConfig})
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/giantswarm/microerror"
+	"github.com/mholt/archiver"
+
+	"github.com/giantswarm/etcd-backup/etcd"
+)
+
+// tarGzExt is appended to the data directory of a member written as tarball.
+const tarGzExt = ".tar.gz"
+
+// ClusterRestore describes the data directories of a cluster restored from
+// a v3 snapshot. The members must be started with the initial cluster and
+// token below, i.e. as etcd flags --initial-cluster and
+// --initial-cluster-token.
+type ClusterRestore struct {
+	Snapshot       string
+	InitialCluster string
+	Token          string
+	Members        []MemberRestore
+}
+
+// MemberRestore is the data directory of one member, or the tarball holding
+// it.
+type MemberRestore struct {
+	etcd.ClusterMember
+	Path string
+}
+
+// RestoreCluster downloads the v3 snapshot name like RestoreBackup and
+// restores a data directory for every member to outputDir, named after the
+// member. With tarball every data directory is written as tar.gz instead.
+// All members share a newly generated cluster token. Nothing is written when
+// the output of any member exists, and the members restored are removed
+// again when one fails.
+func (s *Service) RestoreCluster(name string, members []etcd.ClusterMember, outputDir string, tarball bool, verify bool) (ClusterRestore, error) {
+	if _, ok := etcd.SnapshotPrefix(name); !ok {
+		return ClusterRestore{}, microerror.Maskf(unsupportedBackupError, "%s is no v3 snapshot", name)
+	}
+
+	token, err := etcd.NewClusterToken()
+	if err != nil {
+		return ClusterRestore{}, microerror.Mask(err)
+	}
+	r := ClusterRestore{
+		Snapshot:       name,
+		InitialCluster: etcd.InitialCluster(members),
+		Token:          token,
+	}
+	for _, m := range members {
+		path := memberPath(outputDir, m, tarball)
+		_, err := os.Stat(path)
+		if err == nil {
+			return ClusterRestore{}, microerror.Maskf(outputExistsError, "%s of member %s", path, m.Name)
+		} else if !os.IsNotExist(err) {
+			return ClusterRestore{}, microerror.Mask(err)
+		}
+
+		r.Members = append(r.Members, MemberRestore{ClusterMember: m, Path: path})
+	}
+
+	tmpDir, err := CreateTMPDir()
+	if err != nil {
+		return ClusterRestore{}, microerror.Mask(err)
+	}
+	defer ClearTMPDir(tmpDir)
+
+	err = os.MkdirAll(outputDir, 0700)
+	if err != nil {
+		return ClusterRestore{}, microerror.Mask(err)
+	}
+
+	dstDir := filepath.Join(tmpDir, "unpacked")
+	err = os.Mkdir(dstDir, 0700)
+	if err != nil {
+		return ClusterRestore{}, microerror.Mask(err)
+	}
+	snapshot, err := s.fetch(name, tmpDir, dstDir, verify)
+	if err != nil {
+		return ClusterRestore{}, microerror.Mask(err)
+	}
+
+	// members restored before a failure would be mixed up with a later run
+	var written []string
+	defer func() {
+		for _, path := range written {
+			os.RemoveAll(path)
+		}
+	}()
+	for _, m := range r.Members {
+		written = append(written, m.Path)
+
+		dataDir := m.Path
+		if tarball {
+			dataDir = filepath.Join(tmpDir, m.Name+etcd.DataDirExt)
+		}
+
+		err = etcd.RestoreMember(snapshot, m.ClusterMember, members, token, dataDir, s.Logger)
+		if err != nil {
+			return ClusterRestore{}, microerror.Mask(err)
+		}
+
+		if tarball {
+			err = archiver.TarGz.Make(m.Path, []string{dataDir})
+			if err != nil {
+				return ClusterRestore{}, microerror.Mask(err)
+			}
+		}
+
+		s.Logger.Log("level", "info", "msg", "Restored member "+m.Name+" to "+m.Path, "backup", name)
+	}
+	written = nil
+
+	return r, nil
+}
+
+// memberPath returns where the data directory of m is restored to.
+func memberPath(outputDir string, m etcd.ClusterMember, tarball bool) string {
+	path := filepath.Join(outputDir, m.Name+etcd.DataDirExt)
+	if tarball {
+		path += tarGzExt
+	}
+
+	return path
+}
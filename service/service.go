@@ -7,7 +7,10 @@ import (
 
 	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/discovery"
 	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/report"
+	"github.com/giantswarm/etcd-backup/storage"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 )
@@ -15,46 +18,105 @@ import (
 type Service struct {
 	Logger micrologger.Logger
 
-	AwsAccessKey     string
-	AwsSecretKey     string
-	AwsS3Bucket      string
-	AwsS3Region      string
-	EtcdV2DataDir    string
-	EtcdV3Cert       string
-	EtcdV3CACert     string
-	EtcdV3Key        string
-	EtcdV3Endpoints  string
-	EncryptPass      string
-	Prefix           string
-	Provider         string
-	PrometheusConfig *config.PrometheusConfig
-
-	Help   bool
-	SkipV2 bool
+	AwsAccessKey       config.Secret
+	AwsSecretKey       config.Secret
+	AwsS3Bucket        string
+	AwsS3Region        string
+	AwsRoleARN         string
+	AwsExternalID      string
+	Compression        etcd.Compression
+	EtcdV2DataDir      string
+	EtcdV3Cert         string
+	EtcdV3CACert       string
+	EtcdV3Key          string
+	EtcdV3Endpoints    string
+	EtcdV3Quota        int64
+	EncryptPass        config.Secret
+	Export             bool
+	ExportPrefixes     []string
+	Prefix             string
+	RateLimits         config.RateLimits
+	Provider           string
+	CRDNamespaces      []string
+	Destinations       []string
+	DestinationPolicy  string
+	ReplicationTargets []string
+	ReplicationTimeout time.Duration
+	ReplicationPoll    time.Duration
+	SecretNamespace    string
+	SecretNameTemplate string
+	CertSecretKeys     string
+	GuestClustersFile  string
+	Inventory          string
+	Kubeconfig         string
+	KubeContext        string
+	PrometheusConfig   *config.PrometheusConfig
+	Report             *report.Report
+	VersionPolicyFile  string
+
+	Help          bool
+	SkipPreflight bool
+	SkipV2        bool
+
+	// Journal records the changes between backups in segments uploaded
+	// every JournalSegmentInterval.
+	Journal                bool
+	JournalSegmentInterval time.Duration
+
+	// journals record the changes after the backups of a run until the
+	// next, their files are kept in journalDir.
+	journals   []*etcd.EtcdJournal
+	journalDir string
 }
 
 func CreateService(f config.Flags, logger micrologger.Logger) *Service {
 	s := &Service{
 		Logger: logger,
 
-		AwsAccessKey:    f.AwsAccessKey,
-		AwsSecretKey:    f.AwsSecretKey,
-		AwsS3Bucket:     f.AwsS3Bucket,
-		AwsS3Region:     f.AwsS3Region,
-		EncryptPass:     f.EncryptPass,
-		EtcdV2DataDir:   f.EtcdV2DataDir,
-		EtcdV3CACert:    f.EtcdV3CACert,
-		EtcdV3Cert:      f.EtcdV3Cert,
-		EtcdV3Key:       f.EtcdV3Key,
-		EtcdV3Endpoints: f.EtcdV3Endpoints,
-		Prefix:          f.Prefix,
-		Provider:        f.Provider,
+		AwsAccessKey:       config.Secret{Value: f.AwsAccessKey, File: f.AwsAccessKeyFile},
+		AwsSecretKey:       config.Secret{Value: f.AwsSecretKey, File: f.AwsSecretKeyFile},
+		AwsS3Bucket:        f.AwsS3Bucket,
+		AwsS3Region:        f.AwsS3Region,
+		AwsRoleARN:         f.AwsRoleARN,
+		AwsExternalID:      f.AwsExternalID,
+		Compression:        etcd.Compression{Algorithm: f.Compression, Level: f.CompressionLevel},
+		EncryptPass:        config.Secret{Value: f.EncryptPass, File: f.EncryptPassFile},
+		Export:             f.Export,
+		ExportPrefixes:     config.SplitList(f.ExportPrefixes),
+		EtcdV2DataDir:      f.EtcdV2DataDir,
+		EtcdV3CACert:       f.EtcdV3CACert,
+		EtcdV3Cert:         f.EtcdV3Cert,
+		EtcdV3Key:          f.EtcdV3Key,
+		EtcdV3Endpoints:    f.EtcdV3Endpoints,
+		EtcdV3Quota:        f.EtcdV3Quota,
+		Prefix:             f.Prefix,
+		RateLimits:         config.RateLimits{Upload: f.UploadRateLimit, SnapshotDiskRead: f.SnapshotDiskRateLimit},
+		Provider:           f.Provider,
+		CRDNamespaces:      config.SplitList(f.CRDNamespaces),
+		Destinations:       config.SplitList(f.Destinations),
+		DestinationPolicy:  f.DestinationPolicy,
+		ReplicationTargets: config.SplitList(f.ReplicationTargets),
+		ReplicationTimeout: f.ReplicationTimeout,
+		ReplicationPoll:    f.ReplicationPoll,
+		SecretNamespace:    f.SecretNamespace,
+		SecretNameTemplate: f.SecretNameTemplate,
+		CertSecretKeys:     f.CertSecretKeys,
+		GuestClustersFile:  f.GuestClustersFile,
+		Inventory:          f.Inventory,
+		Kubeconfig:         f.Kubeconfig,
+		KubeContext:        f.KubeContext,
 		PrometheusConfig: &config.PrometheusConfig{
 			Job: f.PushGatewayJob,
 			Url: f.PushGatewayURL,
 		},
+		Report:            report.New(),
+		VersionPolicyFile: f.VersionPolicyFile,
 
-		SkipV2: f.SkipV2,
+		SkipPreflight: f.SkipPreflight,
+		SkipV2:        f.SkipV2,
+
+		Journal:                f.Journal,
+		JournalSegmentInterval: f.JournalSegmentInterval,
 	}
 	return s
 }
@@ -69,29 +131,50 @@ func (s *Service) BackupHostCluster() error {
 	}
 	defer ClearTMPDir(tmpDir)
 
+	awsConfig, encryptPass, err := s.secrets()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	uploader, err := s.uploader(awsConfig)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	uploadLimit, readLimit, err := s.rateLimits(config.RateLimits{})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	// V2 etcd.
 	if !s.SkipV2 {
 		v2 := etcd.EtcdBackupV2{
 			Logger: s.Logger,
 
-			Aws: config.AWSConfig{
-				AccessKey: s.AwsAccessKey,
-				SecretKey: s.AwsSecretKey,
-				Bucket:    s.AwsS3Bucket,
-				Region:    s.AwsS3Region,
-			},
-			Datadir: s.EtcdV2DataDir,
-			EncPass: s.EncryptPass,
-			Prefix:  s.Prefix,
-			TmpDir:  tmpDir,
+			Uploader: uploader,
+			Datadir:  s.EtcdV2DataDir,
+			EncPass:  encryptPass,
+			Prefix:   s.Prefix,
+			TmpDir:   tmpDir,
+
+			Compression:               s.Compression,
+			SnapshotDiskReadRateLimit: readLimit,
+			UploadRateLimit:           uploadLimit,
 		}
 		// run backup task
-		err, backupMetrics := etcd.FullBackup(&v2)
+		o := func() error {
+			err, backupMetrics := etcd.FullBackup(&v2)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			metrics.Send(s.PrometheusConfig, backupMetrics, "")
+
+			return nil
+		}
+
+		err = s.retry(o, "etcd v2 backup of "+v2.Prefix)
 		if err != nil {
-			metrics.Send(s.PrometheusConfig, metrics.NewFailureMetrics(), "")
+			metrics.Send(s.PrometheusConfig, failureMetrics(err), "")
+			s.Report.Add(report.Entry{Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()})
 			return microerror.Mask(err)
-		} else {
-			metrics.Send(s.PrometheusConfig, backupMetrics, "")
 		}
 	}
 
@@ -99,54 +182,69 @@ func (s *Service) BackupHostCluster() error {
 	v3 := etcd.EtcdBackupV3{
 		Logger: s.Logger,
 
-		Aws: config.AWSConfig{
-			AccessKey: s.AwsAccessKey,
-			SecretKey: s.AwsSecretKey,
-			Bucket:    s.AwsS3Bucket,
-			Region:    s.AwsS3Region,
-		},
+		Uploader:  uploader,
 		CACert:    s.EtcdV3CACert,
 		Cert:      s.EtcdV3Cert,
 		Prefix:    s.Prefix,
-		EncPass:   s.EncryptPass,
+		EncPass:   encryptPass,
 		Endpoints: s.EtcdV3Endpoints,
 		Key:       s.EtcdV3Key,
 		TmpDir:    tmpDir,
+
+		Compression:               s.Compression,
+		SnapshotDiskReadRateLimit: readLimit,
+		UploadRateLimit:           uploadLimit,
+	}
+
+	checks, err := s.preflight(&v3, "")
+	if err != nil {
+		return microerror.Mask(err)
 	}
 
 	// run backup task
+	var backupMetrics *metrics.BackupMetrics
 	o := func() error {
 
-		err, backupMetrics := etcd.FullBackup(&v3)
+		err, m := etcd.FullBackup(&v3)
 		if err != nil {
 			return microerror.Mask(err)
 		}
+		backupMetrics = m
 
 		s.Logger.Log("level", "info", "msg", "Cluster backup created for: "+v3.Prefix)
 
-		sent, err := metrics.Send(s.PrometheusConfig, backupMetrics, "")
-
-		if sent {
-			if err != nil {
-				s.Logger.Log("level", "info", "msg", fmt.Sprintf("Error sending metrics to push gateway for: %s (%s)", v3.Prefix, err))
-			} else {
-				s.Logger.Log("level", "info", "msg", "Successfully sent metrics to push gateway for: "+v3.Prefix)
-			}
-		} else {
-			s.Logger.Log("level", "info", "msg", "Did NOT send metrics to push gateway for: "+v3.Prefix)
-		}
-
 		return nil
 	}
 
-	b := backoff.NewMaxRetries(retries, 20*time.Second)
-
-	err = backoff.Retry(o, b)
+	err = s.retry(o, "etcd v3 backup of "+v3.Prefix)
 	if err != nil {
-		metrics.Send(s.PrometheusConfig, metrics.NewFailureMetrics(), "")
+		m := failureMetrics(err)
+		m.Preflight = preflightMetrics(checks)
+		metrics.Send(s.PrometheusConfig, m, "")
+		s.Report.Add(report.Entry{Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error(), Preflight: preflightReport(checks)})
 		return microerror.Mask(err)
 	}
 
+	backupMetrics.Preflight = preflightMetrics(checks)
+	entry := report.Entry{Status: report.StatusSucceeded, Preflight: preflightReport(checks), Replication: replicationReport(backupMetrics)}
+	s.exportBackup(&v3, backupMetrics, &entry)
+
+	sent, err := metrics.Send(s.PrometheusConfig, backupMetrics, "")
+
+	if sent {
+		if err != nil {
+			s.Logger.Log("level", "info", "msg", fmt.Sprintf("Error sending metrics to push gateway for: %s (%s)", v3.Prefix, err))
+		} else {
+			s.Logger.Log("level", "info", "msg", "Successfully sent metrics to push gateway for: "+v3.Prefix)
+		}
+	} else {
+		s.Logger.Log("level", "info", "msg", "Did NOT send metrics to push gateway for: "+v3.Prefix)
+	}
+
+	s.addJournal(&v3)
+
+	s.Report.Add(entry)
+
 	return nil
 }
 
@@ -157,115 +255,420 @@ func (s *Service) BackupGuestClusters() error {
 		return microerror.Mask(err)
 	}
 	defer ClearTMPDir(tmpDir)
-	// create host cluster k8s client
-	k8sClient, err := CreateK8sClient(s.Logger)
+
+	awsConfig, encryptPass, err := s.secrets()
 	if err != nil {
 		return microerror.Mask(err)
 	}
-	// create k8s crd client
-	crdClient, err := CreateCRDClient(s.Logger)
+	uploader, err := s.uploader(awsConfig)
 	if err != nil {
 		return microerror.Mask(err)
 	}
-	// fetch all guest cluster ids
-	clusterList, err := GetAllGuestClusters(s.Provider, crdClient)
+
+	// create host cluster k8s client
+	k8sClient, err := CreateK8sClient(s.Logger, s.Kubeconfig, s.KubeContext)
+	if err != nil {
+		return s.guestDiscoveryFailed(err)
+	}
+	certSecret, err := s.certSecret()
 	if err != nil {
-		return microerror.Mask(err)
+		return s.guestDiscoveryFailed(err)
+	}
+	versionPolicy, err := s.versionPolicy()
+	if err != nil {
+		return s.guestDiscoveryFailed(err)
+	}
+	// create guest cluster source
+	source, err := s.clusterSource(certSecret)
+	if err != nil {
+		return s.guestDiscoveryFailed(err)
 	}
-	s.Logger.Log("level", "info", "msg", fmt.Sprintf("Guest cluster list: %#v", clusterList))
+	// fetch all guest clusters
+	clusterList, err := source.Clusters()
+	if err != nil {
+		return s.guestDiscoveryFailed(err)
+	}
+	s.Logger.Log("level", "info", "msg", fmt.Sprintf("Guest cluster list: %#v", clusterIDs(clusterList)))
 
-	// backup failed flag, we want to know if any of the backup failed,
+	// count of failed backups, we want to know if any of the backup failed,
 	// but one failed guest cluster should not cancel backup of the rest
-	failed := false
+	failed := 0
 
 	// iterate over all clusters
-	for _, clusterID := range clusterList {
+	for _, cluster := range clusterList {
+		clusterID := cluster.ID
+
 		// check if the cluster release version has support for etcd backup
-		versionSupported, err := CheckClusterVersionSupport(clusterID, s.Provider, crdClient)
+		versionSupported, reason, err := versionPolicy.Supported(s.Provider, cluster)
 		if err != nil {
-			failed = true
+			failed++
 			s.Logger.Log("level", "error", "msg", "Failed to check release version for cluster "+clusterID, "reason", err)
+			s.Report.Add(report.Entry{ClusterID: clusterID, Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()})
+			metrics.Send(s.PrometheusConfig, failureMetrics(err), clusterID)
 			continue
 		}
 		if !versionSupported {
-			s.Logger.Log("level", "warning", "msg", "Cluster "+clusterID+" is too old for etcd backup. Skipping.")
+			s.Logger.Log("level", "warning", "msg", "Cluster "+clusterID+" is not supported for etcd backup. Skipping.", "reason", reason)
+			s.Report.Add(report.Entry{ClusterID: clusterID, Status: report.StatusSkipped, Reason: report.ReasonUnsupportedVersion, Message: reason})
+			metrics.Send(s.PrometheusConfig, metrics.NewSkippedUnsupportedVersionMetrics(), clusterID)
 			continue
 		}
 
 		// fetch etcd certs
-		certs, err := FetchCerts(clusterID, k8sClient)
+		certs, err := FetchCerts(cluster.CertSecretRef, certSecret.Keys, k8sClient)
 		if err != nil {
-			failed = true
+			failed++
 			s.Logger.Log("level", "error", "msg", "Failed to fetch etcd certs for cluster "+clusterID, "reason", err)
+			s.Report.Add(report.Entry{ClusterID: clusterID, Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()})
+			metrics.Send(s.PrometheusConfig, failureMetrics(err), clusterID)
 			continue
 		}
 		// write etcd certs to tmpdir
 		err = CreateCertFiles(clusterID, certs, tmpDir)
 		if err != nil {
-			failed = true
+			failed++
 			s.Logger.Log("level", "error", "msg", "Failed to write etcd certs to tmpdir for cluster "+clusterID, "reason", err)
+			s.Report.Add(report.Entry{ClusterID: clusterID, Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()})
+			metrics.Send(s.PrometheusConfig, failureMetrics(err), clusterID)
 			continue
 		}
 
-		// fetch etcd endpoint
-		etcdEndpoint, err := GetEtcdEndpoint(clusterID, s.Provider, crdClient)
+		uploadLimit, readLimit, err := s.rateLimits(cluster.RateLimits())
 		if err != nil {
-			failed = true
-			s.Logger.Log("level", "error", "msg", "Failed to fetch etcd endpoint for cluster "+clusterID, "reason", err)
+			failed++
+			s.Logger.Log("level", "error", "msg", "Invalid rate limit labels for cluster "+clusterID, "reason", err)
+			s.Report.Add(report.Entry{ClusterID: clusterID, Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()})
+			metrics.Send(s.PrometheusConfig, failureMetrics(err), clusterID)
 			continue
 		}
+
 		// backup config, we only care about etcd3 in guest cluster
 		backupConfig := etcd.EtcdBackupV3{
 			Logger: s.Logger,
 
-			Aws: config.AWSConfig{
-				AccessKey: s.AwsAccessKey,
-				SecretKey: s.AwsSecretKey,
-				Bucket:    s.AwsS3Bucket,
-				Region:    s.AwsS3Region,
-			},
-			CACert: certs.CAFile,
-			Cert:   certs.CrtFile,
-			Key:    certs.KeyFile,
+			Uploader: uploader,
+			CACert:   certs.CAFile,
+			Cert:     certs.CrtFile,
+			Key:      certs.KeyFile,
 
 			Prefix:    s.Prefix + BackupPrefix(clusterID),
-			EncPass:   s.EncryptPass,
-			Endpoints: etcdEndpoint,
+			EncPass:   encryptPass,
+			Endpoints: cluster.Endpoint,
 
-			TmpDir: tmpDir,
+			Compression:               s.Compression,
+			SnapshotDiskReadRateLimit: readLimit,
+			UploadRateLimit:           uploadLimit,
+			TmpDir:                    tmpDir,
 		}
 
-		o := func() error {
+		err = s.backupWithRetry(&backupConfig, clusterID)
+		if err != nil {
+			failed++
+			s.Logger.Log("level", "error", "msg", "Failed to backup etcd cluster "+clusterID, "reason", err)
+		}
+	}
 
-			err, backupMetrics := etcd.FullBackup(&backupConfig)
+	// check if any backup failed
+	if failed > 0 {
+		s.Logger.Log("level", "error", "msg", "Failed to backup all clusters", "failed", failed)
+		return microerror.Maskf(partialGuestFailureError, "%d of %d guest clusters failed", failed, len(clusterList))
+	} else {
+		s.Logger.Log("level", "info", "msg", fmt.Sprintf("Finished guest cluster backup. Total guest clusters: %d", len(clusterList)))
+	}
+
+	return nil
+}
+
+// guestDiscoveryFailed reports that no guest cluster or inventory target
+// could be backed up because discovery failed. The failure is counted without cluster ID, the
+// reason tells it from host cluster failures.
+func (s *Service) guestDiscoveryFailed(err error) error {
+	err = discoveryError(err)
+	metrics.Send(s.PrometheusConfig, failureMetrics(err), "")
+
+	return microerror.Mask(err)
+}
+
+// secrets returns the S3 settings and encryption passphrase. Secret files are
+// read again on every call, so rotated secrets are used by the next backup.
+func (s *Service) secrets() (config.AWSConfig, string, error) {
+	accessKey, err := s.AwsAccessKey.Get()
+	if err != nil {
+		return config.AWSConfig{}, "", microerror.Maskf(invalidConfigError, "failed to read AWS access key: %s", err)
+	}
+	secretKey, err := s.AwsSecretKey.Get()
+	if err != nil {
+		return config.AWSConfig{}, "", microerror.Maskf(invalidConfigError, "failed to read AWS secret key: %s", err)
+	}
+	encryptPass, err := s.EncryptPass.Get()
+	if err != nil {
+		return config.AWSConfig{}, "", microerror.Maskf(invalidConfigError, "failed to read passphrase: %s", err)
+	}
+
+	awsConfig := config.AWSConfig{
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		Bucket:     s.AwsS3Bucket,
+		Region:     s.AwsS3Region,
+		RoleARN:    s.AwsRoleARN,
+		ExternalID: s.AwsExternalID,
+	}
+
+	return awsConfig, encryptPass, nil
+}
+
+// uploader returns the fan-out to all configured destinations. Without
+// destinations the S3 bucket given by -aws-s3-bucket is used.
+func (s *Service) uploader(awsConfig config.AWSConfig) (storage.Uploader, error) {
+	var destinations []storage.Destination
+	for _, spec := range s.Destinations {
+		d, err := storage.ParseDestination(spec, awsConfig, s.Logger)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		destinations = append(destinations, d)
+	}
+
+	if len(destinations) == 0 {
+		d, err := storage.NewS3(storage.S3Config{Logger: s.Logger, AWS: awsConfig})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		destinations = append(destinations, d)
+	}
+
+	var replication *storage.ReplicationChecker
+	if len(s.ReplicationTargets) > 0 {
+		var targets []*storage.S3
+		for _, spec := range s.ReplicationTargets {
+			t, err := storage.ParseReplicationTarget(spec, awsConfig, s.Logger)
 			if err != nil {
-				return microerror.Mask(err)
+				return nil, microerror.Mask(err)
 			}
+			targets = append(targets, t)
+		}
 
-			s.Logger.Log("level", "info", "msg", "Cluster backup created for: "+clusterID)
+		c := storage.ReplicationCheckerConfig{
+			Logger: s.Logger,
 
-			metrics.Send(s.PrometheusConfig, backupMetrics, clusterID)
+			Targets:      targets,
+			Timeout:      s.ReplicationTimeout,
+			PollInterval: s.ReplicationPoll,
+		}
 
-			return nil
+		var err error
+		replication, err = storage.NewReplicationChecker(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
 		}
+	}
+
+	c := storage.FanOutConfig{
+		Logger: s.Logger,
+
+		Destinations: destinations,
+		Policy:       s.DestinationPolicy,
+		Replication:  replication,
+	}
+
+	return storage.NewFanOut(c)
+}
+
+// rateLimits returns the upload and snapshot read limits in bytes per
+// second. Non-empty limits of override take precedence over the global ones.
+func (s *Service) rateLimits(override config.RateLimits) (int64, int64, error) {
+	limits := s.RateLimits.Override(override)
+
+	upload, err := config.ParseRateLimit(limits.Upload)
+	if err != nil {
+		return 0, 0, microerror.Mask(err)
+	}
+	read, err := config.ParseRateLimit(limits.SnapshotDiskRead)
+	if err != nil {
+		return 0, 0, microerror.Mask(err)
+	}
+
+	return upload, read, nil
+}
 
-		b := backoff.NewMaxRetries(retries, 20*time.Second)
+// replicationReport converts replication metrics into report entries.
+func replicationReport(backupMetrics *metrics.BackupMetrics) []report.Replication {
+	if backupMetrics == nil {
+		return nil
+	}
+
+	var r []report.Replication
+	for _, m := range backupMetrics.Replication {
+		r = append(r, report.Replication{
+			Target: m.Target,
+			Status: m.Status,
+			LagMs:  m.LagMeasurement,
+		})
+	}
+
+	return r
+}
+
+// backupWithRetry runs a v3 backup of a guest or inventory cluster and
+// retries on failure. The backup is skipped when a pre-flight check fails.
+// Metrics are labelled with clusterID.
+func (s *Service) backupWithRetry(backupConfig *etcd.EtcdBackupV3, clusterID string) error {
+	checks, err := s.preflight(backupConfig, clusterID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
 
-		err = backoff.Retry(o, b)
+	var backupMetrics *metrics.BackupMetrics
+	o := func() error {
+
+		err, m := etcd.FullBackup(backupConfig)
 		if err != nil {
-			failed = true
-			s.Logger.Log("level", "error", "msg", "Failed to backup etcd cluster "+clusterID, "reason", err)
-			metrics.Send(s.PrometheusConfig, metrics.NewFailureMetrics(), clusterID)
+			return microerror.Mask(err)
 		}
+		backupMetrics = m
+
+		s.Logger.Log("level", "info", "msg", "Cluster backup created for: "+clusterID)
+
+		return nil
 	}
 
-	// check if any backup failed
-	if failed {
-		s.Logger.Log("level", "error", "msg", "Failed to backup all clusters", err)
-		return failedBackupError
-	} else {
-		s.Logger.Log("level", "info", "msg", fmt.Sprintf("Finished guest cluster backup. Total guest clusters: %d", len(clusterList)))
+	err = s.retry(o, "etcd v3 backup of "+backupConfig.Prefix)
+	if err != nil {
+		m := failureMetrics(err)
+		m.Preflight = preflightMetrics(checks)
+		metrics.Send(s.PrometheusConfig, m, clusterID)
+		s.Report.Add(report.Entry{ClusterID: clusterID, Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error(), Preflight: preflightReport(checks)})
+		return microerror.Mask(err)
 	}
 
+	backupMetrics.Preflight = preflightMetrics(checks)
+	entry := report.Entry{ClusterID: clusterID, Status: report.StatusSucceeded, Preflight: preflightReport(checks), Replication: replicationReport(backupMetrics)}
+	s.exportBackup(backupConfig, backupMetrics, &entry)
+
+	metrics.Send(s.PrometheusConfig, backupMetrics, clusterID)
+
+	s.addJournal(backupConfig)
+
+	s.Report.Add(entry)
+
 	return nil
 }
+
+// exportBackup uploads the export of the snapshot v3 has taken, if enabled,
+// and adds its outcome to the metrics m and report entry e of the backup. A
+// failed export does not fail the backup, the snapshot is valid without it.
+func (s *Service) exportBackup(v3 *etcd.EtcdBackupV3, m *metrics.BackupMetrics, e *report.Entry) {
+	if !s.Export {
+		return
+	}
+
+	err := s.export(v3)
+	if err != nil {
+		s.Logger.Log("level", "error", "msg", "Failed to export etcd v3 backup of "+v3.Prefix, "reason", err)
+		m.Export = &metrics.ExportMetrics{FailureReason: FailureReason(err)}
+		e.Export = &report.Export{Status: report.StatusFailed, Reason: FailureReason(err), Message: err.Error()}
+		return
+	}
+
+	m.Export = &metrics.ExportMetrics{Successful: true}
+	e.Export = &report.Export{Status: report.StatusSucceeded}
+}
+
+// export uploads a logical export of the keys at the revision of the
+// snapshot v3 has taken. It is retried on its own, so the snapshot is not
+// taken again.
+func (s *Service) export(v3 *etcd.EtcdBackupV3) error {
+	o := func() error {
+		e := v3.Export(s.ExportPrefixes)
+
+		err, _ := etcd.FullBackup(e)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		s.Logger.Log("level", "info", "msg", "Cluster export created for: "+v3.Prefix, "revision", e.Revision)
+
+		return nil
+	}
+
+	err := s.retry(o, "etcd v3 export of "+v3.Prefix)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// retryInterval is the time between attempts of a backup.
+var retryInterval = 20 * time.Second
+
+// retry runs the backup o until it succeeds or failed retries more times.
+// Every kind of backup is retried the same way. Failed attempts are logged
+// with the stage they failed at.
+func (s *Service) retry(o func() error, what string) error {
+	b := backoff.NewMaxRetries(retries, retryInterval)
+	n := func(err error, d time.Duration) {
+		s.Logger.Log("level", "warning", "msg", fmt.Sprintf("Retrying %s in %s", what, d), "stage", etcd.FailedStage(err), "reason", err)
+	}
+
+	err := backoff.RetryNotify(o, b, n)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// clusterSource returns the static file source when configured, the provider
+// CR source otherwise.
+func (s *Service) clusterSource(certSecret discovery.CertSecret) (discovery.ClusterSource, error) {
+	if s.GuestClustersFile != "" {
+		return discovery.NewStatic(discovery.StaticConfig{CertSecret: certSecret, Path: s.GuestClustersFile})
+	}
+
+	// create k8s crd client
+	crdClient, err := CreateCRDClient(s.Logger, s.Kubeconfig, s.KubeContext)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return CreateClusterSource(s.Provider, crdClient, s.CRDNamespaces, certSecret)
+}
+
+// versionPolicy returns the policy loaded from file, or the default policy.
+func (s *Service) versionPolicy() (discovery.VersionPolicy, error) {
+	if s.VersionPolicyFile == "" {
+		return discovery.DefaultVersionPolicy(), nil
+	}
+
+	p, err := discovery.LoadVersionPolicy(s.VersionPolicyFile)
+	if err != nil {
+		return discovery.VersionPolicy{}, microerror.Mask(err)
+	}
+
+	return p, nil
+}
+
+// certSecret returns where guest cluster etcd certificates are stored.
+func (s *Service) certSecret() (discovery.CertSecret, error) {
+	keys, err := discovery.ParseCertKeys(s.CertSecretKeys)
+	if err != nil {
+		return discovery.CertSecret{}, microerror.Mask(err)
+	}
+
+	c := discovery.CertSecret{
+		Namespace:    s.SecretNamespace,
+		NameTemplate: s.SecretNameTemplate,
+		Keys:         keys,
+	}
+
+	return c, nil
+}
+
+func clusterIDs(clusters []discovery.GuestCluster) []string {
+	var ids []string
+	for _, c := range clusters {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
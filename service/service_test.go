@@ -0,0 +1,86 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/etcd"
+	"github.com/giantswarm/etcd-backup/report"
+	"github.com/giantswarm/etcd-backup/storage"
+)
+
+func testLogger(t *testing.T) micrologger.Logger {
+	logger, err := micrologger.New(micrologger.Config{IOWriter: ioutil.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return logger
+}
+
+// fakeEtcdctl puts an etcdctl running script first in $PATH. The returned
+// function restores $PATH and removes the script.
+func fakeEtcdctl(t *testing.T, script string) (string, func()) {
+	dir, err := ioutil.TempDir("", "etcdctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "etcdctl"), []byte("#!/bin/sh\n"+script+"\n"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	return dir, func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_Service_BackupHostCluster_RetriesV2(t *testing.T) {
+	// every etcdctl backup fails
+	dir, cleanup := fakeEtcdctl(t, `echo "$1" >> "$(dirname "$0")/calls"
+exit 1`)
+	defer cleanup()
+
+	interval := retryInterval
+	retryInterval = time.Millisecond
+	defer func() { retryInterval = interval }()
+
+	s := &Service{
+		Logger:            testLogger(t),
+		Destinations:      []string{"file://" + dir},
+		DestinationPolicy: storage.PolicyAll,
+		EtcdV2DataDir:     dir,
+		PrometheusConfig:  &config.PrometheusConfig{},
+		Report:            report.New(),
+	}
+
+	err := s.BackupHostCluster()
+	if !etcd.IsCreateFailed(err) {
+		t.Fatalf("expected create failed error, got %#v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Fields(string(data))
+	if len(calls) != retries {
+		t.Fatalf("expected %d attempts of the v2 backup, got %v", retries, calls)
+	}
+	for _, c := range calls {
+		if c != "backup" {
+			t.Fatalf("expected only v2 backups, got %v", calls)
+		}
+	}
+}
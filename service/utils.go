@@ -1,17 +1,16 @@
 package service
 
 import (
-	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 
-	"github.com/coreos/go-semver/semver"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/giantswarm/apiextensions/pkg/clientset/versioned"
+	"github.com/giantswarm/etcd-backup/discovery"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	"github.com/giantswarm/operatorkit/client/k8sclient"
@@ -30,20 +29,55 @@ func CreateTMPDir() (string, error) {
 	return tmpDir, nil
 }
 
-//clear temporary directory
+// clear temporary directory
 func ClearTMPDir(tmpDir string) {
 	os.RemoveAll(tmpDir)
 }
 
+// create rest config to access host k8s cluster, from kubeconfig when set or
+// $KUBECONFIG lists files and in-cluster otherwise
+func CreateRestConfig(logger micrologger.Logger, kubeconfigPath string, kubeContext string) (*rest.Config, error) {
+	c := k8srestconfig.Config{
+		Logger:    logger,
+		InCluster: true,
+	}
+
+	var cluster *kubeconfigCluster
+	if paths := kubeconfigPaths(kubeconfigPath); len(paths) > 0 {
+		var err error
+		cluster, err = loadKubeconfig(paths, kubeContext)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		c.InCluster = false
+		c.Address = cluster.Address
+		c.TLS = cluster.TLS
+	}
+
+	restConfig, err := k8srestconfig.New(c)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if cluster != nil {
+		restConfig.BearerToken = cluster.Token
+		restConfig.Username = cluster.Username
+		restConfig.Password = cluster.Password
+		restConfig.Insecure = cluster.Insecure
+	}
+
+	return restConfig, nil
+}
+
 // create k8s client to access host k8s cluster
-func CreateK8sClient(logger micrologger.Logger) (kubernetes.Interface, error) {
-	guestClientConfig := k8sclient.Config{}
-	{
-		guestClientConfig.InCluster = true
-		guestClientConfig.Logger = logger
+func CreateK8sClient(logger micrologger.Logger, kubeconfigPath string, kubeContext string) (kubernetes.Interface, error) {
+	restConfig, err := CreateRestConfig(logger, kubeconfigPath, kubeContext)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	k8sClient, err := k8sclient.New(guestClientConfig)
+	k8sClient, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		return nil, microerror.Maskf(err, "error creating k8sclient for host cluster")
 	}
@@ -51,199 +85,59 @@ func CreateK8sClient(logger micrologger.Logger) (kubernetes.Interface, error) {
 }
 
 // create CRD client to access k8s crd resources cluster
-func CreateCRDClient(logger micrologger.Logger) (*versioned.Clientset, error) {
-	var err error
-	var restConfig *rest.Config
-	{
-		c := k8srestconfig.Config{
-			Logger:    logger,
-			InCluster: true,
-		}
-
-		restConfig, err = k8srestconfig.New(c)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
-	}
-
-	g8sClient, err := versioned.NewForConfig(restConfig)
+func CreateCRDClient(logger micrologger.Logger, kubeconfigPath string, kubeContext string) (*versioned.Clientset, error) {
+	restConfig, err := CreateRestConfig(logger, kubeconfigPath, kubeContext)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
+	g8sClient, err := versioned.NewForConfig(restConfig)
 	if err != nil {
 		return nil, microerror.Maskf(err, "error creating crd client for host cluster")
 	}
 	return g8sClient, nil
 }
 
-// check if cluster release version has guest cluster backup support
-func CheckClusterVersionSupport(clusterID string, provider string, crdCLient *versioned.Clientset) (bool, error) {
-	getOpts := metav1.GetOptions{}
-
+// create the guest cluster source for the configured provider
+func CreateClusterSource(provider string, crdClient versioned.Interface, namespaces []string, certSecret discovery.CertSecret) (discovery.ClusterSource, error) {
 	switch provider {
 	case aws:
-		{
-			crd, err := crdCLient.ProviderV1alpha1().AWSConfigs(crdNamespace).Get(clusterID, getOpts)
-			if err != nil {
-				return false, microerror.Maskf(err, "failed to get aws crd"+clusterID)
-			}
-			crdVersionStr := crd.Spec.VersionBundle.Version
-			if crdVersionStr == "" {
-				crdVersionStr = "0.0.0"
-			}
-			crdVersion := semver.New(crdVersionStr)
-			if crdVersion.Compare(*awsSupportFrom) >= 0 {
-				// version has support
-				return true, nil
-			} else {
-				// version doesnt have support
-				return false, nil
-			}
-		}
+		return discovery.NewAWS(discovery.AWSConfig{G8sClient: crdClient, CertSecret: certSecret, Namespaces: namespaces})
 	case azure:
-		{
-			crd, err := crdCLient.ProviderV1alpha1().AzureConfigs(crdNamespace).Get(clusterID, getOpts)
-			if err != nil {
-				return false, microerror.Maskf(err, "failed to get azure crd "+clusterID)
-			}
-			crdVersionStr := crd.Spec.VersionBundle.Version
-			if crdVersionStr == "" {
-				crdVersionStr = "0.0.0"
-			}
-			crdVersion := semver.New(crdVersionStr)
-			if crdVersion.Compare(*azureSupportFrom) >= 0 {
-				// version has support
-				return true, nil
-			} else {
-				// version doesnt have support
-				return false, nil
-			}
-		}
+		return discovery.NewAzure(discovery.AzureConfig{G8sClient: crdClient, CertSecret: certSecret, Namespaces: namespaces})
 	case kvm:
-		{
-			// kvm backups are always supported
-			return true, nil
-		}
+		return discovery.NewKVM(discovery.KVMConfig{G8sClient: crdClient, CertSecret: certSecret, Namespaces: namespaces})
 	}
-	return false, nil
-}
 
-// fetch all guest clusters ids in host cluster
-func GetAllGuestClusters(provider string, crdCLient *versioned.Clientset) ([]string, error) {
-	var clusterList []string
-	listOpt := metav1.ListOptions{}
-
-	switch provider {
-	case aws:
-		{
-			crdList, err := crdCLient.ProviderV1alpha1().AWSConfigs(crdNamespace).List(listOpt)
-			if err != nil {
-				return []string{}, microerror.Maskf(err, "failed to list aws crd")
-			}
-			for _, awsConfig := range crdList.Items {
-				// only backup cluster if it was not marked for delete
-				if awsConfig.DeletionTimestamp == nil {
-					clusterList = append(clusterList, awsConfig.Name)
-				}
-			}
-			break
-		}
-	case azure:
-		{
-			crdList, err := crdCLient.ProviderV1alpha1().AzureConfigs(crdNamespace).List(listOpt)
-			if err != nil {
-				return []string{}, microerror.Maskf(err, "failed to list azure crd")
-			}
-			for _, azureConfig := range crdList.Items {
-				// only backup cluster if it was not marked for delete
-				if azureConfig.DeletionTimestamp == nil {
-					clusterList = append(clusterList, azureConfig.Name)
-				}
-			}
-			break
-		}
-	case kvm:
-		{
-			crdList, err := crdCLient.ProviderV1alpha1().KVMConfigs(crdNamespace).List(listOpt)
-			if err != nil {
-				return []string{}, microerror.Maskf(err, "failed to list azure crd")
-			}
-			for _, kvmConfig := range crdList.Items {
-				// only backup cluster if it was not marked for delete
-				if kvmConfig.DeletionTimestamp == nil {
-					clusterList = append(clusterList, kvmConfig.Name)
-				}
-			}
-			break
-		}
-	default:
-		{
-			return []string{}, invalidProviderError
-		}
-	}
-
-	return clusterList, nil
+	return nil, microerror.Maskf(invalidProviderError, "%q", provider)
 }
 
 // fetch etcd client certs
-func FetchCerts(clusterID string, k8sClient kubernetes.Interface) (*k8sclient.TLSClientConfig, error) {
+// keys are used when the secret reference does not set its own
+func FetchCerts(secretRef discovery.SecretRef, keys discovery.CertKeys, k8sClient kubernetes.Interface) (*k8sclient.TLSClientConfig, error) {
+	if secretRef.Keys != nil {
+		keys = *secretRef.Keys
+	}
 
 	getOpts := metav1.GetOptions{}
-	secret, err := k8sClient.CoreV1().Secrets(secretNamespace).Get(clusterID+"-etcd", getOpts)
+	secret, err := k8sClient.CoreV1().Secrets(secretRef.Namespace).Get(secretRef.Name, getOpts)
 	if err != nil {
-		return nil, microerror.Maskf(err, "error getting etcd client certificates for guest cluster %s", clusterID)
+		return nil, microerror.Maskf(certificateFailedError, "error getting etcd client certificates from secret %s/%s: %s", secretRef.Namespace, secretRef.Name, err)
 	}
 
-	certs := &k8sclient.TLSClientConfig{
-		CAData:  secret.Data["ca"],
-		KeyData: secret.Data["key"],
-		CrtData: secret.Data["crt"],
+	for _, k := range []string{keys.CA, keys.Crt, keys.Key} {
+		if len(secret.Data[k]) == 0 {
+			return nil, microerror.Maskf(invalidCertSecretError, "secret %s/%s has no data key %q", secretRef.Namespace, secretRef.Name, k)
+		}
 	}
 
-	return certs, nil
-}
-
-// fetch guest cluster etcd endpoint
-func GetEtcdEndpoint(clusterID string, provider string, crdCLient *versioned.Clientset) (string, error) {
-	getOpts := metav1.GetOptions{}
-	var etcdEndpoint string
-
-	switch provider {
-	case aws:
-		{
-			crd, err := crdCLient.ProviderV1alpha1().AWSConfigs(crdNamespace).Get(clusterID, getOpts)
-			if err != nil {
-				fmt.Println()
-				return "", microerror.Maskf(err, "error getting aws crd for guest cluster %s", clusterID)
-			}
-			etcdEndpoint = AwsEtcdEndpoint(crd.Spec.Cluster.Etcd.Domain)
-			break
-		}
-	case azure:
-		{
-			crd, err := crdCLient.ProviderV1alpha1().AzureConfigs(crdNamespace).Get(clusterID, getOpts)
-			if err != nil {
-				fmt.Println()
-				return "", microerror.Maskf(err, "error getting azure crd for guest cluster %s", clusterID)
-			}
-			etcdEndpoint = AzureEtcdEndpoint(crd.Spec.Cluster.Etcd.Domain)
-			break
-		}
-	case kvm:
-		{
-			crd, err := crdCLient.ProviderV1alpha1().KVMConfigs(crdNamespace).Get(clusterID, getOpts)
-			if err != nil {
-				fmt.Println()
-				return "", microerror.Maskf(err, "error getting kvm crd for guest cluster %s", clusterID)
-			}
-			etcdEndpoint = KVMEtcdEndpoint(crd.Spec.Cluster.Etcd.Domain)
-			break
-		}
+	certs := &k8sclient.TLSClientConfig{
+		CAData:  secret.Data[keys.CA],
+		KeyData: secret.Data[keys.Key],
+		CrtData: secret.Data[keys.Crt],
 	}
 
-	// we already check for unknown provider at the start
-	return etcdEndpoint, nil
+	return certs, nil
 }
 
 // create cert files in tmp dir from certConfig and saves filenames back
@@ -251,21 +145,21 @@ func CreateCertFiles(clusterID string, certConfig *k8sclient.TLSClientConfig, tm
 	// cert
 	err := ioutil.WriteFile(CertFile(clusterID, tmpDir), certConfig.CrtData, fileMode)
 	if err != nil {
-		return microerror.Maskf(err, "Failed to write crt file "+CertFile(clusterID, tmpDir))
+		return microerror.Maskf(certificateFailedError, "Failed to write crt file %s: %s", CertFile(clusterID, tmpDir), err)
 	}
 	certConfig.CrtFile = CertFile(clusterID, tmpDir)
 
 	// key
 	err = ioutil.WriteFile(KeyFile(clusterID, tmpDir), certConfig.KeyData, fileMode)
 	if err != nil {
-		return microerror.Maskf(err, "Failed to write key file "+KeyFile(clusterID, tmpDir))
+		return microerror.Maskf(certificateFailedError, "Failed to write key file %s: %s", KeyFile(clusterID, tmpDir), err)
 	}
 	certConfig.KeyFile = KeyFile(clusterID, tmpDir)
 
 	// ca
 	err = ioutil.WriteFile(CAFile(clusterID, tmpDir), certConfig.CAData, fileMode)
 	if err != nil {
-		return microerror.Maskf(err, "Failed to write ca file "+CAFile(clusterID, tmpDir))
+		return microerror.Maskf(certificateFailedError, "Failed to write ca file %s: %s", CAFile(clusterID, tmpDir), err)
 	}
 	certConfig.CAFile = CAFile(clusterID, tmpDir)
 
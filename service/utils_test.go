@@ -0,0 +1,126 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/giantswarm/apiextensions/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/apiextensions/pkg/clientset/versioned"
+	providerv1alpha1 "github.com/giantswarm/apiextensions/pkg/clientset/versioned/typed/provider/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/etcd-backup/discovery"
+)
+
+// fakeG8sClient serves one CR named after the provider for every provider
+// and namespace.
+type fakeG8sClient struct {
+	versioned.Interface
+}
+
+func (c fakeG8sClient) ProviderV1alpha1() providerv1alpha1.ProviderV1alpha1Interface {
+	return fakeProvider{}
+}
+
+type fakeProvider struct {
+	providerv1alpha1.ProviderV1alpha1Interface
+}
+
+func (fakeProvider) AWSConfigs(namespace string) providerv1alpha1.AWSConfigInterface {
+	return fakeAWSConfigs{}
+}
+
+func (fakeProvider) AzureConfigs(namespace string) providerv1alpha1.AzureConfigInterface {
+	return fakeAzureConfigs{}
+}
+
+func (fakeProvider) KVMConfigs(namespace string) providerv1alpha1.KVMConfigInterface {
+	return fakeKVMConfigs{}
+}
+
+type fakeAWSConfigs struct {
+	providerv1alpha1.AWSConfigInterface
+}
+
+func (fakeAWSConfigs) List(opts metav1.ListOptions) (*v1alpha1.AWSConfigList, error) {
+	return &v1alpha1.AWSConfigList{Items: []v1alpha1.AWSConfig{{ObjectMeta: metav1.ObjectMeta{Name: aws}}}}, nil
+}
+
+type fakeAzureConfigs struct {
+	providerv1alpha1.AzureConfigInterface
+}
+
+func (fakeAzureConfigs) List(opts metav1.ListOptions) (*v1alpha1.AzureConfigList, error) {
+	return &v1alpha1.AzureConfigList{Items: []v1alpha1.AzureConfig{{ObjectMeta: metav1.ObjectMeta{Name: azure}}}}, nil
+}
+
+type fakeKVMConfigs struct {
+	providerv1alpha1.KVMConfigInterface
+}
+
+func (fakeKVMConfigs) List(opts metav1.ListOptions) (*v1alpha1.KVMConfigList, error) {
+	return &v1alpha1.KVMConfigList{Items: []v1alpha1.KVMConfig{{ObjectMeta: metav1.ObjectMeta{Name: kvm}}}}, nil
+}
+
+func Test_CreateClusterSource(t *testing.T) {
+	certSecret := discovery.CertSecret{
+		Namespace:    config.DefaultNamespace,
+		NameTemplate: config.DefaultSecretNameTemplate,
+		Keys:         discovery.CertKeys{CA: "ca", Crt: "crt", Key: "key"},
+	}
+
+	testCases := []struct {
+		name         string
+		provider     string
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: aws",
+			provider: aws,
+		},
+		{
+			name:     "case 1: azure",
+			provider: azure,
+		},
+		{
+			name:     "case 2: kvm",
+			provider: kvm,
+		},
+		{
+			name:         "case 3: unknown provider",
+			provider:     "openstack",
+			errorMatcher: IsInvalidProvider,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source, err := CreateClusterSource(tc.provider, fakeG8sClient{}, []string{config.DefaultNamespace}, certSecret)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+			if err != nil {
+				return
+			}
+
+			clusters, err := source.Clusters()
+			if err != nil {
+				t.Fatalf("expected no error, got %#v", err)
+			}
+			if len(clusters) != 1 || clusters[0].ID != tc.provider {
+				t.Fatalf("expected the %s CR, got %#v", tc.provider, clusters)
+			}
+			if clusters[0].CertSecretRef.Name != tc.provider+"-etcd" {
+				t.Fatalf("expected secret %s-etcd, got %#v", tc.provider, clusters[0].CertSecretRef)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var uploadFailedError = microerror.New("upload failed")
+
+// IsUploadFailed asserts uploadFailedError.
+func IsUploadFailed(err error) bool {
+	return microerror.Cause(err) == uploadFailedError
+}
+
+var notFoundError = microerror.New("not found")
+
+// IsNotFound asserts notFoundError and S3 errors for missing objects.
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	c := microerror.Cause(err)
+	if c == notFoundError {
+		return true
+	}
+	if aerr, ok := c.(awserr.Error); ok {
+		switch aerr.Code() {
+		case "NotFound", "NoSuchKey":
+			return true
+		}
+	}
+
+	return false
+}
+
+var checksumMismatchError = microerror.New("checksum mismatch")
+
+// IsChecksumMismatch asserts checksumMismatchError.
+func IsChecksumMismatch(err error) bool {
+	return microerror.Cause(err) == checksumMismatchError
+}
+
+var invalidManifestError = microerror.New("invalid manifest")
+
+// IsInvalidManifest asserts invalidManifestError.
+func IsInvalidManifest(err error) bool {
+	return microerror.Cause(err) == invalidManifestError
+}
@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+const (
+	// PolicyAll needs every destination to succeed.
+	PolicyAll = "all"
+	// PolicyAny needs at least one destination to succeed.
+	PolicyAny = "any"
+	// PolicyPrimary needs the first destination to succeed, failures of the
+	// others are only reported.
+	PolicyPrimary = "primary"
+)
+
+// ValidPolicy returns whether p is a known success policy.
+func ValidPolicy(p string) bool {
+	return p == PolicyAll || p == PolicyAny || p == PolicyPrimary
+}
+
+// copyBufferSize is the size of the chunks passed to the destinations.
+const copyBufferSize = 1 << 20
+
+// errDestinationDone closes the pipe of a destination that finished, so
+// writes to it fail instead of blocking.
+var errDestinationDone = microerror.New("destination done")
+
+type FanOutConfig struct {
+	Logger micrologger.Logger
+
+	Destinations []Destination
+	Policy       string
+	// Replication optionally verifies that the upload to the first S3
+	// destination is replicated, see VerifyReplication.
+	Replication *ReplicationChecker
+}
+
+// FanOut uploads the same file to all destinations and decides by policy
+// whether the upload as a whole succeeded.
+type FanOut struct {
+	logger micrologger.Logger
+
+	destinations []Destination
+	policy       string
+	replication  *ReplicationChecker
+	primary      *S3
+}
+
+func NewFanOut(config FanOutConfig) (*FanOut, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if len(config.Destinations) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Destinations must not be empty", config)
+	}
+	if !ValidPolicy(config.Policy) {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Policy must be %s, %s or %s", config, PolicyAll, PolicyAny, PolicyPrimary)
+	}
+
+	var primary *S3
+	for _, d := range config.Destinations {
+		if s3, ok := d.(*S3); ok {
+			primary = s3
+			break
+		}
+	}
+	if config.Replication != nil && primary == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Replication needs an S3 destination", config)
+	}
+
+	f := &FanOut{
+		logger: config.Logger,
+
+		destinations: config.Destinations,
+		policy:       config.Policy,
+		replication:  config.Replication,
+		primary:      primary,
+	}
+
+	return f, nil
+}
+
+// Upload streams r to all destinations at once. A destination failing
+// midway is dropped while the others continue. Whether the upload as a
+// whole failed is decided by the policy.
+func (f *FanOut) Upload(name string, r io.Reader, metadata map[string]string) (Result, error) {
+	result := Result{
+		Size:         -1,
+		Destinations: make([]DestinationResult, len(f.destinations)),
+	}
+
+	mw := newManifestWriter(name, metadata)
+
+	var wg sync.WaitGroup
+	writers := make([]*io.PipeWriter, len(f.destinations))
+	start := time.Now()
+	for i, d := range f.destinations {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+
+		wg.Add(1)
+		go func(i int, d Destination) {
+			defer wg.Done()
+
+			size, err := d.Upload(name, pr, metadata, mw.Manifest)
+			if err == nil {
+				err = errDestinationDone
+			}
+			// unblock the fan-out if the destination stopped reading early
+			pr.CloseWithError(err)
+			if err == errDestinationDone {
+				err = nil
+			}
+
+			finished := time.Now()
+			result.Destinations[i] = DestinationResult{
+				Name:     d.Name(),
+				Size:     size,
+				Duration: finished.Sub(start),
+				Finished: finished,
+				Err:      err,
+			}
+		}(i, d)
+	}
+
+	readErr := f.copy(mw, writers, r)
+	if readErr == nil {
+		mw.finish()
+	}
+	for _, pw := range writers {
+		pw.CloseWithError(readErr)
+	}
+	wg.Wait()
+
+	if readErr != nil {
+		return result, microerror.Mask(readErr)
+	}
+	result.Manifest = mw.Manifest()
+
+	var failed int
+	for i, d := range f.destinations {
+		r := result.Destinations[i]
+		if r.Err != nil {
+			failed++
+			f.logger.Log("level", "error", "msg", "Failed to upload backup to "+d.Name(), "reason", r.Err)
+			continue
+		}
+		if result.Size < 0 {
+			result.Size = r.Size
+		}
+	}
+
+	var ok bool
+	switch f.policy {
+	case PolicyAll:
+		ok = failed == 0
+	case PolicyAny:
+		ok = failed < len(f.destinations)
+	case PolicyPrimary:
+		ok = result.Destinations[0].Err == nil
+	}
+
+	if !ok {
+		return result, microerror.Maskf(uploadFailedError, "%d of %d destinations failed with policy %s", failed, len(f.destinations), f.policy)
+	}
+	if failed > 0 {
+		f.logger.Log("level", "warning", "msg", fmt.Sprintf("%d of %d destinations failed, backup counts as successful with policy %s", failed, len(f.destinations), f.policy))
+	}
+
+	return result, nil
+}
+
+// VerifyReplication checks that the upload of name with result arrived in
+// the replication targets of the first S3 destination. It returns nothing
+// without replication targets or when the upload to that destination failed.
+// Replication problems are reported but do not fail the backup.
+func (f *FanOut) VerifyReplication(name string, result Result) []ReplicationResult {
+	if f.replication == nil {
+		return nil
+	}
+
+	for i, d := range f.destinations {
+		if d != Destination(f.primary) {
+			continue
+		}
+		if i >= len(result.Destinations) || result.Destinations[i].Err != nil {
+			return nil
+		}
+
+		replication, err := f.replication.Verify(f.primary, name, result.Manifest, result.Destinations[i].Finished)
+		if err != nil {
+			f.logger.Log("level", "error", "msg", "Failed to verify replication", "reason", err)
+		}
+		return replication
+	}
+
+	return nil
+}
+
+func anyLive(live []bool) bool {
+	for _, l := range live {
+		if l {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copy reads r and writes every chunk to hash and all destinations still
+// reading. It returns read errors only.
+func (f *FanOut) copy(hash io.Writer, writers []*io.PipeWriter, r io.Reader) error {
+	live := make([]bool, len(writers))
+	for i := range live {
+		live[i] = true
+	}
+
+	buf := make([]byte, copyBufferSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			for i, pw := range writers {
+				if !live[i] {
+					continue
+				}
+				_, werr := pw.Write(buf[:n])
+				if werr != nil {
+					live[i] = false
+				}
+			}
+		}
+		if !anyLive(live) {
+			// no one is reading anymore, the policy check reports the
+			// failed destinations
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+}
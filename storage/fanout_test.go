@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger"
+)
+
+func testLogger(t *testing.T) micrologger.Logger {
+	logger, err := micrologger.New(micrologger.Config{IOWriter: ioutil.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return logger
+}
+
+// fakeDestination stores the uploaded data. With err set it fails, right
+// away if early is set and after reading everything otherwise.
+type fakeDestination struct {
+	name  string
+	err   error
+	early bool
+
+	data     []byte
+	manifest Manifest
+}
+
+func (d *fakeDestination) Name() string {
+	return d.name
+}
+
+func (d *fakeDestination) Upload(name string, r io.Reader, metadata map[string]string, manifest func() Manifest) (int64, error) {
+	if d.err != nil && d.early {
+		return -1, d.err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return -1, err
+	}
+	if d.err != nil {
+		return -1, d.err
+	}
+	d.data = data
+	d.manifest = manifest()
+
+	return int64(len(data)), nil
+}
+
+func Test_FanOut_Policy(t *testing.T) {
+	destErr := errors.New("bucket gone")
+
+	testCases := []struct {
+		name         string
+		policy       string
+		destinations []*fakeDestination
+		errorMatcher func(error) bool
+	}{
+		{
+			name:   "case 0: all succeed with policy all",
+			policy: PolicyAll,
+			destinations: []*fakeDestination{
+				{name: "a"},
+				{name: "b"},
+			},
+			errorMatcher: nil,
+		},
+		{
+			name:   "case 1: one fails with policy all",
+			policy: PolicyAll,
+			destinations: []*fakeDestination{
+				{name: "a"},
+				{name: "b", err: destErr},
+			},
+			errorMatcher: IsUploadFailed,
+		},
+		{
+			name:   "case 2: all but one fail with policy any",
+			policy: PolicyAny,
+			destinations: []*fakeDestination{
+				{name: "a", err: destErr, early: true},
+				{name: "b", err: destErr},
+				{name: "c"},
+			},
+			errorMatcher: nil,
+		},
+		{
+			name:   "case 3: all fail with policy any",
+			policy: PolicyAny,
+			destinations: []*fakeDestination{
+				{name: "a", err: destErr, early: true},
+				{name: "b", err: destErr},
+			},
+			errorMatcher: IsUploadFailed,
+		},
+		{
+			name:   "case 4: others fail with policy primary",
+			policy: PolicyPrimary,
+			destinations: []*fakeDestination{
+				{name: "a"},
+				{name: "b", err: destErr, early: true},
+				{name: "c", err: destErr},
+			},
+			errorMatcher: nil,
+		},
+		{
+			name:   "case 5: first fails with policy primary",
+			policy: PolicyPrimary,
+			destinations: []*fakeDestination{
+				{name: "a", err: destErr, early: true},
+				{name: "b"},
+			},
+			errorMatcher: IsUploadFailed,
+		},
+	}
+
+	// larger than a copy buffer, so destinations stopping early would block
+	// the others
+	data := bytes.Repeat([]byte("snapshot"), copyBufferSize/2)
+	sum := sha256.Sum256(data)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var destinations []Destination
+			for _, d := range tc.destinations {
+				destinations = append(destinations, d)
+			}
+			f, err := NewFanOut(FanOutConfig{
+				Logger:       testLogger(t),
+				Destinations: destinations,
+				Policy:       tc.policy,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			done := make(chan error, 1)
+			var result Result
+			go func() {
+				var err error
+				result, err = f.Upload("backup.db", bytes.NewReader(data), nil)
+				done <- err
+			}()
+
+			select {
+			case err = <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Upload did not return")
+			}
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if result.Manifest.Size != int64(len(data)) || result.Manifest.SHA256 != hex.EncodeToString(sum[:]) {
+				t.Fatalf("expected the manifest of the data, got %#v", result.Manifest)
+			}
+			for i, d := range tc.destinations {
+				if (result.Destinations[i].Err != nil) != (d.err != nil) {
+					t.Fatalf("expected error %v for %s, got %v", d.err, d.name, result.Destinations[i].Err)
+				}
+				if d.err == nil && !bytes.Equal(d.data, data) {
+					t.Fatalf("expected %s to store all %d bytes, got %d", d.name, len(data), len(d.data))
+				}
+			}
+		})
+	}
+}
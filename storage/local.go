@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+type LocalConfig struct {
+	Logger micrologger.Logger
+
+	// Path is the directory backups are copied to, e.g. an NFS mount.
+	Path string
+}
+
+// Local copies backups to a directory.
+type Local struct {
+	logger micrologger.Logger
+
+	path string
+}
+
+func NewLocal(config LocalConfig) (*Local, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Path == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Path must not be empty", config)
+	}
+
+	l := &Local{
+		logger: config.Logger,
+
+		path: config.Path,
+	}
+
+	return l, nil
+}
+
+func (l *Local) Name() string {
+	return "file://" + l.path
+}
+
+// Upload copies r into the directory, followed by the manifest. The copy is
+// written to a temporary file first, so readers never see partial backups,
+// and is read back and checked against the manifest before it is moved into
+// place.
+func (l *Local) Upload(name string, r io.Reader, metadata map[string]string, manifest func() Manifest) (int64, error) {
+	err := os.MkdirAll(l.path, 0700)
+	if err != nil {
+		return -1, microerror.Mask(err)
+	}
+
+	check := func(tmpPath string) error {
+		f, err := os.Open(tmpPath)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		defer f.Close()
+
+		v := newVerifier(manifest())
+		_, err = io.Copy(v, f)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return v.verify()
+	}
+	err = l.write(name, r, check)
+	if err != nil {
+		return -1, microerror.Mask(err)
+	}
+
+	m := manifest()
+	data, err := m.marshal()
+	if err != nil {
+		return -1, microerror.Mask(err)
+	}
+	err = l.write(name+ManifestExt, bytes.NewReader(data), nil)
+	if err != nil {
+		return -1, microerror.Mask(err)
+	}
+
+	l.logger.Log("level", "info", "msg", fmt.Sprintf("Local: file %s successfully copied to %s", name, l.path), "sha256", m.SHA256)
+
+	return m.Size, nil
+}
+
+// write atomically stores r as name in the directory. check is called after
+// all data is written and before the file is renamed into place.
+func (l *Local) write(name string, r io.Reader, check func(tmpPath string) error) error {
+	tmp, err := ioutil.TempFile(l.path, ".upload-")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return microerror.Mask(err)
+	}
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return microerror.Mask(err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if check != nil {
+		err = check(tmp.Name())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err = os.Rename(tmp.Name(), filepath.Join(l.path, name))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// List returns the backups in the directory whose names start with prefix.
+func (l *Local) List(prefix string) ([]Object, error) {
+	infos, err := ioutil.ReadDir(l.path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var objects []Object
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || IsManifest(name) || strings.HasPrefix(name, ".") || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		objects = append(objects, Object{
+			Name:     name,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+
+	return objects, nil
+}
+
+// Manifest returns the manifest stored next to the backup name.
+func (l *Local) Manifest(name string) (Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(l.path, name+ManifestExt))
+	if os.IsNotExist(err) {
+		return Manifest{}, microerror.Maskf(notFoundError, "no manifest for %s in %s", name, l.path)
+	} else if err != nil {
+		return Manifest{}, microerror.Mask(err)
+	}
+
+	return unmarshalManifest(data)
+}
+
+// Download writes the backup name to w.
+func (l *Local) Download(name string, w io.Writer) error {
+	f, err := os.Open(filepath.Join(l.path, name))
+	if os.IsNotExist(err) {
+		return microerror.Maskf(notFoundError, "file %s in %s", name, l.path)
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// ManifestExt is appended to the backup name to form the name of the
+// manifest stored next to it.
+const ManifestExt = ".manifest.json"
+
+// Manifest describes a stored backup file. It is written next to the backup
+// by every destination and used to verify the backup before it is restored.
+type Manifest struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+	Created time.Time `json:"created"`
+	// Metadata describes the backup, e.g. the etcd member it was taken
+	// from.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// manifestWriter computes the manifest of the data written to it. The
+// manifest is complete after finish.
+type manifestWriter struct {
+	name     string
+	metadata map[string]string
+	hash     hash.Hash
+	size     int64
+
+	done     chan struct{}
+	manifest Manifest
+}
+
+func newManifestWriter(name string, metadata map[string]string) *manifestWriter {
+	return &manifestWriter{
+		name:     name,
+		metadata: metadata,
+		hash:     sha256.New(),
+
+		done: make(chan struct{}),
+	}
+}
+
+func (w *manifestWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	return w.hash.Write(p)
+}
+
+func (w *manifestWriter) finish() {
+	w.manifest = Manifest{
+		Name:    w.name,
+		Size:    w.size,
+		SHA256:  hex.EncodeToString(w.hash.Sum(nil)),
+		Created: time.Now().UTC(),
+
+		Metadata: w.metadata,
+	}
+	close(w.done)
+}
+
+// Manifest waits for finish and returns the manifest.
+func (w *manifestWriter) Manifest() Manifest {
+	<-w.done
+	return w.manifest
+}
+
+// IsManifest returns whether name is the name of a manifest.
+func IsManifest(name string) bool {
+	return strings.HasSuffix(name, ManifestExt)
+}
+
+func (m Manifest) marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return b, nil
+}
+
+func unmarshalManifest(b []byte) (Manifest, error) {
+	var m Manifest
+	err := json.Unmarshal(b, &m)
+	if err != nil {
+		return Manifest{}, microerror.Maskf(invalidManifestError, "%s", err)
+	}
+	if m.SHA256 == "" {
+		return Manifest{}, microerror.Maskf(invalidManifestError, "manifest of %s has no sha256", m.Name)
+	}
+
+	return m, nil
+}
+
+// verifier hashes everything written to it and compares the result with a
+// manifest.
+type verifier struct {
+	manifest Manifest
+	hash     hash.Hash
+	size     int64
+}
+
+func newVerifier(m Manifest) *verifier {
+	return &verifier{
+		manifest: m,
+		hash:     sha256.New(),
+	}
+}
+
+func (v *verifier) Write(p []byte) (int, error) {
+	v.size += int64(len(p))
+	return v.hash.Write(p)
+}
+
+func (v *verifier) verify() error {
+	if v.size != v.manifest.Size {
+		return microerror.Maskf(checksumMismatchError, "%s has %d bytes, manifest records %d", v.manifest.Name, v.size, v.manifest.Size)
+	}
+	sum := hex.EncodeToString(v.hash.Sum(nil))
+	if sum != v.manifest.SHA256 {
+		return microerror.Maskf(checksumMismatchError, "%s has sha256 %s, manifest records %s", v.manifest.Name, sum, v.manifest.SHA256)
+	}
+
+	return nil
+}
+
+// Fetch downloads the backup name from src to fpath and verifies it against
+// its manifest. On mismatch fpath is removed, so unverified data is never
+// left behind for decryption.
+func Fetch(src Source, name string, fpath string) (Manifest, error) {
+	m, err := src.Manifest(name)
+	if err != nil {
+		return Manifest{}, microerror.Mask(err)
+	}
+
+	f, err := os.OpenFile(fpath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return Manifest{}, microerror.Mask(err)
+	}
+
+	v := newVerifier(m)
+	err = src.Download(name, io.MultiWriter(f, v))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = v.verify()
+	}
+	if err != nil {
+		os.Remove(fpath)
+		return Manifest{}, microerror.Mask(err)
+	}
+
+	return m, nil
+}
+
+// Verify downloads the backup name from src and checks it against its
+// manifest without storing it.
+func Verify(src Source, name string) (Manifest, error) {
+	m, err := src.Manifest(name)
+	if err != nil {
+		return Manifest{}, microerror.Mask(err)
+	}
+
+	v := newVerifier(m)
+	err = src.Download(name, v)
+	if err != nil {
+		return Manifest{}, microerror.Mask(err)
+	}
+	err = v.verify()
+	if err != nil {
+		return m, microerror.Mask(err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+// fakeSource serves a single backup and its manifest.
+type fakeSource struct {
+	data     []byte
+	manifest *Manifest
+}
+
+func (s *fakeSource) Name() string {
+	return "fake"
+}
+
+func (s *fakeSource) List(prefix string) ([]Object, error) {
+	return nil, nil
+}
+
+func (s *fakeSource) Manifest(name string) (Manifest, error) {
+	if s.manifest == nil {
+		return Manifest{}, microerror.Maskf(notFoundError, "no manifest for %s", name)
+	}
+
+	return *s.manifest, nil
+}
+
+func (s *fakeSource) Download(name string, w io.Writer) error {
+	_, err := w.Write(s.data)
+	return err
+}
+
+func testManifest(data string) *Manifest {
+	sum := sha256.Sum256([]byte(data))
+	return &Manifest{Name: "backup.db", Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+}
+
+func Test_Verify(t *testing.T) {
+	testCases := []struct {
+		name         string
+		data         string
+		manifest     *Manifest
+		errorMatcher func(error) bool
+	}{
+		{
+			name:         "case 0: backup matches its manifest",
+			data:         "snapshot",
+			manifest:     testManifest("snapshot"),
+			errorMatcher: nil,
+		},
+		{
+			name:         "case 1: backup is truncated",
+			data:         "snap",
+			manifest:     testManifest("snapshot"),
+			errorMatcher: IsChecksumMismatch,
+		},
+		{
+			name:         "case 2: backup has the size but not the checksum of its manifest",
+			data:         "snapsh0t",
+			manifest:     testManifest("snapshot"),
+			errorMatcher: IsChecksumMismatch,
+		},
+		{
+			name:         "case 3: backup has no manifest",
+			data:         "snapshot",
+			manifest:     nil,
+			errorMatcher: IsNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := &fakeSource{data: []byte(tc.data), manifest: tc.manifest}
+
+			_, err := Verify(src, "backup.db")
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
+
+func Test_Fetch_RemovesMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-backup-fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "backup.db")
+	src := &fakeSource{data: []byte("snapsh0t"), manifest: testManifest("snapshot")}
+
+	_, err = Fetch(src, "backup.db", fpath)
+	if !IsChecksumMismatch(err) {
+		t.Fatalf("error == %#v, want checksum mismatch", err)
+	}
+	if _, err := os.Stat(fpath); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, got %v", fpath, err)
+	}
+
+	src.data = []byte("snapshot")
+	_, err = Fetch(src, "backup.db", fpath)
+	if err != nil {
+		t.Fatalf("expected no error, got %#v", err)
+	}
+	data, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "snapshot" {
+		t.Fatalf("expected the backup in %s, got %q", fpath, data)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/etcd-backup/config"
+)
+
+// ParseDestination creates a destination from its URL form:
+//
+//	s3://bucket[/key/prefix][?region=eu-west-1&role-arn=...&external-id=...]
+//	file:///path/to/dir
+//
+// S3 settings not given in the URL are taken from defaults.
+func ParseDestination(spec string, defaults config.AWSConfig, logger micrologger.Logger) (Destination, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "destination %q: %s", spec, err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		c := defaults
+		c.Bucket = u.Host
+
+		q := u.Query()
+		if v := q.Get("region"); v != "" {
+			c.Region = v
+		}
+		if v := q.Get("role-arn"); v != "" {
+			c.RoleARN = v
+		}
+		if v := q.Get("external-id"); v != "" {
+			c.ExternalID = v
+		}
+
+		keyPrefix := strings.TrimPrefix(u.Path, "/")
+		if keyPrefix != "" && !strings.HasSuffix(keyPrefix, "/") {
+			keyPrefix += "/"
+		}
+
+		return NewS3(S3Config{Logger: logger, AWS: c, KeyPrefix: keyPrefix})
+	case "file":
+		return NewLocal(LocalConfig{Logger: logger, Path: u.Path})
+	}
+
+	return nil, microerror.Maskf(invalidConfigError, "destination %q must start with s3:// or file://", spec)
+}
+
+// ParseSource creates a source to read backups from, in the URL form of
+// ParseDestination.
+func ParseSource(spec string, defaults config.AWSConfig, logger micrologger.Logger) (Source, error) {
+	d, err := ParseDestination(spec, defaults, logger)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	src, ok := d.(Source)
+	if !ok {
+		return nil, microerror.Maskf(invalidConfigError, "destination %q can not be read from", spec)
+	}
+
+	return src, nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/etcd-backup/config"
+)
+
+const (
+	ReplicationReplicated = "replicated"
+	ReplicationMismatch   = "mismatch"
+	ReplicationMissing    = "missing"
+)
+
+type ReplicationCheckerConfig struct {
+	Logger micrologger.Logger
+
+	// Targets are the buckets the primary bucket replicates to.
+	Targets []*S3
+	// Timeout is how long to wait for an object to show up in a target.
+	Timeout time.Duration
+	// PollInterval is the time between two checks of a target.
+	PollInterval time.Duration
+}
+
+// ReplicationChecker verifies that uploaded objects arrive in the buckets
+// the primary bucket replicates to, with the same size and checksum.
+type ReplicationChecker struct {
+	logger micrologger.Logger
+
+	targets      []*S3
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+// ReplicationResult is the outcome of checking one replication target.
+type ReplicationResult struct {
+	Target string
+	Status string
+	// Lag is the time between upload and the object showing up in the
+	// target, rounded up to the poll interval. It is zero for missing
+	// replicas.
+	Lag     time.Duration
+	Message string
+}
+
+func NewReplicationChecker(config ReplicationCheckerConfig) (*ReplicationChecker, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if len(config.Targets) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Targets must not be empty", config)
+	}
+	if config.Timeout <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Timeout must be positive", config)
+	}
+	if config.PollInterval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PollInterval must be positive", config)
+	}
+
+	c := &ReplicationChecker{
+		logger: config.Logger,
+
+		targets:      config.Targets,
+		timeout:      config.Timeout,
+		pollInterval: config.PollInterval,
+	}
+
+	return c, nil
+}
+
+// Verify polls all targets for the object uploaded to primary at uploaded
+// and compares the replica and its replicated manifest with the manifest m
+// of the upload. ETags are not compared, they differ between single and
+// multipart uploads of the same data.
+func (c *ReplicationChecker) Verify(primary *S3, fpath string, m Manifest, uploaded time.Time) ([]ReplicationResult, error) {
+	key := primary.ObjectKey(fpath)
+
+	var results []ReplicationResult
+	for _, target := range c.targets {
+		r := c.verifyTarget(target, filepath.Base(fpath), m, uploaded)
+		results = append(results, r)
+
+		switch r.Status {
+		case ReplicationReplicated:
+			c.logger.Log("level", "info", "msg", fmt.Sprintf("Object %s replicated to %s after %s", key, r.Target, r.Lag))
+		default:
+			c.logger.Log("level", "warning", "msg", fmt.Sprintf("Object %s not replicated to %s", key, r.Target), "reason", r.Message)
+		}
+	}
+
+	return results, nil
+}
+
+// verifyTarget polls target until the backup name and its manifest are
+// replicated and compares them with the manifest m.
+func (c *ReplicationChecker) verifyTarget(target *S3, name string, m Manifest, uploaded time.Time) ReplicationResult {
+	r := ReplicationResult{
+		Target: target.Name(),
+	}
+
+	key := target.ObjectKey(name)
+	deadline := uploaded.Add(c.timeout)
+	for {
+		mismatch, err := compareReplica(target, name, m)
+		if err == nil {
+			r.Lag = time.Since(uploaded)
+
+			if mismatch != "" {
+				r.Status = ReplicationMismatch
+				r.Message = mismatch
+				return r
+			}
+
+			r.Status = ReplicationReplicated
+			return r
+		} else if !IsNotFound(err) {
+			c.logger.Log("level", "warning", "msg", "Failed to check replica in "+r.Target, "reason", err)
+		}
+
+		if time.Now().Add(c.pollInterval).After(deadline) {
+			r.Status = ReplicationMissing
+			r.Message = fmt.Sprintf("object %s not found within %s", key, c.timeout)
+			return r
+		}
+
+		time.Sleep(c.pollInterval)
+	}
+}
+
+// compareReplica compares the replica of the backup name in target and its
+// replicated manifest with the manifest m. It returns why they differ, or a
+// not found error while either is not replicated yet.
+func compareReplica(target *S3, name string, m Manifest) (string, error) {
+	size, sha, err := target.Head(target.ObjectKey(name))
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	replicated, err := target.Manifest(name)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	switch {
+	case size != m.Size:
+		return fmt.Sprintf("primary has size %d, replica has size %d", m.Size, size), nil
+	case replicated.SHA256 != m.SHA256:
+		return fmt.Sprintf("primary has sha256 %s, replicated manifest has sha256 %s", m.SHA256, replicated.SHA256), nil
+	case sha != "" && sha != m.SHA256:
+		return fmt.Sprintf("primary has sha256 %s, replica has sha256 %s", m.SHA256, sha), nil
+	}
+
+	return "", nil
+}
+
+// ParseReplicationTarget creates a replication target from its URL form
+// s3://bucket[/key/prefix][?region=...&role-arn=...&external-id=...].
+func ParseReplicationTarget(spec string, defaults config.AWSConfig, logger micrologger.Logger) (*S3, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "replication target %q: %s", spec, err)
+	}
+	if u.Scheme != "s3" {
+		return nil, microerror.Maskf(invalidConfigError, "replication target %q must start with s3://", spec)
+	}
+
+	d, err := ParseDestination(spec, defaults, logger)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return d.(*S3), nil
+}
@@ -0,0 +1,461 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/etcd-backup/config"
+)
+
+const (
+	// headerChecksumSHA256 makes S3 verify the uploaded data.
+	headerChecksumSHA256 = "x-amz-checksum-sha256"
+	// headerChecksumAlgorithm announces the part checksums of a multipart
+	// upload.
+	headerChecksumAlgorithm = "x-amz-checksum-algorithm"
+	// metadataSHA256 stores the checksum as x-amz-meta-sha256 on the object.
+	// It is only set on objects uploaded with a single request, the checksum
+	// of multipart uploads is not known before they complete. The manifest
+	// has it for all.
+	metadataSHA256 = "sha256"
+	// s3PartSize is the size of multipart upload parts and the largest
+	// object uploaded with a single request.
+	s3PartSize = 16 << 20
+)
+
+type S3Config struct {
+	Logger micrologger.Logger
+
+	AWS config.AWSConfig
+	// KeyPrefix is prepended to the object keys, e.g. "backups/".
+	KeyPrefix string
+}
+
+// S3 uploads backups to an S3 bucket.
+type S3 struct {
+	logger micrologger.Logger
+
+	aws       config.AWSConfig
+	keyPrefix string
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.AWS.Bucket == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.AWS.Bucket must not be empty", config)
+	}
+
+	s := &S3{
+		logger: config.Logger,
+
+		aws:       config.AWS,
+		keyPrefix: config.KeyPrefix,
+	}
+
+	return s, nil
+}
+
+func (s *S3) Name() string {
+	return "s3://" + path.Join(s.aws.Bucket, s.keyPrefix)
+}
+
+// Upload streams r to the S3 bucket, followed by the manifest. Data that
+// fits into one part is uploaded with a single request carrying the MD5 and
+// SHA-256 checksums. Larger data is uploaded as multipart upload with MD5
+// and SHA-256 checksums per part, while the next part is read. S3 rejects
+// parts that do not match their checksum. The completed multipart object is
+// compared with the manifest before the manifest is uploaded.
+func (s *S3) Upload(name string, r io.Reader, metadata map[string]string, manifest func() Manifest) (int64, error) {
+	// Login to AWS S3
+	svc, err := newS3Client(s.aws)
+	if err != nil {
+		return -1, microerror.Mask(err)
+	}
+
+	key := s.ObjectKey(name)
+
+	buf := make([]byte, s3PartSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = s.put(svc, key, buf[:n], metadata, manifest())
+		if err != nil {
+			return -1, microerror.Mask(err)
+		}
+	} else if err != nil {
+		return -1, microerror.Mask(err)
+	} else {
+		n, etag, err := s.putMultipart(svc, key, buf, r, metadata)
+		if err != nil {
+			return -1, microerror.Mask(err)
+		}
+		if n != manifest().Size {
+			return -1, microerror.Maskf(checksumMismatchError, "uploaded %d bytes of %s, manifest records %d", n, name, manifest().Size)
+		}
+		err = s.verifyMultipart(svc, key, manifest(), etag)
+		if err != nil {
+			return -1, microerror.Mask(err)
+		}
+	}
+
+	m := manifest()
+
+	// Put manifest next to the object.
+	data, err := m.marshal()
+	if err != nil {
+		return -1, microerror.Mask(err)
+	}
+	manifestParams := &s3.PutObjectInput{
+		Bucket:      aws.String(s.aws.Bucket),
+		Key:         aws.String(key + ManifestExt),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	_, err = svc.PutObject(manifestParams)
+	if err != nil {
+		return -1, microerror.Mask(err)
+	}
+
+	s.logger.Log("level", "info", "msg", fmt.Sprintf("AWS S3: object %s successfully uploaded to bucket %s", key, s.aws.Bucket), "sha256", m.SHA256)
+
+	return m.Size, nil
+}
+
+// put uploads data with a single request.
+func (s *S3) put(svc *s3.S3, key string, data []byte, metadata map[string]string, manifest Manifest) error {
+	if int64(len(data)) != manifest.Size {
+		return microerror.Maskf(checksumMismatchError, "read %d bytes of %s, manifest records %d", len(data), key, manifest.Size)
+	}
+
+	sha, err := hex.DecodeString(manifest.SHA256)
+	if err != nil {
+		return microerror.Maskf(invalidManifestError, "%s", err)
+	}
+	sum := md5.Sum(data)
+
+	params := &s3.PutObjectInput{
+		Bucket:        aws.String(s.aws.Bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentMD5:    aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+		ContentType:   aws.String("application/octet-stream"),
+		Metadata:      objectMetadata(metadata),
+	}
+	params.Metadata[metadataSHA256] = aws.String(manifest.SHA256)
+
+	// Put object to S3. The checksum header is not known to this SDK
+	// version, it is set on the request before it is signed.
+	req, _ := svc.PutObjectRequest(params)
+	req.HTTPRequest.Header.Set(headerChecksumSHA256, base64.StdEncoding.EncodeToString(sha))
+	err = req.Send()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// putMultipart uploads first and the rest of r as multipart upload and
+// returns the uploaded size and the ETag S3 gives the completed object. Each
+// part is uploaded while the next one is read. The upload is aborted on
+// failure.
+func (s *S3) putMultipart(svc *s3.S3, key string, first []byte, r io.Reader, metadata map[string]string) (int64, string, error) {
+	create := &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.aws.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String("application/octet-stream"),
+		Metadata:    objectMetadata(metadata),
+	}
+	createReq, upload := svc.CreateMultipartUploadRequest(create)
+	createReq.HTTPRequest.Header.Set(headerChecksumAlgorithm, "SHA256")
+	err := createReq.Send()
+	if err != nil {
+		return -1, "", microerror.Mask(err)
+	}
+
+	type part struct {
+		number int64
+		data   []byte
+	}
+	parts := make(chan part, 1)
+	uploaded := make(chan error, 1)
+
+	var completed []completedPart
+	// sums are the MD5 checksums of all parts, they make up the ETag
+	var sums []byte
+	go func() {
+		var err error
+		for p := range parts {
+			// drain the channel after a failure
+			if err != nil {
+				continue
+			}
+
+			sum := md5.Sum(p.data)
+			sha := sha256.Sum256(p.data)
+			params := &s3.UploadPartInput{
+				Bucket:        aws.String(s.aws.Bucket),
+				Key:           aws.String(key),
+				UploadId:      upload.UploadId,
+				PartNumber:    aws.Int64(p.number),
+				Body:          bytes.NewReader(p.data),
+				ContentLength: aws.Int64(int64(len(p.data))),
+				ContentMD5:    aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+			}
+
+			// as with put, the checksum header is set before signing
+			req, out := svc.UploadPartRequest(params)
+			req.HTTPRequest.Header.Set(headerChecksumSHA256, base64.StdEncoding.EncodeToString(sha[:]))
+			err = req.Send()
+			if err != nil {
+				err = microerror.Mask(err)
+				continue
+			}
+			completed = append(completed, completedPart{
+				ETag:           aws.StringValue(out.ETag),
+				PartNumber:     p.number,
+				ChecksumSHA256: base64.StdEncoding.EncodeToString(sha[:]),
+			})
+			sums = append(sums, sum[:]...)
+		}
+		uploaded <- err
+	}()
+
+	size := int64(len(first))
+	parts <- part{number: 1, data: first}
+
+	var readErr error
+	for number := int64(2); ; number++ {
+		buf := make([]byte, s3PartSize)
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			size += int64(n)
+			parts <- part{number: number, data: buf[:n]}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			readErr = microerror.Mask(err)
+			break
+		}
+	}
+	close(parts)
+
+	err = <-uploaded
+	if err == nil {
+		err = readErr
+	}
+	if err != nil {
+		abort := &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.aws.Bucket),
+			Key:      aws.String(key),
+			UploadId: upload.UploadId,
+		}
+		_, abortErr := svc.AbortMultipartUpload(abort)
+		if abortErr != nil {
+			s.logger.Log("level", "warning", "msg", "Failed to abort multipart upload of "+key, "reason", abortErr)
+		}
+		return -1, "", microerror.Mask(err)
+	}
+
+	body, err := xml.Marshal(completeUpload{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/", Parts: completed})
+	if err != nil {
+		return -1, "", microerror.Mask(err)
+	}
+	complete := &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.aws.Bucket),
+		Key:      aws.String(key),
+		UploadId: upload.UploadId,
+	}
+	// The part checksums are not known to this SDK version, the body is
+	// replaced after the SDK built it.
+	completeReq, _ := svc.CompleteMultipartUploadRequest(complete)
+	completeReq.Handlers.Build.PushBack(func(r *request.Request) {
+		r.SetBufferBody(body)
+	})
+	err = completeReq.Send()
+	if err != nil {
+		return -1, "", microerror.Mask(err)
+	}
+
+	etag := md5.Sum(sums)
+	return size, fmt.Sprintf("%x-%d", etag, len(completed)), nil
+}
+
+// completeUpload is the body of a CompleteMultipartUpload request including
+// the part checksums.
+type completeUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completedPart struct {
+	ETag           string `xml:"ETag"`
+	PartNumber     int64  `xml:"PartNumber"`
+	ChecksumSHA256 string `xml:"ChecksumSHA256"`
+}
+
+// verifyMultipart compares the object stored under key after a multipart
+// upload with the manifest and the ETag computed from its parts, the SHA-256
+// checksum of the whole object is not available.
+func (s *S3) verifyMultipart(svc *s3.S3, key string, m Manifest, etag string) error {
+	params := &s3.HeadObjectInput{
+		Bucket: aws.String(s.aws.Bucket),
+		Key:    aws.String(key),
+	}
+	out, err := svc.HeadObject(params)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if aws.Int64Value(out.ContentLength) != m.Size {
+		return microerror.Maskf(checksumMismatchError, "object %s has %d bytes, manifest records %d", key, aws.Int64Value(out.ContentLength), m.Size)
+	}
+	if strings.Trim(aws.StringValue(out.ETag), `"`) != etag {
+		return microerror.Maskf(checksumMismatchError, "object %s has ETag %s, its parts make %s", key, aws.StringValue(out.ETag), etag)
+	}
+
+	return nil
+}
+
+// objectMetadata converts backup metadata into S3 object metadata, stored as
+// x-amz-meta-* headers.
+func objectMetadata(metadata map[string]string) map[string]*string {
+	m := map[string]*string{}
+	for k, v := range metadata {
+		m[k] = aws.String(v)
+	}
+
+	return m
+}
+
+// List returns the backups below the key prefix whose names start with
+// prefix.
+func (s *S3) List(prefix string) ([]Object, error) {
+	svc, err := newS3Client(s.aws)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	params := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.aws.Bucket),
+		Prefix: aws.String(s.keyPrefix + prefix),
+	}
+
+	var objects []Object
+	err = svc.ListObjectsV2Pages(params, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range page.Contents {
+			name := strings.TrimPrefix(aws.StringValue(o.Key), s.keyPrefix)
+			if strings.Contains(name, "/") || IsManifest(name) {
+				continue
+			}
+			objects = append(objects, Object{
+				Name:     name,
+				Size:     aws.Int64Value(o.Size),
+				Modified: aws.TimeValue(o.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return objects, nil
+}
+
+// Manifest returns the manifest stored next to the backup name.
+func (s *S3) Manifest(name string) (Manifest, error) {
+	var buf bytes.Buffer
+	err := s.get(s.keyPrefix+name+ManifestExt, &buf)
+	if IsNotFound(err) {
+		return Manifest{}, microerror.Maskf(notFoundError, "no manifest for %s in bucket %s", name, s.aws.Bucket)
+	} else if err != nil {
+		return Manifest{}, microerror.Mask(err)
+	}
+
+	return unmarshalManifest(buf.Bytes())
+}
+
+// Download writes the backup name to w.
+func (s *S3) Download(name string, w io.Writer) error {
+	err := s.get(s.keyPrefix+name, w)
+	if IsNotFound(err) {
+		return microerror.Maskf(notFoundError, "object %s in bucket %s", s.keyPrefix+name, s.aws.Bucket)
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (s *S3) get(key string, w io.Writer) error {
+	svc, err := newS3Client(s.aws)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(s.aws.Bucket),
+		Key:    aws.String(key),
+	}
+
+	out, err := svc.GetObject(params)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer out.Body.Close()
+
+	_, err = io.Copy(w, out.Body)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// ObjectKey returns the key the file at fpath is stored under.
+func (s *S3) ObjectKey(fpath string) string {
+	return s.keyPrefix + filepath.Base(fpath)
+}
+
+// Head returns the size of the object stored under key and its SHA-256
+// checksum from the x-amz-meta-sha256 metadata, empty for multipart uploads.
+func (s *S3) Head(key string) (int64, string, error) {
+	svc, err := newS3Client(s.aws)
+	if err != nil {
+		return -1, "", microerror.Mask(err)
+	}
+
+	params := &s3.HeadObjectInput{
+		Bucket: aws.String(s.aws.Bucket),
+		Key:    aws.String(key),
+	}
+
+	out, err := svc.HeadObject(params)
+	if IsNotFound(err) {
+		return -1, "", microerror.Maskf(notFoundError, "object %s in bucket %s", key, s.aws.Bucket)
+	} else if err != nil {
+		return -1, "", microerror.Mask(err)
+	}
+
+	return aws.Int64Value(out.ContentLength), aws.StringValue(out.Metadata[strings.Title(metadataSHA256)]), nil
+}
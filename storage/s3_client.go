@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/giantswarm/etcd-backup/config"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// Environment variables set by EKS pod identity (IRSA) webhooks.
+	envWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	envRoleARN              = "AWS_ROLE_ARN"
+	envRoleSessionName      = "AWS_ROLE_SESSION_NAME"
+
+	roleSessionName = "etcd-backup"
+)
+
+// Creates S3 client. Static keys are used when configured, otherwise the
+// credential chain is tried in order: web identity token, environment,
+// shared config, ECS/EC2 metadata. When p.RoleARN is set the resulting
+// credentials assume that role.
+func newS3Client(p config.AWSConfig) (*s3.S3, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Region: aws.String(p.Region),
+		},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var creds *credentials.Credentials
+	switch {
+	case p.AccessKey != "" && p.SecretKey != "":
+		creds = credentials.NewStaticCredentials(p.AccessKey, p.SecretKey, "")
+	case os.Getenv(envWebIdentityTokenFile) != "" && os.Getenv(envRoleARN) != "":
+		creds = credentials.NewCredentials(&webIdentityProvider{
+			client:    sts.New(sess),
+			roleARN:   os.Getenv(envRoleARN),
+			tokenFile: os.Getenv(envWebIdentityTokenFile),
+		})
+	default:
+		creds = sess.Config.Credentials
+	}
+
+	if p.RoleARN != "" {
+		base := sess.Copy(&aws.Config{Credentials: creds})
+		creds = stscreds.NewCredentials(base, p.RoleARN, func(arp *stscreds.AssumeRoleProvider) {
+			arp.RoleSessionName = roleSessionName
+			if p.ExternalID != "" {
+				arp.ExternalID = aws.String(p.ExternalID)
+			}
+		})
+	}
+
+	_, err = creds.Get()
+	if err != nil {
+		return nil, microerror.Maskf(err, "failed to get AWS credentials")
+	}
+
+	return s3.New(sess, aws.NewConfig().WithCredentials(creds)), nil
+}
+
+// webIdentityProvider exchanges the projected service account token for
+// role credentials, as done by EKS IAM roles for service accounts.
+type webIdentityProvider struct {
+	credentials.Expiry
+
+	client    *sts.STS
+	roleARN   string
+	tokenFile string
+}
+
+func (p *webIdentityProvider) Retrieve() (credentials.Value, error) {
+	// the token is rotated by the kubelet, read it on every refresh
+	token, err := ioutil.ReadFile(p.tokenFile)
+	if err != nil {
+		return credentials.Value{}, microerror.Mask(err)
+	}
+
+	sessionName := os.Getenv(envRoleSessionName)
+	if sessionName == "" {
+		sessionName = roleSessionName
+	}
+
+	resp, err := p.client.AssumeRoleWithWebIdentity(&sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(p.roleARN),
+		RoleSessionName:  aws.String(sessionName),
+		WebIdentityToken: aws.String(strings.TrimSpace(string(token))),
+	})
+	if err != nil {
+		return credentials.Value{}, microerror.Mask(err)
+	}
+
+	p.SetExpiration(*resp.Credentials.Expiration, time.Minute)
+
+	v := credentials.Value{
+		AccessKeyID:     *resp.Credentials.AccessKeyId,
+		SecretAccessKey: *resp.Credentials.SecretAccessKey,
+		SessionToken:    *resp.Credentials.SessionToken,
+		ProviderName:    "WebIdentityProvider",
+	}
+
+	return v, nil
+}
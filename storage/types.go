@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"io"
+	"time"
+)
+
+// Destination stores backup files, e.g. an S3 bucket or a local path.
+type Destination interface {
+	// Name identifies the destination in logs and metrics.
+	Name() string
+	// Upload stores the data read from r under name together with its
+	// manifest and returns the stored size. The manifest is complete once r
+	// is drained, so it is passed as a function. Its metadata is passed
+	// separately for destinations that store it with the data. The stored
+	// data is checked against the manifest checksum.
+	Upload(name string, r io.Reader, metadata map[string]string, manifest func() Manifest) (int64, error)
+}
+
+// Uploader uploads a backup stream to one or more destinations.
+type Uploader interface {
+	// Upload stores the data read from r under name while it is produced.
+	// metadata describes the backup and is stored in its manifest.
+	Upload(name string, r io.Reader, metadata map[string]string) (Result, error)
+}
+
+// ReplicationVerifier is implemented by uploaders which can check that an
+// upload was replicated. Checking may wait for the replication, so it is not
+// part of Upload and its time does not count as upload time.
+type ReplicationVerifier interface {
+	// VerifyReplication checks the upload of name which returned result.
+	VerifyReplication(name string, result Result) []ReplicationResult
+}
+
+// Source reads back stored backups, e.g. for restore.
+type Source interface {
+	Name() string
+	// List returns the stored backups, without manifests, whose names start
+	// with prefix.
+	List(prefix string) ([]Object, error)
+	// Manifest returns the manifest stored next to the backup name.
+	Manifest(name string) (Manifest, error)
+	// Download writes the backup name to w.
+	Download(name string, w io.Writer) error
+}
+
+// Object is a backup file stored in a source.
+type Object struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// Result is the outcome of uploading one file to all destinations.
+type Result struct {
+	// Size of the uploaded file, -1 if no destination succeeded.
+	Size         int64
+	Manifest     Manifest
+	Destinations []DestinationResult
+	// Replication is only set when replication verification is enabled.
+	Replication []ReplicationResult
+}
+
+// DestinationResult is the outcome of uploading to a single destination.
+type DestinationResult struct {
+	Name     string
+	Size     int64
+	Duration time.Duration
+	// Finished is when the upload to the destination ended.
+	Finished time.Time
+	Err      error
+}